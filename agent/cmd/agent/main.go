@@ -9,6 +9,7 @@ import (
 
 	"github.com/GenJi77JYXC/intelligent-pioneer/agent/internal/client"
 	"github.com/GenJi77JYXC/intelligent-pioneer/agent/internal/config"
+	"github.com/GenJi77JYXC/intelligent-pioneer/agent/internal/executor"
 	"github.com/GenJi77JYXC/intelligent-pioneer/agent/internal/heartbeat"
 	"github.com/GenJi77JYXC/intelligent-pioneer/agent/internal/sysinfo"
 	"github.com/GenJi77JYXC/intelligent-pioneer/agent/internal/task"
@@ -28,6 +29,12 @@ func main() {
 	// 2. 初始化 API 客户端
 	apiClient := client.NewAPIClient(config.Cfg.BackendURL)
 
+	// 加载外部执行插件，支持的执行方式在注册时上报给后端
+	if _, err := executor.LoadPlugins(config.Cfg.PluginDir); err != nil {
+		log.Printf("WARN: Failed to load executor plugins from %s: %v", config.Cfg.PluginDir, err)
+	}
+	capabilities := executor.Kinds()
+
 	// 3. 注册 (如果需要)
 	if config.Cfg.AgentID == "" {
 		log.Println("Agent not registered. Attempting to register...")
@@ -50,7 +57,7 @@ func main() {
 			osInfo = "unknown-os"
 		}
 
-		agentID, err := apiClient.Register(hostname, ip, osInfo)
+		agentID, err := apiClient.Register(hostname, ip, osInfo, capabilities)
 		if err != nil {
 			log.Fatalf("Failed to register agent: %v", err)
 		}
@@ -61,15 +68,27 @@ func main() {
 		log.Printf("Agent registered successfully with ID: %s", agentID)
 	} else {
 		log.Printf("Agent already registered with ID: %s", config.Cfg.AgentID)
+		if err := apiClient.UpdateCapabilities(config.Cfg.AgentID, capabilities); err != nil {
+			log.Printf("WARN: Failed to report supported task kinds: %v", err)
+		}
 	}
 
 	// 4. 创建一个可以被取消的 context，用于优雅退出
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	// 5. 在 goroutine 中启动心跳和任务拉取
-	go heartbeat.Start(ctx, apiClient, config.Cfg.AgentID)
-	go task.StartPolling(ctx, apiClient, config.Cfg.AgentID)
+	// 5. 在 goroutine 中启动心跳和任务拉取，任务在有并发限制的执行池中执行
+	pool := task.NewPool(task.PoolConfig{
+		MaxConcurrency:  config.Cfg.MaxConcurrency,
+		QueueSize:       config.Cfg.QueueSize,
+		KindConcurrency: config.Cfg.KindConcurrency,
+	})
+	go heartbeat.Start(ctx, apiClient, config.Cfg.AgentID, pool.Capacity)
+	maxTasks := config.Cfg.MaxTasksPerPoll
+	if maxTasks <= 0 {
+		maxTasks = 1
+	}
+	go task.Run(ctx, apiClient, config.Cfg.AgentID, maxTasks, config.Cfg.Transport, pool)
 
 	log.Println("Agent is running. Press Ctrl+C to exit.")
 
@@ -0,0 +1,94 @@
+// Package actions 是 Agent 内置动作的注册表
+// 内置动作用 Go 实现 (不依赖 shell 命令)，在不同操作系统上返回相同结构的结果，后端可以直接基于结果做判断
+package actions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// ErrInvalidArgs 表示动作的参数无效，任务应当被拒绝而不是执行失败
+var ErrInvalidArgs = errors.New("invalid action arguments")
+
+// ErrUnsupported 表示动作在当前操作系统上不可用
+var ErrUnsupported = errors.New("action is not supported on this platform")
+
+// Handler 执行一个动作，返回的结果会被序列化为 JSON 作为任务结果的 Data
+// 动作本身执行成功、但检查的对象不正常时 (例如端口不可达) 不返回错误，而是在结果中体现
+type Handler func(ctx context.Context, args Args) (interface{}, error)
+
+var registry = make(map[string]Handler)
+
+// Register 注册一个动作，名称重复时 panic
+func Register(name string, handler Handler) {
+	if _, ok := registry[name]; ok {
+		panic("actions: duplicate action " + name)
+	}
+	registry[name] = handler
+}
+
+// Lookup 按名称查找动作
+func Lookup(name string) (Handler, bool) {
+	handler, ok := registry[name]
+	return handler, ok
+}
+
+// Names 返回所有已注册动作的名称 (按字母顺序)
+func Names() []string {
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Args 是动作的参数，取自知识库步骤中的 "arg.<name>"
+type Args map[string]string
+
+// Required 返回必填的字符串参数
+func (a Args) Required(name string) (string, error) {
+	value := a[name]
+	if value == "" {
+		return "", fmt.Errorf("%w: %s is required", ErrInvalidArgs, name)
+	}
+	return value, nil
+}
+
+// String 返回可选的字符串参数，未指定时返回 def
+func (a Args) String(name, def string) string {
+	if value := a[name]; value != "" {
+		return value
+	}
+	return def
+}
+
+// Int 返回可选的整数参数，未指定时返回 def，超出 [min, max] 时返回错误
+func (a Args) Int(name string, def, min, max int) (int, error) {
+	value := a[name]
+	if value == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < min || n > max {
+		return 0, fmt.Errorf("%w: %s must be an integer between %d and %d", ErrInvalidArgs, name, min, max)
+	}
+	return n, nil
+}
+
+// Duration 返回可选的时长参数 (例如 "5s")，未指定时返回 def
+func (a Args) Duration(name string, def time.Duration) (time.Duration, error) {
+	value := a[name]
+	if value == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("%w: %s must be a positive duration", ErrInvalidArgs, name)
+	}
+	return d, nil
+}
@@ -0,0 +1,66 @@
+package actions
+
+import (
+	"context"
+
+	"github.com/shirou/gopsutil/v3/disk"
+)
+
+func init() {
+	Register("disk.usage", diskUsage)
+}
+
+// DiskUsage 是单个文件系统的使用情况
+type DiskUsage struct {
+	Path              string  `json:"path"`
+	Fstype            string  `json:"fstype"`
+	TotalBytes        uint64  `json:"total_bytes"`
+	UsedBytes         uint64  `json:"used_bytes"`
+	FreeBytes         uint64  `json:"free_bytes"`
+	UsedPercent       float64 `json:"used_percent"`
+	InodesUsedPercent float64 `json:"inodes_used_percent"`
+}
+
+// DiskUsageList 是没有指定 path 时 disk.usage 的结果
+type DiskUsageList struct {
+	Partitions []DiskUsage `json:"partitions"`
+}
+
+// diskUsage 查询磁盘使用情况
+// 参数: path (可选)。指定时返回该路径所在文件系统的使用情况 (DiskUsage)，否则返回所有物理分区 (DiskUsageList)
+func diskUsage(ctx context.Context, args Args) (interface{}, error) {
+	if path := args.String("path", ""); path != "" {
+		return usageOf(ctx, path)
+	}
+
+	partitions, err := disk.PartitionsWithContext(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	result := DiskUsageList{Partitions: make([]DiskUsage, 0, len(partitions))}
+	for _, partition := range partitions {
+		usage, err := usageOf(ctx, partition.Mountpoint)
+		if err != nil {
+			// 无法访问的挂载点 (例如权限不足) 跳过
+			continue
+		}
+		result.Partitions = append(result.Partitions, *usage)
+	}
+	return result, nil
+}
+
+func usageOf(ctx context.Context, path string) (*DiskUsage, error) {
+	usage, err := disk.UsageWithContext(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	return &DiskUsage{
+		Path:              usage.Path,
+		Fstype:            usage.Fstype,
+		TotalBytes:        usage.Total,
+		UsedBytes:         usage.Used,
+		FreeBytes:         usage.Free,
+		UsedPercent:       usage.UsedPercent,
+		InodesUsedPercent: usage.InodesUsedPercent,
+	}, nil
+}
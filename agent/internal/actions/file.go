@@ -0,0 +1,129 @@
+package actions
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// maxTailBytes 是 file.tail 从文件末尾读取的最大字节数
+const maxTailBytes = 256 * 1024
+
+func init() {
+	Register("file.stat", statFile)
+	Register("file.tail", tailFile)
+}
+
+// FileStat 是 file.stat 的结果，文件不存在时 Exists 为 false
+type FileStat struct {
+	Path       string     `json:"path"`
+	Exists     bool       `json:"exists"`
+	IsDir      bool       `json:"is_dir"`
+	Size       int64      `json:"size"`
+	Mode       string     `json:"mode"` // 例如 "-rw-r--r--"
+	Perm       string     `json:"perm"` // 八进制权限，例如 "0644"
+	ModTime    *time.Time `json:"mod_time,omitempty"`
+	AgeSeconds int64      `json:"age_seconds"` // 距最后修改的秒数
+}
+
+// FileTail 是 file.tail 的结果，Truncated 表示文件中还有更早的行没有返回
+type FileTail struct {
+	Path      string   `json:"path"`
+	Size      int64    `json:"size"`
+	Lines     []string `json:"lines"`
+	Truncated bool     `json:"truncated"`
+}
+
+func absPath(args Args) (string, error) {
+	path, err := args.Required("path")
+	if err != nil {
+		return "", err
+	}
+	if !filepath.IsAbs(path) {
+		return "", fmt.Errorf("%w: path %q must be absolute", ErrInvalidArgs, path)
+	}
+	return path, nil
+}
+
+// statFile 查询文件信息
+// 参数: path (绝对路径)
+func statFile(ctx context.Context, args Args) (interface{}, error) {
+	path, err := absPath(args)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return FileStat{Path: path}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	modTime := info.ModTime()
+	return FileStat{
+		Path:       path,
+		Exists:     true,
+		IsDir:      info.IsDir(),
+		Size:       info.Size(),
+		Mode:       info.Mode().String(),
+		Perm:       fmt.Sprintf("%04o", info.Mode().Perm()),
+		ModTime:    &modTime,
+		AgeSeconds: int64(time.Since(modTime) / time.Second),
+	}, nil
+}
+
+// tailFile 返回文件的最后若干行，最多读取文件末尾的 256KB
+// 参数: path (绝对路径)、lines (默认 100，最多 10000)
+func tailFile(ctx context.Context, args Args) (interface{}, error) {
+	path, err := absPath(args)
+	if err != nil {
+		return nil, err
+	}
+	n, err := args.Int("lines", 100, 1, 10000)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if !info.Mode().IsRegular() {
+		return nil, fmt.Errorf("%s is not a regular file", path)
+	}
+
+	offset := max(info.Size()-maxTailBytes, 0)
+	data, err := io.ReadAll(io.NewSectionReader(f, offset, info.Size()-offset))
+	if err != nil {
+		return nil, err
+	}
+	data = bytes.TrimSuffix(data, []byte("\n"))
+	lines := bytes.Split(data, []byte("\n"))
+	truncated := false
+	if offset > 0 {
+		// 第一行可能不完整
+		lines = lines[1:]
+		truncated = true
+	}
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+		truncated = true
+	}
+
+	result := FileTail{Path: path, Size: info.Size(), Lines: make([]string, 0, len(lines)), Truncated: truncated}
+	if len(data) > 0 {
+		for _, line := range lines {
+			result.Lines = append(result.Lines, string(bytes.TrimSuffix(line, []byte("\r"))))
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,187 @@
+package actions
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxProbeBodyBytes 是 net.http_probe 结果中保留的响应体字节数
+const maxProbeBodyBytes = 1024
+
+func init() {
+	Register("net.tcp_check", tcpCheck)
+	Register("net.dns_lookup", dnsLookup)
+	Register("net.http_probe", httpProbe)
+}
+
+// TCPCheck 是 net.tcp_check 的结果
+type TCPCheck struct {
+	Address   string `json:"address"`
+	Reachable bool   `json:"reachable"`
+	LatencyMs int64  `json:"latency_ms"`
+	Error     string `json:"error,omitempty"`
+}
+
+// DNSLookup 是 net.dns_lookup 的结果，Records 是解析到的地址或记录
+type DNSLookup struct {
+	Name     string   `json:"name"`
+	Type     string   `json:"type"`
+	Resolved bool     `json:"resolved"`
+	Records  []string `json:"records"`
+	Error    string   `json:"error,omitempty"`
+}
+
+// HTTPProbe 是 net.http_probe 的结果，OK 表示状态码符合预期 (未指定 expect_status 时为 2xx 或 3xx)
+type HTTPProbe struct {
+	URL         string `json:"url"`
+	StatusCode  int    `json:"status_code"`
+	OK          bool   `json:"ok"`
+	LatencyMs   int64  `json:"latency_ms"`
+	BodyExcerpt string `json:"body_excerpt,omitempty"`
+	BodyMatched *bool  `json:"body_matched,omitempty"` // 指定了 contains 时，响应体是否包含该字符串
+	Error       string `json:"error,omitempty"`
+}
+
+// tcpCheck 检查 TCP 端口是否可以连接
+// 参数: host、port、timeout (默认 5s)
+func tcpCheck(ctx context.Context, args Args) (interface{}, error) {
+	host, err := args.Required("host")
+	if err != nil {
+		return nil, err
+	}
+	port, err := args.Int("port", 0, 1, 65535)
+	if err != nil {
+		return nil, err
+	}
+	if port == 0 {
+		return nil, fmt.Errorf("%w: port is required", ErrInvalidArgs)
+	}
+	timeout, err := args.Duration("timeout", 5*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	result := TCPCheck{Address: net.JoinHostPort(host, strconv.Itoa(port))}
+	dialer := net.Dialer{Timeout: timeout}
+	start := time.Now()
+	conn, err := dialer.DialContext(ctx, "tcp", result.Address)
+	result.LatencyMs = time.Since(start).Milliseconds()
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		result.Error = err.Error()
+		return result, nil
+	}
+	conn.Close()
+	result.Reachable = true
+	return result, nil
+}
+
+// dnsLookup 解析域名
+// 参数: name、type ("host" 默认，即 A 和 AAAA 记录；"cname"、"mx"、"txt")
+func dnsLookup(ctx context.Context, args Args) (interface{}, error) {
+	name, err := args.Required("name")
+	if err != nil {
+		return nil, err
+	}
+	recordType := strings.ToLower(args.String("type", "host"))
+
+	result := DNSLookup{Name: name, Type: recordType, Records: []string{}}
+	resolver := net.DefaultResolver
+	switch recordType {
+	case "host":
+		result.Records, err = resolver.LookupHost(ctx, name)
+	case "cname":
+		var cname string
+		cname, err = resolver.LookupCNAME(ctx, name)
+		if err == nil {
+			result.Records = []string{cname}
+		}
+	case "mx":
+		var records []*net.MX
+		records, err = resolver.LookupMX(ctx, name)
+		for _, mx := range records {
+			result.Records = append(result.Records, fmt.Sprintf("%d %s", mx.Pref, mx.Host))
+		}
+	case "txt":
+		result.Records, err = resolver.LookupTXT(ctx, name)
+	default:
+		return nil, fmt.Errorf("%w: type must be host, cname, mx or txt", ErrInvalidArgs)
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		result.Records = []string{}
+		result.Error = err.Error()
+		return result, nil
+	}
+	result.Resolved = len(result.Records) > 0
+	return result, nil
+}
+
+// httpProbe 发送 HTTP 请求检查服务是否可用
+// 参数: url (http 或 https)、method ("GET" 默认或 "HEAD")、expect_status、contains、timeout (默认 10s)
+func httpProbe(ctx context.Context, args Args) (interface{}, error) {
+	rawURL, err := args.Required("url")
+	if err != nil {
+		return nil, err
+	}
+	if u, err := url.Parse(rawURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("%w: url must be an absolute http or https url", ErrInvalidArgs)
+	}
+	method := strings.ToUpper(args.String("method", http.MethodGet))
+	if method != http.MethodGet && method != http.MethodHead {
+		return nil, fmt.Errorf("%w: method must be GET or HEAD", ErrInvalidArgs)
+	}
+	expectStatus, err := args.Int("expect_status", 0, 100, 599)
+	if err != nil {
+		return nil, err
+	}
+	timeout, err := args.Duration("timeout", 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	contains := args.String("contains", "")
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidArgs, err)
+	}
+
+	result := HTTPProbe{URL: rawURL}
+	start := time.Now()
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		result.LatencyMs = time.Since(start).Milliseconds()
+		result.Error = err.Error()
+		return result, nil
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024*1024))
+	result.LatencyMs = time.Since(start).Milliseconds()
+
+	result.StatusCode = resp.StatusCode
+	if expectStatus != 0 {
+		result.OK = resp.StatusCode == expectStatus
+	} else {
+		result.OK = resp.StatusCode >= 200 && resp.StatusCode < 400
+	}
+	result.BodyExcerpt = string(body[:min(len(body), maxProbeBodyBytes)])
+	if contains != "" {
+		matched := strings.Contains(string(body), contains)
+		result.BodyMatched = &matched
+		result.OK = result.OK && matched
+	}
+	return result, nil
+}
@@ -0,0 +1,89 @@
+package actions
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/shirou/gopsutil/v3/process"
+)
+
+func init() {
+	Register("process.list", listProcesses)
+}
+
+// ProcessInfo 是 process.list 返回的单个进程
+type ProcessInfo struct {
+	PID           int32   `json:"pid"`
+	Name          string  `json:"name"`
+	Username      string  `json:"username"`
+	Status        string  `json:"status"`
+	CPUPercent    float64 `json:"cpu_percent"` // 进程启动以来的平均 CPU 使用率
+	MemoryPercent float32 `json:"memory_percent"`
+	RSSBytes      uint64  `json:"rss_bytes"`
+	Cmdline       string  `json:"cmdline"`
+}
+
+// ProcessList 是 process.list 的结果，Count 是过滤后 (截断前) 的进程数
+type ProcessList struct {
+	Count     int           `json:"count"`
+	Processes []ProcessInfo `json:"processes"`
+}
+
+// listProcesses 列出进程
+// 参数: name (按进程名或命令行的子串过滤)、sort ("cpu" 默认或 "memory"，降序)、limit (默认 20，最多 500)
+func listProcesses(ctx context.Context, args Args) (interface{}, error) {
+	filter := args.String("name", "")
+	sortBy := args.String("sort", "cpu")
+	if sortBy != "cpu" && sortBy != "memory" {
+		return nil, fmt.Errorf("%w: sort must be cpu or memory", ErrInvalidArgs)
+	}
+	limit, err := args.Int("limit", 20, 1, 500)
+	if err != nil {
+		return nil, err
+	}
+
+	procs, err := process.ProcessesWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]ProcessInfo, 0, len(procs))
+	for _, p := range procs {
+		// 进程随时可能退出，读取失败的字段保持零值
+		name, err := p.NameWithContext(ctx)
+		if err != nil {
+			continue
+		}
+		cmdline, _ := p.CmdlineWithContext(ctx)
+		if filter != "" && !strings.Contains(name, filter) && !strings.Contains(cmdline, filter) {
+			continue
+		}
+		info := ProcessInfo{PID: p.Pid, Name: name, Cmdline: cmdline}
+		info.Username, _ = p.UsernameWithContext(ctx)
+		if status, err := p.StatusWithContext(ctx); err == nil && len(status) > 0 {
+			info.Status = status[0]
+		}
+		info.CPUPercent, _ = p.CPUPercentWithContext(ctx)
+		info.MemoryPercent, _ = p.MemoryPercentWithContext(ctx)
+		if mem, err := p.MemoryInfoWithContext(ctx); err == nil {
+			info.RSSBytes = mem.RSS
+		}
+		infos = append(infos, info)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(infos, func(i, j int) bool {
+		if sortBy == "memory" {
+			return infos[i].RSSBytes > infos[j].RSSBytes
+		}
+		return infos[i].CPUPercent > infos[j].CPUPercent
+	})
+	result := ProcessList{Count: len(infos), Processes: infos}
+	if len(infos) > limit {
+		result.Processes = infos[:limit]
+	}
+	return result, nil
+}
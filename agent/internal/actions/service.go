@@ -0,0 +1,74 @@
+package actions
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+)
+
+func init() {
+	Register("service.status", serviceStatus)
+	Register("service.restart", serviceRestart)
+}
+
+// ServiceStatus 是 service.status 和 service.restart 的结果
+// Linux 上 State 是 systemd 的 ActiveState (例如 "active"、"failed")，Windows 上是服务状态 (例如 "running"、"stopped")
+type ServiceStatus struct {
+	Name        string `json:"name"`
+	Exists      bool   `json:"exists"`
+	Running     bool   `json:"running"`
+	State       string `json:"state"`
+	SubState    string `json:"sub_state,omitempty"`
+	MainPID     int    `json:"main_pid"`
+	Description string `json:"description,omitempty"`
+	Restarted   bool   `json:"restarted,omitempty"`
+}
+
+// serviceNamePattern 限制服务名的字符，避免被解释为命令行选项
+var serviceNamePattern = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9@._:\-]*$`)
+
+func serviceName(args Args) (string, error) {
+	name, err := args.Required("name")
+	if err != nil {
+		return "", err
+	}
+	if !serviceNamePattern.MatchString(name) {
+		return "", fmt.Errorf("%w: invalid service name %q", ErrInvalidArgs, name)
+	}
+	return name, nil
+}
+
+// serviceStatus 查询服务状态，服务不存在时 Exists 为 false
+// 参数: name
+func serviceStatus(ctx context.Context, args Args) (interface{}, error) {
+	name, err := serviceName(args)
+	if err != nil {
+		return nil, err
+	}
+	return queryService(ctx, name)
+}
+
+// serviceRestart 重启服务，返回重启后的状态，服务不存在或重启失败时返回错误
+// 参数: name
+func serviceRestart(ctx context.Context, args Args) (interface{}, error) {
+	name, err := serviceName(args)
+	if err != nil {
+		return nil, err
+	}
+	status, err := queryService(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if !status.Exists {
+		return nil, fmt.Errorf("service %s does not exist", name)
+	}
+	if err := restartService(ctx, name); err != nil {
+		return nil, err
+	}
+	status, err = queryService(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	status.Restarted = true
+	return status, nil
+}
@@ -0,0 +1,47 @@
+package actions
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// queryService 通过 systemctl show 查询 systemd 服务的状态，没有 systemd 时返回 ErrUnsupported
+func queryService(ctx context.Context, name string) (*ServiceStatus, error) {
+	if _, err := exec.LookPath("systemctl"); err != nil {
+		return nil, ErrUnsupported
+	}
+	out, err := exec.CommandContext(ctx, "systemctl", "show", name,
+		"--property=LoadState,ActiveState,SubState,MainPID,Description").Output()
+	if err != nil {
+		return nil, fmt.Errorf("systemctl show %s: %w", name, err)
+	}
+
+	props := make(map[string]string)
+	for _, line := range strings.Split(string(out), "\n") {
+		if key, value, ok := strings.Cut(line, "="); ok {
+			props[key] = value
+		}
+	}
+	status := &ServiceStatus{
+		Name:        name,
+		Exists:      props["LoadState"] != "not-found",
+		State:       props["ActiveState"],
+		SubState:    props["SubState"],
+		Description: props["Description"],
+	}
+	status.MainPID, _ = strconv.Atoi(props["MainPID"])
+	status.Running = status.State == "active"
+	return status, nil
+}
+
+// restartService 通过 systemctl restart 重启 systemd 服务
+func restartService(ctx context.Context, name string) error {
+	out, err := exec.CommandContext(ctx, "systemctl", "restart", name).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("systemctl restart %s: %w: %s", name, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
@@ -0,0 +1,13 @@
+//go:build !linux && !windows
+
+package actions
+
+import "context"
+
+func queryService(ctx context.Context, name string) (*ServiceStatus, error) {
+	return nil, ErrUnsupported
+}
+
+func restartService(ctx context.Context, name string) error {
+	return ErrUnsupported
+}
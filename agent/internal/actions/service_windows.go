@@ -0,0 +1,83 @@
+package actions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/winservices"
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+var serviceStates = map[svc.State]string{
+	svc.Stopped:         "stopped",
+	svc.StartPending:    "start_pending",
+	svc.StopPending:     "stop_pending",
+	svc.Running:         "running",
+	svc.ContinuePending: "continue_pending",
+	svc.PausePending:    "pause_pending",
+	svc.Paused:          "paused",
+}
+
+// queryService 通过服务控制管理器查询 Windows 服务的状态
+func queryService(ctx context.Context, name string) (*ServiceStatus, error) {
+	service, err := winservices.NewService(name)
+	if err != nil {
+		return nil, err
+	}
+	status, err := service.QueryStatusWithContext(ctx)
+	if err != nil {
+		if errors.Is(err, windows.ERROR_SERVICE_DOES_NOT_EXIST) {
+			return &ServiceStatus{Name: name}, nil
+		}
+		return nil, err
+	}
+	result := &ServiceStatus{
+		Name:    name,
+		Exists:  true,
+		State:   serviceStates[status.State],
+		MainPID: int(status.Pid),
+		Running: status.State == svc.Running,
+	}
+	if config, err := service.QueryServiceConfigWithContext(ctx); err == nil {
+		result.Description = config.DisplayName
+	}
+	return result, nil
+}
+
+// restartService 停止并重新启动 Windows 服务
+func restartService(ctx context.Context, name string) error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect()
+	s, err := m.OpenService(name)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	status, err := s.Control(svc.Stop)
+	if err != nil && !errors.Is(err, windows.ERROR_SERVICE_NOT_ACTIVE) {
+		return fmt.Errorf("stop service %s: %w", name, err)
+	}
+	for err == nil && status.State != svc.Stopped {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(500 * time.Millisecond):
+		}
+		status, err = s.Query()
+		if err != nil {
+			return err
+		}
+	}
+	if err := s.Start(); err != nil {
+		return fmt.Errorf("start service %s: %w", name, err)
+	}
+	return nil
+}
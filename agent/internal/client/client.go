@@ -7,13 +7,19 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"sync/atomic"
 	"time"
 )
 
+// successCode 与后端 internal/api/response.go 中的 SuccessCode 一致
+const successCode = 20000
+
 // APIClient 封装了与后端 API 的交互
 type APIClient struct {
 	httpClient *http.Client
 	baseURL    string
+	streaming  atomic.Bool // 持久连接是否可用，见 OpenStream
 }
 
 // NewAPIClient 创建一个新的 API 客户端
@@ -24,13 +30,14 @@ func NewAPIClient(baseURL string) *APIClient {
 	}
 }
 
-// Register 注册 Agent 到后端
-func (c *APIClient) Register(hostname, ip, os string) (string, error) {
+// Register 注册 Agent 到后端，capabilities 是 Agent 支持的任务执行方式 (Task.Kind)
+func (c *APIClient) Register(hostname, ip, os string, capabilities []string) (string, error) {
 	// 这个结构体应该与后端 api/types.go 中的 RegisterAgentRequest 一致
-	reqBody, _ := json.Marshal(map[string]string{
-		"hostname":   hostname,
-		"ip_address": ip,
-		"os":         os,
+	reqBody, _ := json.Marshal(map[string]interface{}{
+		"hostname":     hostname,
+		"ip_address":   ip,
+		"os":           os,
+		"capabilities": capabilities,
 	})
 
 	resp, err := c.httpClient.Post(c.baseURL+"/api/v1/agent/register", "application/json", bytes.NewBuffer(reqBody))
@@ -43,19 +50,43 @@ func (c *APIClient) Register(hostname, ip, os string) (string, error) {
 		return "", fmt.Errorf("server returned non-OK status: %s", resp.Status)
 	}
 
-	// 这个结构体应该与后端 api/types.go 中的 RegisterAgentResponse 一致
+	// 响应数据与后端 api/types.go 中的 RegisterAgentResponse 一致
 	var respBody struct {
-		AgentID string `json:"agent_id"`
+		Data struct {
+			AgentID string `json:"agent_id"`
+		} `json:"data"`
 	}
 	if err := json.NewDecoder(resp.Body).Decode(&respBody); err != nil {
 		return "", err
 	}
-	return respBody.AgentID, nil
+	if respBody.Data.AgentID == "" {
+		return "", fmt.Errorf("server returned no agent id")
+	}
+	return respBody.Data.AgentID, nil
+}
+
+// UpdateCapabilities 更新已注册 Agent 支持的任务执行方式，Agent 每次启动时上报 (插件可能有增减)
+func (c *APIClient) UpdateCapabilities(agentID string, capabilities []string) error {
+	reqBody, _ := json.Marshal(map[string]interface{}{
+		"capabilities": capabilities,
+	})
+	req, err := http.NewRequest(http.MethodPut, c.baseURL+"/api/v1/agent/"+url.PathEscape(agentID)+"/capabilities", bytes.NewReader(reqBody))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return decodeResponse(resp.Body, "update capabilities", nil)
 }
 
 // SendHeartbeat 发送心跳
-func (c *APIClient) SendHeartbeat(agentID string) error {
-	reqBody, _ := json.Marshal(map[string]string{"agent_id": agentID})
+// capacity 不为空时一起上报 Agent 当前的任务执行容量
+func (c *APIClient) SendHeartbeat(agentID string, capacity *Capacity) error {
+	reqBody, _ := json.Marshal(map[string]interface{}{"agent_id": agentID, "capacity": capacity})
 	resp, err := c.httpClient.Post(c.baseURL+"/api/v1/agent/heartbeat", "application/json", bytes.NewBuffer(reqBody))
 	if err != nil {
 		return err
@@ -67,9 +98,10 @@ func (c *APIClient) SendHeartbeat(agentID string) error {
 	return nil
 }
 
-// FetchTasks 通过长轮询获取任务
-func (c *APIClient) FetchTasks(ctx context.Context, agentID string) (*Task, error) {
-	url := fmt.Sprintf("%s/api/v1/agent/tasks?agent_id=%s", c.baseURL, agentID)
+// FetchTasks 通过长轮询获取任务，一次最多获取 max 个 (服务端可能进一步限制)
+// 长轮询超时没有任务时返回空切片
+func (c *APIClient) FetchTasks(ctx context.Context, agentID string, max int) ([]Task, error) {
+	url := fmt.Sprintf("%s/api/v1/agent/tasks?agent_id=%s&max=%d", c.baseURL, agentID, max)
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, err
@@ -81,18 +113,25 @@ func (c *APIClient) FetchTasks(ctx context.Context, agentID string) (*Task, erro
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode == http.StatusNoContent {
-		return nil, nil // 超时，没有任务
-	}
 	if resp.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("fetch tasks failed with status: %s", resp.Status)
 	}
 
-	var task Task
-	if err := json.NewDecoder(resp.Body).Decode(&task); err != nil {
+	// 后端统一使用 {code, msg, data} 的响应结构
+	var respBody struct {
+		Code int    `json:"code"`
+		Msg  string `json:"msg"`
+		Data struct {
+			Tasks []Task `json:"tasks"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&respBody); err != nil {
 		return nil, err
 	}
-	return &task, nil
+	if respBody.Code != successCode {
+		return nil, fmt.Errorf("fetch tasks failed with code %d: %s", respBody.Code, respBody.Msg)
+	}
+	return respBody.Data.Tasks, nil
 }
 
 // PostResult 上报任务结果
@@ -110,20 +149,127 @@ func (c *APIClient) PostResult(result TaskResult) error {
 	return nil
 }
 
+// PostOutput 上报任务执行过程中的输出，持久连接不可用时使用
+func (c *APIClient) PostOutput(agentID string, chunks []OutputChunk) error {
+	reqBody, _ := json.Marshal(map[string]interface{}{
+		"agent_id": agentID,
+		"chunks":   chunks,
+	})
+	resp, err := c.httpClient.Post(c.baseURL+"/api/v1/agent/tasks/output", "application/json", bytes.NewBuffer(reqBody))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var respBody struct {
+		Code int    `json:"code"`
+		Msg  string `json:"msg"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&respBody); err != nil {
+		return err
+	}
+	if respBody.Code != successCode {
+		return fmt.Errorf("post output failed with code %d: %s", respBody.Code, respBody.Msg)
+	}
+	return nil
+}
+
 // --- 在 client 包内部定义与后端 API 对应的类型 ---
 // 这些结构体应该与后端 internal/core/engine/model.go 中的 Task 和 TaskResult 结构一致
 type Task struct {
 	ID         string `json:"ID"`
 	WorkflowID string `json:"WorkflowID"`
 	Type       string `json:"Type"`
+	Kind       string `json:"Kind,omitempty"` // "command" (默认)、"script"、"file_push"、"file_pull" 或 "action"
 	Command    string `json:"Command"`
+	Priority   int    `json:"Priority"`
+	// NotBefore 和 ExpiresAt 由后端在分发时检查，Agent 只做记录
+	NotBefore *time.Time `json:"NotBefore,omitempty"`
+	ExpiresAt *time.Time `json:"ExpiresAt,omitempty"`
+	// Timeout 是执行超时时间 (秒)，0 表示使用 Agent 的默认值
+	Timeout int `json:"Timeout,omitempty"`
+
+	// 以下是可选的执行参数，无法满足时拒绝执行 (TaskStatusRejected)
+	Env         map[string]string `json:"Env,omitempty"`
+	WorkDir     string            `json:"WorkDir,omitempty"`
+	Stdin       string            `json:"Stdin,omitempty"`
+	Interpreter string            `json:"Interpreter,omitempty"` // "sh" (默认)、"bash"、"python3" 或 "direct"
+	RunAs       string            `json:"RunAs,omitempty"`
+
+	// 脚本任务的脚本名称、内容和内容的 SHA-256，校验不通过时拒绝执行
+	ScriptName   string `json:"ScriptName,omitempty"`
+	Script       string `json:"Script,omitempty"`
+	ScriptSHA256 string `json:"ScriptSHA256,omitempty"`
+
+	// File 是文件传输任务的参数，文件内容通过 DownloadFileChunk / UploadFileChunk 分块传输
+	File *FileTransfer `json:"File,omitempty"`
+
+	// 内置动作任务的动作名称和参数，见 agent/internal/actions
+	Action string            `json:"Action,omitempty"`
+	Args   map[string]string `json:"Args,omitempty"`
+}
+
+// FileTransfer 与后端 engine.FileTransfer 一致
+type FileTransfer struct {
+	FileID  string `json:"FileID,omitempty"`
+	SHA256  string `json:"SHA256,omitempty"`
+	Size    int64  `json:"Size,omitempty"`
+	Path    string `json:"Path"`
+	Mode    string `json:"Mode,omitempty"`
+	Owner   string `json:"Owner,omitempty"`
+	Group   string `json:"Group,omitempty"`
+	MaxSize int64  `json:"MaxSize,omitempty"`
 }
 
+// 任务的执行方式，见 Task.Kind
+const (
+	TaskKindCommand  = "command"
+	TaskKindScript   = "script"
+	TaskKindFilePush = "file_push"
+	TaskKindFilePull = "file_pull"
+	TaskKindAction   = "action"
+)
+
+// 任务结果的状态，正常执行结束 (无论成功与否) 时为空
+const (
+	// TaskStatusTimedOut 表示任务执行超时，命令及其子进程已被终止
+	TaskStatusTimedOut = "timed_out"
+	// TaskStatusRejected 表示 Agent 无法满足任务的执行参数，任务没有被执行
+	TaskStatusRejected = "rejected"
+)
+
 type TaskResult struct {
 	TaskID   string `json:"task_id"`
 	AgentID  string `json:"agent_id"`
 	Success  bool   `json:"success"`
-	Output   string `json:"output"`
 	Error    string `json:"error"`
 	ExitCode int    `json:"exit_code"`
+	// Stdout 和 Stderr 超过 config.MaxOutputBytes 时保留开头和结尾，中间插入截断标记
+	// StdoutBytes 和 StderrBytes 是截断前的原始字节数
+	Stdout      string `json:"stdout"`
+	Stderr      string `json:"stderr"`
+	StdoutBytes int64  `json:"stdout_bytes"`
+	StderrBytes int64  `json:"stderr_bytes"`
+	// Status 为空表示正常执行结束 (无论成功与否)，"timed_out" 表示执行超时
+	Status string `json:"status,omitempty"`
+	// Data 是内置动作任务返回的结构化结果 (JSON)
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+// Capacity 是 Agent 的任务执行容量，随心跳上报，后端据此决定最多下发多少任务
+type Capacity struct {
+	MaxConcurrency int `json:"max_concurrency"`
+	QueueSize      int `json:"queue_size"`
+	Running        int `json:"running"`
+	Queued         int `json:"queued"`
+	// Available 是还能接收的任务数: MaxConcurrency + QueueSize - Running - Queued
+	Available int `json:"available"`
+}
+
+// OutputChunk 是任务执行过程中的一段输出，与后端 engine.TaskOutputChunk 一致
+type OutputChunk struct {
+	TaskID string `json:"task_id"`
+	Seq    int    `json:"seq"`    // 从 0 开始递增，stdout 和 stderr 共用一个序列
+	Stream string `json:"stream"` // "stdout" 或 "stderr"
+	Data   []byte `json:"data"`
 }
@@ -0,0 +1,124 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// chunkSHA256Header 与后端 api.ChunkSHA256Header 一致
+const chunkSHA256Header = "X-Chunk-SHA256"
+
+// DownloadFileChunk 下载 file_push 任务的文件中从 offset 开始、最多 length 字节的分块，并校验分块的 SHA-256
+func (c *APIClient) DownloadFileChunk(ctx context.Context, agentID, taskID string, offset, length int64) ([]byte, error) {
+	query := url.Values{}
+	query.Set("agent_id", agentID)
+	query.Set("offset", strconv.FormatInt(offset, 10))
+	query.Set("length", strconv.FormatInt(length, 10))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.fileURL(taskID)+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	// 失败时后端返回 JSON 响应
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "application/json") {
+		return nil, decodeResponse(resp.Body, "download file chunk", nil)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("download file chunk failed with status: %s", resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	if hex.EncodeToString(sum[:]) != resp.Header.Get(chunkSHA256Header) {
+		return nil, fmt.Errorf("file chunk at offset %d failed checksum verification", offset)
+	}
+	return data, nil
+}
+
+// UploadFileChunk 上传 file_pull 任务的文件中从 offset 开始的分块，后端按分块的 SHA-256 校验
+func (c *APIClient) UploadFileChunk(ctx context.Context, agentID, taskID string, offset int64, data []byte) error {
+	query := url.Values{}
+	query.Set("agent_id", agentID)
+	query.Set("offset", strconv.FormatInt(offset, 10))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, c.fileURL(taskID)+"?"+query.Encode(), bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(data)
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set(chunkSHA256Header, hex.EncodeToString(sum[:]))
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return decodeResponse(resp.Body, "upload file chunk", nil)
+}
+
+// CompleteFileUpload 通知后端 file_pull 任务的所有分块已上传，后端校验总大小和 SHA-256 后返回保存的文件 ID
+func (c *APIClient) CompleteFileUpload(ctx context.Context, agentID, taskID, name, path string, size int64, checksum string) (string, error) {
+	reqBody, _ := json.Marshal(map[string]interface{}{
+		"agent_id": agentID,
+		"name":     name,
+		"path":     path,
+		"size":     size,
+		"sha256":   checksum,
+	})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.fileURL(taskID)+"/complete", bytes.NewReader(reqBody))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var file struct {
+		ID string `json:"id"`
+	}
+	if err := decodeResponse(resp.Body, "complete file upload", &file); err != nil {
+		return "", err
+	}
+	return file.ID, nil
+}
+
+func (c *APIClient) fileURL(taskID string) string {
+	return c.baseURL + "/api/v1/agent/tasks/" + url.PathEscape(taskID) + "/file"
+}
+
+// decodeResponse 解析后端的统一响应，code 不是成功时返回错误，data 不为 nil 时解析响应数据
+func decodeResponse(body io.Reader, action string, data interface{}) error {
+	var respBody struct {
+		Code int             `json:"code"`
+		Msg  string          `json:"msg"`
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.NewDecoder(body).Decode(&respBody); err != nil {
+		return err
+	}
+	if respBody.Code != successCode {
+		return fmt.Errorf("%s failed with code %d: %s", action, respBody.Code, respBody.Msg)
+	}
+	if data != nil {
+		return json.Unmarshal(respBody.Data, data)
+	}
+	return nil
+}
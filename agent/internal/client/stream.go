@@ -0,0 +1,121 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+// 持久连接上的消息类型，与后端 internal/api/handler_stream.go 一致
+const (
+	StreamMsgTasks     = "tasks"
+	StreamMsgAck       = "ack"
+	StreamMsgCancel    = "cancel"
+	StreamMsgHeartbeat = "heartbeat"
+	StreamMsgResult    = "result"
+	StreamMsgResultAck = "result_ack"
+	StreamMsgOutput    = "output"
+	StreamMsgCapacity  = "capacity"
+)
+
+// streamWriteTimeout 是单条消息的写超时
+const streamWriteTimeout = 10 * time.Second
+
+// StreamMessage 是持久连接上传输的消息，这个结构体应该与后端 api.StreamMessage 一致
+type StreamMessage struct {
+	Type    string        `json:"type"`
+	Tasks   []Task        `json:"tasks,omitempty"`
+	TaskIDs []string      `json:"task_ids,omitempty"`
+	TaskID  string        `json:"task_id,omitempty"`
+	Reason  string        `json:"reason,omitempty"`
+	Result  *TaskResult   `json:"result,omitempty"`
+	Output  []OutputChunk `json:"output,omitempty"`
+	// Capacity 随心跳和 capacity 消息上报，后端据此决定最多下发多少任务
+	Capacity *Capacity `json:"capacity,omitempty"`
+}
+
+// Stream 是 Agent 与后端之间的持久连接 (WebSocket)
+// 后端通过它下发任务和取消指令，Agent 通过它确认任务、发送心跳和上报结果
+type Stream struct {
+	conn   *websocket.Conn
+	client *APIClient
+
+	writeMu   sync.Mutex // websocket 连接不支持并发写
+	closeOnce sync.Once
+}
+
+// OpenStream 建立持久连接，一次最多接收 max 个任务 (服务端可能进一步限制)
+// 后端不支持持久连接时返回错误，调用方应回退到长轮询
+func (c *APIClient) OpenStream(ctx context.Context, agentID string, max int) (*Stream, error) {
+	wsURL, err := streamURL(c.baseURL)
+	if err != nil {
+		return nil, err
+	}
+	wsURL = fmt.Sprintf("%s/api/v1/agent/stream?agent_id=%s&max=%d", wsURL, url.QueryEscape(agentID), max)
+
+	wsConfig, err := websocket.NewConfig(wsURL, c.baseURL)
+	if err != nil {
+		return nil, err
+	}
+	dialCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	conn, err := wsConfig.DialContext(dialCtx)
+	if err != nil {
+		return nil, err
+	}
+
+	c.streaming.Store(true)
+	return &Stream{conn: conn, client: c}, nil
+}
+
+// Streaming 报告当前是否有可用的持久连接，此时心跳通过持久连接发送
+func (c *APIClient) Streaming() bool {
+	return c.streaming.Load()
+}
+
+// Send 发送一条消息，可以并发调用
+func (s *Stream) Send(msg StreamMessage) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	s.conn.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+	return websocket.JSON.Send(s.conn, &msg)
+}
+
+// Receive 阻塞直到收到下一条消息，连接断开时返回错误
+func (s *Stream) Receive() (StreamMessage, error) {
+	var msg StreamMessage
+	err := websocket.JSON.Receive(s.conn, &msg)
+	return msg, err
+}
+
+// Close 关闭连接，可以重复调用
+func (s *Stream) Close() error {
+	var err error
+	s.closeOnce.Do(func() {
+		s.client.streaming.Store(false)
+		err = s.conn.Close()
+	})
+	return err
+}
+
+// streamURL 把后端的 http(s) 地址转换为对应的 ws(s) 地址
+func streamURL(baseURL string) (string, error) {
+	u, err := url.Parse(strings.TrimRight(baseURL, "/"))
+	if err != nil {
+		return "", err
+	}
+	switch u.Scheme {
+	case "http":
+		u.Scheme = "ws"
+	case "https":
+		u.Scheme = "wss"
+	default:
+		return "", fmt.Errorf("unsupported backend URL scheme: %q", u.Scheme)
+	}
+	return u.String(), nil
+}
@@ -12,16 +12,47 @@ const ConfigFileName = "agent_config.json"
 type Config struct {
 	BackendURL string `json:"backend_url"`
 	AgentID    string `json:"agent_id"`
+	// MaxTasksPerPoll 是一次长轮询最多获取的任务数，后端可能进一步限制
+	MaxTasksPerPoll int `json:"max_tasks_per_poll"`
+	// Transport 是接收任务的方式: "auto" 优先使用持久连接，不可用时回退到长轮询；
+	// "stream" 只使用持久连接；"poll" 只使用长轮询
+	Transport string `json:"transport"`
+	// MaxOutputBytes 是任务结果中 stdout 和 stderr 各自保留的最大字节数，超出时保留开头和结尾
+	MaxOutputBytes int `json:"max_output_bytes"`
+	// MaxFileBytes 是文件传输任务 (file_push / file_pull) 允许传输的最大文件字节数
+	MaxFileBytes int64 `json:"max_file_bytes"`
+	// PluginDir 是外部执行插件所在的目录，默认是配置目录下的 plugins
+	PluginDir string `json:"plugin_dir"`
+	// MaxConcurrency 是同时执行的最大任务数
+	MaxConcurrency int `json:"max_concurrency"`
+	// KindConcurrency 按执行方式 (例如 "command"、"file_push") 单独限制同时执行的任务数，0 或未配置表示只受 MaxConcurrency 限制
+	KindConcurrency map[string]int `json:"kind_concurrency"`
+	// QueueSize 是本地排队等待执行的最大任务数，执行槽位和队列都占满时 Agent 停止接收任务
+	QueueSize int `json:"queue_size"`
 	// 未来可以添加更多配置, 如日志级别等
 }
 
+// 接收任务的方式，见 Config.Transport
+const (
+	TransportAuto   = "auto"
+	TransportStream = "stream"
+	TransportPoll   = "poll"
+)
+
 var Cfg *Config
 
 // LoadConfig 从文件加载配置，如果文件不存在则使用默认值
 func LoadConfig(configDir string) error {
 	Cfg = &Config{
 		// 设置一个默认的后端地址
-		BackendURL: "http://localhost:8080",
+		BackendURL:      "http://localhost:8080",
+		MaxTasksPerPoll: 5,
+		Transport:       TransportAuto,
+		MaxOutputBytes:  64 * 1024,
+		MaxFileBytes:    100 * 1024 * 1024,
+		PluginDir:       filepath.Join(configDir, "plugins"),
+		MaxConcurrency:  4,
+		QueueSize:       16,
 	}
 
 	configFile := filepath.Join(configDir, ConfigFileName)
@@ -0,0 +1,50 @@
+package executor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/GenJi77JYXC/intelligent-pioneer/agent/internal/actions"
+	"github.com/GenJi77JYXC/intelligent-pioneer/agent/internal/client"
+)
+
+// executeAction 执行内置动作任务，动作的结果以 JSON 放入 TaskResult.Data，格式化后的 JSON 同时作为 Stdout 便于查看
+// 未知的动作、无效的参数或当前平台不支持的动作会被拒绝
+func executeAction(ctx context.Context, req *Request) client.TaskResult {
+	agentID, task := req.AgentID, req.Task
+	log.Printf("Executing action: %s", task.Action)
+	handler, ok := actions.Lookup(task.Action)
+	if !ok {
+		return rejectTask(agentID, task, fmt.Errorf("unknown action %q", task.Action))
+	}
+
+	out, err := handler(ctx, task.Args)
+	if errors.Is(err, actions.ErrInvalidArgs) || errors.Is(err, actions.ErrUnsupported) {
+		return rejectTask(agentID, task, err)
+	}
+	if err != nil {
+		log.Printf("Action %s failed: %v", task.Action, err)
+		return failTask(ctx, req, err)
+	}
+
+	data, err := json.Marshal(out)
+	if err != nil {
+		return failTask(ctx, req, fmt.Errorf("cannot encode action result: %w", err))
+	}
+	pretty, _ := json.MarshalIndent(out, "", "  ")
+	stdout := newCappedBuffer(maxOutputBytes())
+	stdout.Write(append(pretty, '\n'))
+
+	log.Printf("Action %s executed successfully.", task.Action)
+	return client.TaskResult{
+		TaskID:      task.ID,
+		AgentID:     agentID,
+		Success:     true,
+		Stdout:      stdout.String(),
+		StdoutBytes: stdout.Len(),
+		Data:        data,
+	}
+}
@@ -0,0 +1,69 @@
+package executor
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/GenJi77JYXC/intelligent-pioneer/agent/internal/config"
+)
+
+// defaultMaxOutputBytes 是配置中没有指定时 stdout 和 stderr 各自保留的最大字节数
+const defaultMaxOutputBytes = 64 * 1024
+
+func maxOutputBytes() int {
+	if config.Cfg != nil && config.Cfg.MaxOutputBytes > 0 {
+		return config.Cfg.MaxOutputBytes
+	}
+	return defaultMaxOutputBytes
+}
+
+// cappedBuffer 保存一路输出的开头和结尾各一半，内存占用不超过上限，同时记录原始字节数
+type cappedBuffer struct {
+	head  []byte
+	tail  []byte // 最近写入的数据，长度超过 tailLimit 的两倍时才整理，避免每次写入都移动数据
+	limit int
+	total int64
+}
+
+func newCappedBuffer(limit int) *cappedBuffer {
+	return &cappedBuffer{limit: limit}
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	b.total += int64(len(p))
+	headLimit := b.limit / 2
+	data := p
+	if n := min(headLimit-len(b.head), len(data)); n > 0 {
+		b.head = append(b.head, data[:n]...)
+		data = data[n:]
+	}
+	if len(data) > 0 {
+		tailLimit := b.limit - headLimit
+		b.tail = append(b.tail, data...)
+		if len(b.tail) > 2*tailLimit {
+			b.tail = append(b.tail[:0], b.tail[len(b.tail)-tailLimit:]...)
+		}
+	}
+	return len(p), nil
+}
+
+// String 返回保留的输出，被截断时在开头和结尾之间插入截断标记
+func (b *cappedBuffer) String() string {
+	tailLimit := b.limit - b.limit/2
+	tail := b.tail
+	if len(tail) > tailLimit {
+		tail = tail[len(tail)-tailLimit:]
+	}
+	omitted := b.total - int64(len(b.head)) - int64(len(tail))
+	if omitted == 0 {
+		return string(b.head) + string(tail)
+	}
+	// 截断处可能把多字节字符切开，去掉不完整的部分
+	head := strings.ToValidUTF8(string(b.head), "")
+	return fmt.Sprintf("%s\n... [%d bytes truncated] ...\n%s", head, omitted, strings.ToValidUTF8(string(tail), ""))
+}
+
+// Len 返回截断前写入的总字节数
+func (b *cappedBuffer) Len() int64 {
+	return b.total
+}
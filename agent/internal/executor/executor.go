@@ -2,35 +2,173 @@ package executor
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
 	"os/exec"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/GenJi77JYXC/intelligent-pioneer/agent/internal/client"
 )
 
-// Execute 执行一个任务并返回结果
-func Execute(agentID string, task *client.Task) client.TaskResult {
-	log.Printf("Executing command: %s", task.Command)
+const (
+	// defaultTimeout 是后端没有指定超时时间时的执行超时
+	defaultTimeout = 1 * time.Minute
+	// waitDelay 是命令被终止后等待输出管道关闭的最长时间
+	waitDelay = 5 * time.Second
+)
+
+// Request 是交给 Executor 执行的一个任务及其执行环境
+type Request struct {
+	AgentID string
+	Task    *client.Task
+	// Sink 不为空时，执行过程中的输出应当分段交给 Sink
+	Sink OutputSink
+	// Files 用于与后端分块传输文件
+	Files FileTransport
+	// Timeout 是任务的执行超时，ctx 在超时后被取消，用于生成超时的错误信息
+	Timeout time.Duration
+}
+
+// Executor 执行某一种执行方式 (Task.Kind) 的任务
+// ctx 超时或被取消时应当尽快终止执行并返回，参数无法满足时以 TaskStatusRejected 拒绝执行
+type Executor interface {
+	Execute(ctx context.Context, req *Request) client.TaskResult
+}
+
+// ExecutorFunc 让普通函数实现 Executor
+type ExecutorFunc func(ctx context.Context, req *Request) client.TaskResult
+
+func (f ExecutorFunc) Execute(ctx context.Context, req *Request) client.TaskResult {
+	return f(ctx, req)
+}
+
+var (
+	executorsMu sync.RWMutex
+	executors   = make(map[string]Executor)
+)
+
+// Register 注册执行 kind 类任务的 Executor，kind 已被注册时返回错误
+// 内置的执行方式 (command、script、file_push、file_pull、action) 在包初始化时注册，外部插件见 LoadPlugins
+func Register(kind string, executor Executor) error {
+	executorsMu.Lock()
+	defer executorsMu.Unlock()
+	if _, ok := executors[kind]; ok {
+		return fmt.Errorf("executor for %q is already registered", kind)
+	}
+	executors[kind] = executor
+	return nil
+}
+
+func mustRegister(kind string, executor Executor) {
+	if err := Register(kind, executor); err != nil {
+		panic(err)
+	}
+}
+
+// Kinds 返回所有已注册的执行方式 (按字母顺序)，Agent 注册时上报给后端
+func Kinds() []string {
+	executorsMu.RLock()
+	defer executorsMu.RUnlock()
+	kinds := make([]string, 0, len(executors))
+	for kind := range executors {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+func lookup(kind string) (Executor, bool) {
+	if kind == "" {
+		kind = client.TaskKindCommand
+	}
+	executorsMu.RLock()
+	defer executorsMu.RUnlock()
+	executor, ok := executors[kind]
+	return executor, ok
+}
+
+func init() {
+	mustRegister(client.TaskKindCommand, ExecutorFunc(executeCommand))
+	mustRegister(client.TaskKindScript, ExecutorFunc(executeCommand))
+	mustRegister(client.TaskKindFilePush, ExecutorFunc(executeFileTask))
+	mustRegister(client.TaskKindFilePull, ExecutorFunc(executeFileTask))
+	mustRegister(client.TaskKindAction, ExecutorFunc(executeAction))
+}
+
+// Execute 按任务的执行方式 (Task.Kind，为空等同于 "command") 选择 Executor 执行任务并返回结果
+// ctx 被取消时 (例如后端下发了取消指令) 终止执行，结果中标记为已取消
+// sink 不为空时，执行过程中的输出会分段交给 sink，所有输出都交给 sink 之后 Execute 才返回
+// files 用于文件传输任务 (file_push / file_pull) 与后端分块传输文件
+func Execute(ctx context.Context, agentID string, task *client.Task, sink OutputSink, files FileTransport) client.TaskResult {
+	executor, ok := lookup(task.Kind)
+	if !ok {
+		return rejectTask(agentID, task, fmt.Errorf("unsupported task kind %q", task.Kind))
+	}
 
-	// 设置命令执行超时，例如 1 分钟
-	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
+	// 超时时间由后端按知识库步骤下发，未指定时使用默认值
+	timeout := defaultTimeout
+	if task.Timeout > 0 {
+		timeout = time.Duration(task.Timeout) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
-	// 使用 sh -c 来执行命令，以便支持管道等 shell 特性
-	cmd := exec.CommandContext(ctx, "sh", "-c", task.Command)
+	return executor.Execute(ctx, &Request{
+		AgentID: agentID,
+		Task:    task,
+		Sink:    sink,
+		Files:   files,
+		Timeout: timeout,
+	})
+}
+
+// executeCommand 执行命令任务和脚本任务
+func executeCommand(ctx context.Context, req *Request) client.TaskResult {
+	agentID, task, timeout := req.AgentID, req.Task, req.Timeout
+	var scriptPath string
+	if task.Kind == client.TaskKindScript {
+		log.Printf("Executing script: %s", task.ScriptName)
+		path, cleanup, err := prepareScript(task)
+		// 脚本执行结束 (或被拒绝) 后总是删除临时文件
+		defer cleanup()
+		if err != nil {
+			return rejectTask(agentID, task, err)
+		}
+		scriptPath = path
+	} else {
+		log.Printf("Executing command: %s", task.Command)
+	}
 
-	output, err := cmd.CombinedOutput() // 合并 stdout 和 stderr
+	cmd, err := buildCommand(ctx, task, scriptPath)
+	if err != nil {
+		return rejectTask(agentID, task, err)
+	}
+	// 进程组被终止后，仍持有输出管道的进程 (例如脱离了进程组的后台进程) 不应让任务一直挂起
+	cmd.WaitDelay = waitDelay
+
+	output := newOutputStreamer(task.ID, req.Sink)
+	cmd.Stdout = output.writer("stdout")
+	cmd.Stderr = output.writer("stderr")
+	err = cmd.Run()
 
 	result := client.TaskResult{
 		TaskID:  task.ID,
 		AgentID: agentID,
-		Output:  string(output),
 	}
+	output.close(&result)
 
 	if err != nil {
 		result.Success = false
 		result.Error = err.Error()
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			result.Status = client.TaskStatusTimedOut
+			result.Error = fmt.Sprintf("task timed out after %s", timeout)
+		} else if errors.Is(ctx.Err(), context.Canceled) {
+			result.Error = "task cancelled: " + context.Cause(ctx).Error()
+		}
 		if exitErr, ok := err.(*exec.ExitError); ok {
 			result.ExitCode = exitErr.ExitCode()
 		} else {
@@ -45,3 +183,35 @@ func Execute(agentID string, task *client.Task) client.TaskResult {
 
 	return result
 }
+
+// failTask 返回不通过命令执行的任务 (文件传输、内置动作、插件) 失败的结果，错误同时写入 Stderr
+// ctx 超时或被取消导致的失败与命令任务一样标记为超时或已取消
+func failTask(ctx context.Context, req *Request, err error) client.TaskResult {
+	result := client.TaskResult{
+		TaskID:   req.Task.ID,
+		AgentID:  req.AgentID,
+		Error:    err.Error(),
+		ExitCode: -1,
+	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		result.Status = client.TaskStatusTimedOut
+		result.Error = fmt.Sprintf("task timed out after %s", req.Timeout)
+	} else if errors.Is(ctx.Err(), context.Canceled) {
+		result.Error = "task cancelled: " + context.Cause(ctx).Error()
+	}
+	result.Stderr = result.Error + "\n"
+	result.StderrBytes = int64(len(result.Stderr))
+	return result
+}
+
+// rejectTask 返回拒绝执行任务的结果，任务没有被执行
+func rejectTask(agentID string, task *client.Task, err error) client.TaskResult {
+	log.Printf("Rejecting task %s: %v", task.ID, err)
+	return client.TaskResult{
+		TaskID:   task.ID,
+		AgentID:  agentID,
+		Error:    err.Error(),
+		ExitCode: -1,
+		Status:   client.TaskStatusRejected,
+	}
+}
@@ -0,0 +1,261 @@
+package executor
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/GenJi77JYXC/intelligent-pioneer/agent/internal/client"
+	"github.com/GenJi77JYXC/intelligent-pioneer/agent/internal/config"
+)
+
+const (
+	// fileChunkSize 是文件传输中每个分块的字节数，不超过后端的 engine.MaxFileChunkSize
+	fileChunkSize = 1024 * 1024
+	// fileChunkRetries 是单个分块传输失败后的重试次数
+	fileChunkRetries = 3
+	defaultFileMode  = 0644
+)
+
+// FileTransport 是文件传输任务与后端之间分块传输文件的接口，由 client.APIClient 实现
+type FileTransport interface {
+	DownloadFileChunk(ctx context.Context, agentID, taskID string, offset, length int64) ([]byte, error)
+	UploadFileChunk(ctx context.Context, agentID, taskID string, offset int64, data []byte) error
+	CompleteFileUpload(ctx context.Context, agentID, taskID, name, path string, size int64, checksum string) (string, error)
+}
+
+// maxFileBytes 返回文件传输任务允许传输的最大文件字节数
+func maxFileBytes() int64 {
+	if config.Cfg != nil && config.Cfg.MaxFileBytes > 0 {
+		return config.Cfg.MaxFileBytes
+	}
+	return 100 * 1024 * 1024
+}
+
+// pushFile 执行 file_push 任务: 分块下载文件并逐块校验，写入目标目录下的临时文件，
+// 校验整个文件的 SHA-256、设置权限和所有者后通过 rename 原子地替换目标文件
+func pushFile(ctx context.Context, agentID string, task *client.Task, files FileTransport) (string, error) {
+	spec := task.File
+	if err := validateFilePath(spec); err != nil {
+		return "", rejection(err)
+	}
+	if spec.FileID == "" || spec.SHA256 == "" {
+		return "", rejection(errors.New("file_push task has no file checksum"))
+	}
+	if spec.Size < 0 || spec.Size > maxFileBytes() {
+		return "", rejection(fmt.Errorf("file size %d exceeds the agent limit of %d bytes", spec.Size, maxFileBytes()))
+	}
+	mode := os.FileMode(defaultFileMode)
+	if spec.Mode != "" {
+		m, err := strconv.ParseUint(spec.Mode, 8, 32)
+		if err != nil || m > 0777 {
+			return "", rejection(fmt.Errorf("invalid file mode %q", spec.Mode))
+		}
+		mode = os.FileMode(m)
+	}
+	uid, gid, err := lookupOwner(spec.Owner, spec.Group)
+	if err != nil {
+		return "", rejection(fmt.Errorf("cannot set file owner: %w", err))
+	}
+
+	// 临时文件与目标文件在同一目录，保证 rename 是原子的
+	dir := filepath.Dir(spec.Path)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(spec.Path)+".pioneer-")
+	if err != nil {
+		return "", fmt.Errorf("cannot create temporary file: %w", err)
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	hasher := sha256.New()
+	w := io.MultiWriter(tmp, hasher)
+	for offset := int64(0); offset < spec.Size; {
+		length := min(int64(fileChunkSize), spec.Size-offset)
+		var data []byte
+		err := retryChunk(ctx, func() error {
+			var err error
+			data, err = files.DownloadFileChunk(ctx, agentID, task.ID, offset, length)
+			return err
+		})
+		if err != nil {
+			return "", fmt.Errorf("cannot download file chunk at offset %d: %w", offset, err)
+		}
+		if len(data) == 0 {
+			return "", fmt.Errorf("file ended unexpectedly at offset %d", offset)
+		}
+		if _, err := w.Write(data); err != nil {
+			return "", fmt.Errorf("cannot write temporary file: %w", err)
+		}
+		offset += int64(len(data))
+	}
+	if sum := hex.EncodeToString(hasher.Sum(nil)); sum != spec.SHA256 {
+		return "", fmt.Errorf("file failed checksum verification: expected %s, got %s", spec.SHA256, sum)
+	}
+
+	if err := tmp.Chmod(mode); err != nil {
+		return "", fmt.Errorf("cannot set file mode: %w", err)
+	}
+	if uid != -1 || gid != -1 {
+		if err := tmp.Chown(uid, gid); err != nil {
+			return "", fmt.Errorf("cannot set file owner: %w", err)
+		}
+	}
+	if err := tmp.Sync(); err != nil {
+		return "", fmt.Errorf("cannot sync temporary file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", fmt.Errorf("cannot close temporary file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), spec.Path); err != nil {
+		return "", fmt.Errorf("cannot replace %s: %w", spec.Path, err)
+	}
+	committed = true
+
+	return fmt.Sprintf("wrote %d bytes to %s (mode %04o, sha256 %s)\n", spec.Size, spec.Path, mode, spec.SHA256), nil
+}
+
+// pullFile 执行 file_pull 任务: 分块上传 Agent 上的文件，后端逐块校验，全部上传后校验整个文件的大小和 SHA-256
+func pullFile(ctx context.Context, agentID string, task *client.Task, files FileTransport) (string, error) {
+	spec := task.File
+	if err := validateFilePath(spec); err != nil {
+		return "", rejection(err)
+	}
+	limit := maxFileBytes()
+	if spec.MaxSize > 0 && spec.MaxSize < limit {
+		limit = spec.MaxSize
+	}
+
+	f, err := os.Open(spec.Path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	if !info.Mode().IsRegular() {
+		return "", fmt.Errorf("%s is not a regular file", spec.Path)
+	}
+	if info.Size() > limit {
+		return "", fmt.Errorf("%s is %d bytes, exceeding the limit of %d bytes", spec.Path, info.Size(), limit)
+	}
+
+	// 按实际读到的内容计算大小和 SHA-256，文件在上传过程中被追加时最多读取 limit 字节
+	hasher := sha256.New()
+	r := io.TeeReader(io.LimitReader(f, limit), hasher)
+	buf := make([]byte, fileChunkSize)
+	var size int64
+	for {
+		n, err := io.ReadFull(r, buf)
+		if n > 0 {
+			offset := size
+			if err := retryChunk(ctx, func() error {
+				return files.UploadFileChunk(ctx, agentID, task.ID, offset, buf[:n])
+			}); err != nil {
+				return "", fmt.Errorf("cannot upload file chunk at offset %d: %w", offset, err)
+			}
+			size += int64(n)
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("cannot read %s: %w", spec.Path, err)
+		}
+	}
+
+	checksum := hex.EncodeToString(hasher.Sum(nil))
+	var fileID string
+	err = retryChunk(ctx, func() error {
+		var err error
+		fileID, err = files.CompleteFileUpload(ctx, agentID, task.ID, filepath.Base(spec.Path), spec.Path, size, checksum)
+		return err
+	})
+	if err != nil {
+		return "", fmt.Errorf("cannot complete file upload: %w", err)
+	}
+
+	return fmt.Sprintf("collected %d bytes from %s as file %s (sha256 %s)\n", size, spec.Path, fileID, checksum), nil
+}
+
+func validateFilePath(spec *client.FileTransfer) error {
+	if spec == nil {
+		return errors.New("file transfer task has no file parameters")
+	}
+	if !filepath.IsAbs(spec.Path) {
+		return fmt.Errorf("file path %q must be absolute", spec.Path)
+	}
+	return nil
+}
+
+// retryChunk 执行 fn，失败时等待后重试，任务被取消或超时时立即返回
+func retryChunk(ctx context.Context, fn func() error) error {
+	var err error
+	for attempt := 0; attempt <= fileChunkRetries; attempt++ {
+		if attempt > 0 {
+			log.Printf("WARN: File transfer request failed, retrying: %v", err)
+			select {
+			case <-ctx.Done():
+				return err
+			case <-time.After(time.Duration(attempt) * time.Second):
+			}
+		}
+		if err = fn(); err == nil || ctx.Err() != nil {
+			return err
+		}
+	}
+	return err
+}
+
+// rejectionError 表示文件传输任务的参数无法满足，任务没有被执行
+type rejectionError struct{ error }
+
+func rejection(err error) error { return rejectionError{err} }
+
+// executeFileTask 执行文件传输任务，把结果转换为与命令任务一致的 TaskResult
+func executeFileTask(ctx context.Context, req *Request) client.TaskResult {
+	agentID, task, files := req.AgentID, req.Task, req.Files
+	log.Printf("Executing %s task %s", task.Kind, task.ID)
+	var summary string
+	var err error
+	if files == nil {
+		err = rejection(errors.New("file transfer is not available"))
+	} else if task.Kind == client.TaskKindFilePush {
+		summary, err = pushFile(ctx, agentID, task, files)
+	} else {
+		summary, err = pullFile(ctx, agentID, task, files)
+	}
+
+	var rejected rejectionError
+	if errors.As(err, &rejected) {
+		return rejectTask(agentID, task, rejected.error)
+	}
+
+	if err != nil {
+		log.Printf("File transfer failed: %v", err)
+		return failTask(ctx, req, err)
+	}
+
+	log.Printf("File transfer completed: %s", summary)
+	return client.TaskResult{
+		TaskID:      task.ID,
+		AgentID:     agentID,
+		Success:     true,
+		Stdout:      summary,
+		StdoutBytes: int64(len(summary)),
+	}
+}
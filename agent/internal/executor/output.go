@@ -0,0 +1,178 @@
+package executor
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/GenJi77JYXC/intelligent-pioneer/agent/internal/client"
+)
+
+const (
+	// outputFlushInterval 是上报执行过程中输出的间隔
+	outputFlushInterval = time.Second
+	// outputChunkSize 是单段输出的最大字节数，积攒到这个大小会提前上报 (后端上限为 64KB)
+	outputChunkSize = 32 * 1024
+	// maxPendingOutput 是等待上报的输出最多积攒的字节数
+	// OutputSink 阻塞 (例如网络缓慢) 期间超出的部分丢弃最早的输出，避免输出很多的命令占用无限的内存
+	maxPendingOutput = 1024 * 1024
+)
+
+// OutputSink 接收任务执行过程中的输出，调用是串行的，序号严格递增
+// 实现可以阻塞 (例如发送网络请求)，期间产生的输出会积攒到下一次调用；积攒超过 maxPendingOutput 时，
+// 最早的输出被丢弃，代之以一段说明丢弃了多少字节的 stderr 提示，因此序号可能不连续
+type OutputSink func(chunks []client.OutputChunk)
+
+// outputStreamer 收集命令的 stdout 和 stderr，定期分段交给 OutputSink
+// 同时分别保留截断后的 stdout 和 stderr，随任务结果一起上报
+type outputStreamer struct {
+	taskID string
+	sink   OutputSink
+
+	mu      sync.Mutex
+	stdout  *cappedBuffer
+	stderr  *cappedBuffer
+	pending []client.OutputChunk
+	nextSeq int
+
+	pendingBytes int // pending 中输出的总字节数
+	droppedBytes int // 上次上报之后被丢弃的字节数
+	droppedSeq   int // 第一段被丢弃的输出的序号，丢弃提示使用这个序号
+
+	kick chan struct{}
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newOutputStreamer(taskID string, sink OutputSink) *outputStreamer {
+	o := &outputStreamer{
+		taskID: taskID,
+		sink:   sink,
+		stdout: newCappedBuffer(maxOutputBytes()),
+		stderr: newCappedBuffer(maxOutputBytes()),
+		kick:   make(chan struct{}, 1),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	if sink == nil {
+		close(o.done)
+	} else {
+		go o.flushLoop()
+	}
+	return o
+}
+
+// writer 返回写入指定来源 ("stdout" 或 "stderr") 的 io.Writer
+func (o *outputStreamer) writer(stream string) io.Writer {
+	capture := o.stdout
+	if stream == "stderr" {
+		capture = o.stderr
+	}
+	return streamWriter{o: o, stream: stream, capture: capture}
+}
+
+type streamWriter struct {
+	o       *outputStreamer
+	stream  string
+	capture *cappedBuffer
+}
+
+func (w streamWriter) Write(p []byte) (int, error) {
+	o := w.o
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	w.capture.Write(p)
+	if o.sink == nil || len(p) == 0 {
+		return len(p), nil
+	}
+
+	for data := p; len(data) > 0; {
+		// 与上一段来源相同时合并，减少分段数量
+		last := len(o.pending) - 1
+		if last < 0 || o.pending[last].Stream != w.stream || len(o.pending[last].Data) >= outputChunkSize {
+			o.pending = append(o.pending, client.OutputChunk{TaskID: o.taskID, Seq: o.nextSeq, Stream: w.stream})
+			o.nextSeq++
+			last++
+		}
+		n := min(outputChunkSize-len(o.pending[last].Data), len(data))
+		o.pending[last].Data = append(o.pending[last].Data, data[:n]...)
+		o.pendingBytes += n
+		data = data[n:]
+	}
+	o.dropOldestLocked()
+	if len(o.pending) > 1 || len(o.pending[0].Data) >= outputChunkSize {
+		select {
+		case o.kick <- struct{}{}:
+		default:
+		}
+	}
+	return len(p), nil
+}
+
+// dropOldestLocked 丢弃最早的待上报分段，直到积攒的输出不超过 maxPendingOutput，调用方必须持有 o.mu
+// 完整的 (截断后的) stdout 和 stderr 仍然随任务结果上报，这里只影响实时查看
+func (o *outputStreamer) dropOldestLocked() {
+	for o.pendingBytes > maxPendingOutput && len(o.pending) > 1 {
+		oldest := o.pending[0]
+		if o.droppedBytes == 0 {
+			o.droppedSeq = oldest.Seq
+		}
+		o.droppedBytes += len(oldest.Data)
+		o.pendingBytes -= len(oldest.Data)
+		o.pending[0] = client.OutputChunk{} // 释放被丢弃的数据
+		o.pending = o.pending[1:]
+	}
+}
+
+func (o *outputStreamer) flushLoop() {
+	defer close(o.done)
+	ticker := time.NewTicker(outputFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-o.kick:
+		case <-o.stop:
+			o.flush()
+			return
+		}
+		o.flush()
+	}
+}
+
+func (o *outputStreamer) flush() {
+	o.mu.Lock()
+	chunks := o.pending
+	if o.droppedBytes > 0 {
+		// 丢弃提示使用第一段被丢弃的输出的序号，排在剩余输出之前
+		gap := client.OutputChunk{
+			TaskID: o.taskID,
+			Seq:    o.droppedSeq,
+			Stream: "stderr",
+			Data:   []byte(fmt.Sprintf("\n[agent: %d bytes of output dropped because the server could not keep up; stdout and stderr are still reported with the result]\n", o.droppedBytes)),
+		}
+		chunks = append([]client.OutputChunk{gap}, chunks...)
+		o.droppedBytes = 0
+	}
+	o.pending = nil
+	o.pendingBytes = 0
+	o.mu.Unlock()
+	if len(chunks) > 0 {
+		o.sink(chunks)
+	}
+}
+
+// close 上报剩余的输出，并把截断后的 stdout 和 stderr 填入任务结果，必须在命令结束后调用
+func (o *outputStreamer) close(result *client.TaskResult) {
+	if o.sink != nil {
+		close(o.stop)
+	}
+	<-o.done
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	result.Stdout = o.stdout.String()
+	result.Stderr = o.stderr.String()
+	result.StdoutBytes = o.stdout.Len()
+	result.StderrBytes = o.stderr.Len()
+}
@@ -0,0 +1,184 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+
+	"github.com/GenJi77JYXC/intelligent-pioneer/agent/internal/client"
+)
+
+// maxPluginResponseBytes 是插件写到 stdout 的响应的最大字节数
+const maxPluginResponseBytes = 4 * 1024 * 1024
+
+// pluginKindPattern 限制插件的执行方式名称 (插件文件名去掉扩展名)
+var pluginKindPattern = regexp.MustCompile(`^[a-z][a-z0-9_.\-]{0,63}$`)
+
+// PluginRequest 是 Agent 写入插件 stdin 的请求
+type PluginRequest struct {
+	AgentID string       `json:"agent_id"`
+	Task    *client.Task `json:"task"`
+	// TimeoutSeconds 是任务的执行超时，超时后插件进程 (及其子进程) 会被终止
+	TimeoutSeconds int `json:"timeout_seconds"`
+}
+
+// PluginResponse 是插件执行结束前写到 stdout 的响应 (一个 JSON 对象)
+// 插件执行过程中的日志应当写到 stderr，stderr 会实时上报并随结果保存
+type PluginResponse struct {
+	Success  bool   `json:"success"`
+	ExitCode int    `json:"exit_code"`
+	Error    string `json:"error"`
+	// Output 是给人看的输出，作为任务结果的 stdout
+	Output string `json:"output"`
+	// Data 是结构化结果，与内置动作的结果一样可以被诊断步骤的 "remediate_if" 条件使用
+	Data json.RawMessage `json:"data,omitempty"`
+	// Rejected 为 true 表示插件无法满足任务的参数，任务没有被执行
+	Rejected bool `json:"rejected"`
+}
+
+// pluginExecutor 通过外部插件程序执行任务
+// 协议: Agent 启动插件，向 stdin 写入 PluginRequest 后关闭 stdin，插件向 stdout 写入 PluginResponse 后退出
+type pluginExecutor struct {
+	kind string
+	path string
+}
+
+// LoadPlugins 把 dir 中的可执行文件注册为外部插件，文件名 (去掉扩展名) 就是插件处理的执行方式 (Task.Kind)
+// 与内置执行方式或其他插件重名、名称不合法的文件会被跳过，dir 不存在时不报错
+// 返回成功注册的执行方式
+func LoadPlugins(dir string) ([]string, error) {
+	if dir == "" {
+		return nil, nil
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var loaded []string
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		info, err := os.Stat(path)
+		if err != nil || !info.Mode().IsRegular() || !isExecutable(entry.Name(), info) {
+			continue
+		}
+		kind := strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name()))
+		if !pluginKindPattern.MatchString(kind) {
+			log.Printf("WARN: Skipping plugin %s: invalid task kind %q", path, kind)
+			continue
+		}
+		if err := Register(kind, &pluginExecutor{kind: kind, path: path}); err != nil {
+			log.Printf("WARN: Skipping plugin %s: %v", path, err)
+			continue
+		}
+		log.Printf("Loaded executor plugin %s for task kind %q", path, kind)
+		loaded = append(loaded, kind)
+	}
+	return loaded, nil
+}
+
+func isExecutable(name string, info os.FileInfo) bool {
+	if runtime.GOOS == "windows" {
+		return strings.EqualFold(filepath.Ext(name), ".exe")
+	}
+	return info.Mode().Perm()&0111 != 0
+}
+
+func (p *pluginExecutor) Execute(ctx context.Context, req *Request) client.TaskResult {
+	agentID, task := req.AgentID, req.Task
+	log.Printf("Executing %s task %s with plugin %s", task.Kind, task.ID, p.path)
+
+	input, err := json.Marshal(PluginRequest{
+		AgentID:        agentID,
+		Task:           task,
+		TimeoutSeconds: int(req.Timeout.Seconds()),
+	})
+	if err != nil {
+		return failTask(ctx, req, fmt.Errorf("cannot encode plugin request: %w", err))
+	}
+
+	cmd := exec.CommandContext(ctx, p.path)
+	killProcessGroup(cmd)
+	cmd.WaitDelay = waitDelay
+	cmd.Stdin = bytes.NewReader(input)
+	stdout := &limitedBuffer{limit: maxPluginResponseBytes}
+	cmd.Stdout = stdout
+	output := newOutputStreamer(task.ID, req.Sink)
+	cmd.Stderr = output.writer("stderr")
+	runErr := cmd.Run()
+
+	result := client.TaskResult{
+		TaskID:  task.ID,
+		AgentID: agentID,
+	}
+	output.close(&result)
+	if ctx.Err() != nil {
+		failed := failTask(ctx, req, ctx.Err())
+		failed.Stderr, failed.StderrBytes = result.Stderr+failed.Stderr, result.StderrBytes+failed.StderrBytes
+		return failed
+	}
+
+	var resp PluginResponse
+	if err := json.Unmarshal(bytes.TrimSpace(stdout.Bytes()), &resp); err != nil {
+		result.ExitCode = -1
+		result.Error = fmt.Sprintf("plugin returned an invalid response: %v", err)
+		var exitErr *exec.ExitError
+		if errors.As(runErr, &exitErr) {
+			result.ExitCode = exitErr.ExitCode()
+			result.Error = fmt.Sprintf("plugin exited with %v without a valid response", runErr)
+		} else if runErr != nil {
+			result.Error = "cannot run plugin: " + runErr.Error()
+		}
+		log.Printf("Plugin %s failed: %s", p.kind, result.Error)
+		return result
+	}
+
+	if resp.Rejected {
+		if resp.Error == "" {
+			resp.Error = "rejected by plugin " + p.kind
+		}
+		return rejectTask(agentID, task, errors.New(resp.Error))
+	}
+	result.Success = resp.Success && runErr == nil
+	result.ExitCode = resp.ExitCode
+	result.Error = resp.Error
+	if runErr != nil && result.Error == "" {
+		result.Error = runErr.Error()
+	}
+	capped := newCappedBuffer(maxOutputBytes())
+	capped.Write([]byte(resp.Output))
+	result.Stdout, result.StdoutBytes = capped.String(), capped.Len()
+	if len(resp.Data) > 0 && !bytes.Equal(resp.Data, []byte("null")) {
+		result.Data = resp.Data
+	}
+	log.Printf("Plugin %s finished, success: %v", p.kind, result.Success)
+	return result
+}
+
+// limitedBuffer 最多保存 limit 字节，超出的部分被丢弃 (插件的响应会因此无法解析)
+type limitedBuffer struct {
+	bytes.Buffer
+	limit int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - b.Len(); room > 0 {
+		b.Buffer.Write(p[:min(len(p), room)])
+	}
+	return len(p), nil
+}
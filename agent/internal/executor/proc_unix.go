@@ -0,0 +1,93 @@
+//go:build !windows
+
+package executor
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"strconv"
+	"syscall"
+)
+
+// killProcessGroup 让命令在独立的进程组中运行，超时或取消时向整个进程组发送 SIGKILL
+func killProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+	cmd.Cancel = func() error {
+		// 负数 pid 表示进程组，进程组 ID 与 sh 的 pid 相同
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
+
+// lookupOwner 把用户名和组名解析为 uid 和 gid，为空的一项返回 -1 (os.Chown 中表示不修改)
+func lookupOwner(owner, group string) (int, int, error) {
+	uid, gid := -1, -1
+	if owner != "" {
+		u, err := user.Lookup(owner)
+		if err != nil {
+			return 0, 0, err
+		}
+		id, err := strconv.Atoi(u.Uid)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid uid %s of user %s", u.Uid, owner)
+		}
+		uid = id
+	}
+	if group != "" {
+		g, err := user.LookupGroup(group)
+		if err != nil {
+			return 0, 0, err
+		}
+		id, err := strconv.Atoi(g.Gid)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid gid %s of group %s", g.Gid, group)
+		}
+		gid = id
+	}
+	return uid, gid, nil
+}
+
+// setRunAs 让命令以指定用户 (及其所属的组) 的身份运行，返回该用户的 HOME、USER 和 LOGNAME 环境变量
+// owned 中的文件 (例如脚本) 的所有者会被改为该用户，切换到其他用户需要 Agent 以 root 运行
+func setRunAs(cmd *exec.Cmd, username string, owned ...string) ([]string, error) {
+	u, err := user.Lookup(username)
+	if err != nil {
+		return nil, fmt.Errorf("cannot run as %s: %w", username, err)
+	}
+	uid, err := strconv.ParseUint(u.Uid, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("cannot run as %s: invalid uid %s", username, u.Uid)
+	}
+	gid, err := strconv.ParseUint(u.Gid, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("cannot run as %s: invalid gid %s", username, u.Gid)
+	}
+	if euid := os.Geteuid(); euid != 0 && uint64(euid) != uid {
+		return nil, fmt.Errorf("cannot run as %s: agent is not running as root", username)
+	}
+
+	for _, path := range owned {
+		if err := os.Chown(path, int(uid), int(gid)); err != nil {
+			return nil, fmt.Errorf("cannot run as %s: %w", username, err)
+		}
+	}
+
+	var groups []uint32
+	if groupIDs, err := u.GroupIds(); err == nil {
+		for _, g := range groupIDs {
+			if id, err := strconv.ParseUint(g, 10, 32); err == nil {
+				groups = append(groups, uint32(id))
+			}
+		}
+	}
+
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Credential = &syscall.Credential{Uid: uint32(uid), Gid: uint32(gid), Groups: groups}
+	return []string{"HOME=" + u.HomeDir, "USER=" + u.Username, "LOGNAME=" + u.Username}, nil
+}
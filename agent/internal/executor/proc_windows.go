@@ -0,0 +1,32 @@
+//go:build windows
+
+package executor
+
+import (
+	"errors"
+	"os/exec"
+	"strconv"
+)
+
+// killProcessGroup 在超时或取消时通过 taskkill /T 终止命令及其所有子进程
+func killProcessGroup(cmd *exec.Cmd) {
+	cmd.Cancel = func() error {
+		if err := exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(cmd.Process.Pid)).Run(); err != nil {
+			return cmd.Process.Kill()
+		}
+		return nil
+	}
+}
+
+// lookupOwner 在 Windows 上不支持，指定了文件所有者的 file_push 任务会被拒绝
+func lookupOwner(owner, group string) (int, int, error) {
+	if owner != "" || group != "" {
+		return 0, 0, errors.New("file owner and group are not supported on windows")
+	}
+	return -1, -1, nil
+}
+
+// setRunAs 在 Windows 上不支持，以其他用户身份运行的任务会被拒绝
+func setRunAs(cmd *exec.Cmd, username string, owned ...string) ([]string, error) {
+	return nil, errors.New("run_as is not supported on windows")
+}
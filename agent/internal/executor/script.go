@@ -0,0 +1,40 @@
+package executor
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/GenJi77JYXC/intelligent-pioneer/agent/internal/client"
+)
+
+// prepareScript 校验脚本任务的 SHA-256，并把脚本写入只有 Agent (或 RunAs 用户) 可访问的临时目录
+// 返回脚本路径和清理函数，无论任务是否执行成功，调用方都必须调用清理函数
+func prepareScript(task *client.Task) (string, func(), error) {
+	noop := func() {}
+	sum := sha256.Sum256([]byte(task.Script))
+	if task.ScriptSHA256 == "" || hex.EncodeToString(sum[:]) != task.ScriptSHA256 {
+		return "", noop, fmt.Errorf("script %s failed checksum verification", task.ScriptName)
+	}
+
+	// MkdirTemp 创建的目录权限为 0700
+	dir, err := os.MkdirTemp("", "pioneer-script-")
+	if err != nil {
+		return "", noop, fmt.Errorf("cannot create script directory: %w", err)
+	}
+	cleanup := func() {
+		if err := os.RemoveAll(dir); err != nil {
+			log.Printf("WARN: Failed to remove script directory %s: %v", dir, err)
+		}
+	}
+
+	path := filepath.Join(dir, "script")
+	if err := os.WriteFile(path, []byte(task.Script), 0700); err != nil {
+		cleanup()
+		return "", noop, fmt.Errorf("cannot write script: %w", err)
+	}
+	return path, cleanup, nil
+}
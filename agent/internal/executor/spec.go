@@ -0,0 +1,159 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/GenJi77JYXC/intelligent-pioneer/agent/internal/client"
+)
+
+// 支持的解释器，见 client.Task.Interpreter
+const (
+	InterpreterSh      = "sh"
+	InterpreterBash    = "bash"
+	InterpreterPython3 = "python3"
+	InterpreterDirect  = "direct" // 不经过 shell，按空白拆分命令行 (支持引号) 后直接执行
+)
+
+// buildCommand 按任务的执行参数构造命令，任务的执行参数无法满足时返回错误，此时任务不应被执行
+// scriptPath 不为空时执行该脚本文件 (脚本任务)，否则执行 task.Command
+func buildCommand(ctx context.Context, task *client.Task, scriptPath string) (*exec.Cmd, error) {
+	argv, err := commandArgs(task, scriptPath)
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	if cmd.Err != nil {
+		return nil, fmt.Errorf("cannot execute %s: %w", argv[0], cmd.Err)
+	}
+
+	if task.WorkDir != "" {
+		info, err := os.Stat(task.WorkDir)
+		if err != nil {
+			return nil, fmt.Errorf("invalid working directory: %w", err)
+		}
+		if !info.IsDir() {
+			return nil, fmt.Errorf("invalid working directory: %s is not a directory", task.WorkDir)
+		}
+		cmd.Dir = task.WorkDir
+	}
+
+	var env []string
+	for name, value := range task.Env {
+		if name == "" || strings.ContainsAny(name, "=\x00") || strings.Contains(value, "\x00") {
+			return nil, fmt.Errorf("invalid environment variable %q", name)
+		}
+		env = append(env, name+"="+value)
+	}
+
+	// 超时或取消时终止整个进程组，而不只是 shell，避免遗留子进程
+	killProcessGroup(cmd)
+	if task.RunAs != "" {
+		var owned []string
+		if scriptPath != "" {
+			// 脚本所在的临时目录只有 Agent 可以访问，需要交给 RunAs 用户
+			owned = []string{filepath.Dir(scriptPath), scriptPath}
+		}
+		userEnv, err := setRunAs(cmd, task.RunAs, owned...)
+		if err != nil {
+			return nil, err
+		}
+		// 任务中显式指定的环境变量优先
+		env = append(userEnv, env...)
+	}
+	if len(env) > 0 {
+		// 同名变量以后出现的为准
+		cmd.Env = append(os.Environ(), env...)
+	}
+
+	if task.Stdin != "" {
+		cmd.Stdin = strings.NewReader(task.Stdin)
+	}
+	return cmd, nil
+}
+
+// commandArgs 按解释器返回要执行的命令行
+func commandArgs(task *client.Task, scriptPath string) ([]string, error) {
+	interpreter := task.Interpreter
+	if interpreter == "" {
+		interpreter = InterpreterSh
+	}
+
+	switch interpreter {
+	case InterpreterSh, InterpreterBash, InterpreterPython3:
+		if _, err := exec.LookPath(interpreter); err != nil {
+			return nil, fmt.Errorf("interpreter %s is not available: %w", interpreter, err)
+		}
+		if scriptPath != "" {
+			return []string{interpreter, scriptPath}, nil
+		}
+		// 使用 -c 来执行命令，以便支持管道等 shell 特性
+		return []string{interpreter, "-c", task.Command}, nil
+	case InterpreterDirect:
+		if scriptPath != "" {
+			// 按脚本的 shebang 直接执行
+			return []string{scriptPath}, nil
+		}
+		args, err := splitCommandLine(task.Command)
+		if err != nil {
+			return nil, err
+		}
+		if len(args) == 0 {
+			return nil, errors.New("empty command")
+		}
+		return args, nil
+	default:
+		return nil, fmt.Errorf("unsupported interpreter %q", task.Interpreter)
+	}
+}
+
+// splitCommandLine 按空白拆分命令行，支持单引号、双引号和反斜杠转义，不做变量展开等 shell 处理
+func splitCommandLine(line string) ([]string, error) {
+	var (
+		args    []string
+		current strings.Builder
+		inArg   bool
+		quote   rune
+		escaped bool
+	)
+	for _, r := range line {
+		switch {
+		case escaped:
+			current.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped = true
+			inArg = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+	if escaped || quote != 0 {
+		return nil, errors.New("unterminated quote or escape in command")
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
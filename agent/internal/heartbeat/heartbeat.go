@@ -8,21 +8,27 @@ import (
 	"github.com/GenJi77JYXC/intelligent-pioneer/agent/internal/client"
 )
 
-// Start 启动心跳循环
-func Start(ctx context.Context, apiClient *client.APIClient, agentID string) {
+// Start 启动心跳循环，capacity 返回随心跳上报的任务执行容量
+func Start(ctx context.Context, apiClient *client.APIClient, agentID string, capacity func() client.Capacity) {
 	log.Println("Heartbeat service started.")
 	ticker := time.NewTicker(1 * time.Minute) // 每分钟发送一次心跳
 	defer ticker.Stop()
 
 	// 立即发送一次心跳，而不是等一分钟
-	if err := apiClient.SendHeartbeat(agentID); err != nil {
+	current := capacity()
+	if err := apiClient.SendHeartbeat(agentID, &current); err != nil {
 		log.Printf("ERROR: Failed to send initial heartbeat: %v", err)
 	}
 
 	for {
 		select {
 		case <-ticker.C:
-			if err := apiClient.SendHeartbeat(agentID); err != nil {
+			// 持久连接可用时心跳通过持久连接发送
+			if apiClient.Streaming() {
+				continue
+			}
+			current := capacity()
+			if err := apiClient.SendHeartbeat(agentID, &current); err != nil {
 				log.Printf("ERROR: Failed to send heartbeat: %v", err)
 			} else {
 				log.Println("Heartbeat sent successfully.")
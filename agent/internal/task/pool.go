@@ -0,0 +1,137 @@
+package task
+
+import (
+	"context"
+	"log"
+	"sync"
+
+	"github.com/GenJi77JYXC/intelligent-pioneer/agent/internal/client"
+)
+
+// Pool 限制同时执行的任务数，超出的任务在本地队列中按接收顺序等待
+// 每种执行方式 (Task.Kind) 还可以单独限制并发数，达到限制的任务不会阻塞队列中其他执行方式的任务
+type Pool struct {
+	maxConcurrency int
+	queueSize      int
+	kindLimits     map[string]int
+
+	mu            sync.Mutex
+	queue         []*pooledTask
+	running       int
+	runningByKind map[string]int
+	changed       chan struct{} // 容量变化时关闭并替换，用于通知等待者
+}
+
+type pooledTask struct {
+	kind string
+	run  func()
+}
+
+// PoolConfig 是任务执行池的配置，见 config.Config 中的同名字段
+type PoolConfig struct {
+	MaxConcurrency  int
+	QueueSize       int
+	KindConcurrency map[string]int
+}
+
+// NewPool 创建任务执行池，MaxConcurrency 至少为 1，QueueSize 不能为负数
+func NewPool(cfg PoolConfig) *Pool {
+	return &Pool{
+		maxConcurrency: max(cfg.MaxConcurrency, 1),
+		queueSize:      max(cfg.QueueSize, 0),
+		kindLimits:     cfg.KindConcurrency,
+		runningByKind:  make(map[string]int),
+		changed:        make(chan struct{}),
+	}
+}
+
+// submit 把任务放入队列，有空闲的执行槽位时立即开始执行
+// 后端按 Agent 上报的容量下发任务，队列满时任务仍会被接收 (只记录警告)，避免丢弃已确认的任务
+func (p *Pool) submit(task *client.Task, run func()) {
+	kind := task.Kind
+	if kind == "" {
+		kind = client.TaskKindCommand
+	}
+	p.mu.Lock()
+	if len(p.queue) >= p.queueSize && p.running >= p.maxConcurrency {
+		log.Printf("WARN: Local task queue is full (%d queued), accepting task %s anyway.", len(p.queue), task.ID)
+	}
+	p.queue = append(p.queue, &pooledTask{kind: kind, run: run})
+	p.dispatchLocked()
+	p.notifyLocked()
+	p.mu.Unlock()
+}
+
+// dispatchLocked 按队列顺序启动所有可以启动的任务，调用方必须持有 p.mu
+func (p *Pool) dispatchLocked() {
+	for i := 0; i < len(p.queue) && p.running < p.maxConcurrency; {
+		t := p.queue[i]
+		if limit := p.kindLimits[t.kind]; limit > 0 && p.runningByKind[t.kind] >= limit {
+			i++
+			continue
+		}
+		p.queue = append(p.queue[:i], p.queue[i+1:]...)
+		p.running++
+		p.runningByKind[t.kind]++
+		go p.execute(t)
+	}
+}
+
+func (p *Pool) execute(t *pooledTask) {
+	t.run()
+	p.mu.Lock()
+	p.running--
+	p.runningByKind[t.kind]--
+	p.dispatchLocked()
+	p.notifyLocked()
+	p.mu.Unlock()
+}
+
+func (p *Pool) notifyLocked() {
+	close(p.changed)
+	p.changed = make(chan struct{})
+}
+
+// Capacity 返回当前的执行容量，随心跳上报给后端
+func (p *Pool) Capacity() client.Capacity {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.capacityLocked()
+}
+
+func (p *Pool) capacityLocked() client.Capacity {
+	return client.Capacity{
+		MaxConcurrency: p.maxConcurrency,
+		QueueSize:      p.queueSize,
+		Running:        p.running,
+		Queued:         len(p.queue),
+		Available:      max(p.maxConcurrency+p.queueSize-p.running-len(p.queue), 0),
+	}
+}
+
+// available 返回还能接收的任务数
+func (p *Pool) available() int {
+	return p.Capacity().Available
+}
+
+// changes 返回一个在容量下一次变化时被关闭的 channel
+func (p *Pool) changes() <-chan struct{} {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.changed
+}
+
+// waitAvailable 等待直到可以接收新任务，ctx 结束时返回 false
+func (p *Pool) waitAvailable(ctx context.Context) bool {
+	for {
+		changed := p.changes()
+		if p.available() > 0 {
+			return true
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
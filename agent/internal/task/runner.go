@@ -0,0 +1,149 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+
+	"github.com/GenJi77JYXC/intelligent-pioneer/agent/internal/client"
+	"github.com/GenJi77JYXC/intelligent-pioneer/agent/internal/executor"
+)
+
+// runner 执行任务并上报结果，同时跟踪正在执行的任务以响应后端的取消指令
+// 任务的生命周期可能跨越多个持久连接，甚至在持久连接和长轮询之间切换
+type runner struct {
+	apiClient *client.APIClient
+	agentID   string
+	pool      *Pool
+
+	mu      sync.Mutex
+	running map[string]context.CancelCauseFunc
+	stream  *client.Stream                // 当前的持久连接，nil 时通过 HTTP 上报结果
+	unacked map[string]*client.TaskResult // 已通过持久连接上报、后端尚未确认的结果
+}
+
+func newRunner(apiClient *client.APIClient, agentID string, pool *Pool) *runner {
+	return &runner{
+		apiClient: apiClient,
+		agentID:   agentID,
+		pool:      pool,
+		running:   make(map[string]context.CancelCauseFunc),
+		unacked:   make(map[string]*client.TaskResult),
+	}
+}
+
+// start 把任务交给执行池异步执行，重复下发 (例如重连后) 的正在执行或排队中的任务会被忽略
+// 排队中的任务同样可以被取消，轮到它执行时会立即以已取消结束
+func (r *runner) start(task *client.Task) {
+	r.mu.Lock()
+	if _, ok := r.running[task.ID]; ok {
+		r.mu.Unlock()
+		log.Printf("Task %s is already running, ignoring duplicate delivery.", task.ID)
+		return
+	}
+	// 任务不跟随接收循环的 ctx，Agent 切换连接方式时正在执行的任务不受影响
+	ctx, cancel := context.WithCancelCause(context.Background())
+	r.running[task.ID] = cancel
+	r.mu.Unlock()
+
+	r.pool.submit(task, func() {
+		result := executor.Execute(ctx, r.agentID, task, r.sendOutput, r.apiClient)
+		r.mu.Lock()
+		delete(r.running, task.ID)
+		r.mu.Unlock()
+		cancel(nil)
+		r.report(&result)
+	})
+}
+
+// cancel 终止一个正在执行的任务，任务仍会上报 (已取消的) 结果
+func (r *runner) cancel(taskID, reason string) {
+	r.mu.Lock()
+	cancel, ok := r.running[taskID]
+	r.mu.Unlock()
+	if !ok {
+		log.Printf("Received cancellation for task %s which is not running.", taskID)
+		return
+	}
+	log.Printf("Cancelling task %s: %s", taskID, reason)
+	cancel(errors.New(reason))
+}
+
+// sendOutput 上报任务执行过程中的输出，优先使用持久连接
+// 输出只用于实时查看，上报失败只记录日志，完整输出仍随任务结果上报
+func (r *runner) sendOutput(chunks []client.OutputChunk) {
+	r.mu.Lock()
+	stream := r.stream
+	r.mu.Unlock()
+
+	if stream != nil {
+		err := stream.Send(client.StreamMessage{Type: client.StreamMsgOutput, Output: chunks})
+		if err == nil {
+			return
+		}
+		log.Printf("WARN: Failed to send task output via stream: %v", err)
+	}
+	if err := r.apiClient.PostOutput(r.agentID, chunks); err != nil {
+		log.Printf("WARN: Failed to post task output: %v", err)
+	}
+}
+
+// report 上报任务结果，优先使用持久连接
+func (r *runner) report(result *client.TaskResult) {
+	r.mu.Lock()
+	stream := r.stream
+	if stream != nil {
+		r.unacked[result.TaskID] = result
+	}
+	r.mu.Unlock()
+
+	if stream != nil {
+		err := stream.Send(client.StreamMessage{Type: client.StreamMsgResult, Result: result})
+		if err == nil {
+			return
+		}
+		// 发送失败说明连接已经断开，detachStream 会通过 HTTP 重新上报
+		log.Printf("WARN: Failed to send task result via stream: %v", err)
+		return
+	}
+	r.postResult(result)
+}
+
+func (r *runner) postResult(result *client.TaskResult) {
+	if err := r.apiClient.PostResult(*result); err != nil {
+		log.Printf("ERROR: Failed to post task result: %v", err)
+	} else {
+		log.Printf("Task result posted successfully: ID=%s", result.TaskID)
+	}
+}
+
+func (r *runner) ackResult(taskID string) {
+	r.mu.Lock()
+	delete(r.unacked, taskID)
+	r.mu.Unlock()
+	log.Printf("Task result acknowledged: ID=%s", taskID)
+}
+
+func (r *runner) attachStream(stream *client.Stream) {
+	r.mu.Lock()
+	r.stream = stream
+	r.mu.Unlock()
+}
+
+// detachStream 在持久连接断开后调用，后端没有确认的结果通过 HTTP 重新上报
+// 后端已经处理过的结果会因为工作流已经前进而被忽略
+func (r *runner) detachStream() {
+	r.mu.Lock()
+	r.stream = nil
+	pending := make([]*client.TaskResult, 0, len(r.unacked))
+	for taskID, result := range r.unacked {
+		pending = append(pending, result)
+		delete(r.unacked, taskID)
+	}
+	r.mu.Unlock()
+
+	for _, result := range pending {
+		r.postResult(result)
+	}
+}
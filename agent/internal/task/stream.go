@@ -0,0 +1,95 @@
+package task
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/GenJi77JYXC/intelligent-pioneer/agent/internal/client"
+)
+
+// streamHeartbeatInterval 是持久连接上的心跳间隔，必须小于后端的读超时 (90s)
+const streamHeartbeatInterval = 30 * time.Second
+
+// runStream 通过持久连接接收任务，直到连接断开或 ctx 结束
+// connected 表示连接是否曾经建立成功，调用方据此决定重连还是回退到长轮询
+func (r *runner) runStream(ctx context.Context, maxTasks int) (connected bool, err error) {
+	stream, err := r.apiClient.OpenStream(ctx, r.agentID, maxTasks)
+	if err != nil {
+		return false, err
+	}
+	log.Println("Task stream connected.")
+	r.attachStream(stream)
+	defer r.detachStream()
+	defer stream.Close()
+
+	// ctx 结束时关闭连接，让 Receive 返回
+	done := make(chan struct{})
+	defer close(done)
+	// 执行池的容量变化时 (接收或执行完任务) 通知后端，后端不会下发超过容量的任务
+	go func() {
+		ticker := time.NewTicker(streamHeartbeatInterval)
+		defer ticker.Stop()
+		for {
+			changed := r.pool.changes()
+			select {
+			case <-ticker.C:
+				if err := stream.Send(r.capacityMessage(client.StreamMsgHeartbeat)); err != nil {
+					log.Printf("ERROR: Failed to send heartbeat via stream: %v", err)
+					stream.Close()
+					return
+				}
+			case <-changed:
+				if err := stream.Send(r.capacityMessage(client.StreamMsgCapacity)); err != nil {
+					log.Printf("ERROR: Failed to send capacity via stream: %v", err)
+					stream.Close()
+					return
+				}
+			case <-ctx.Done():
+				stream.Close()
+				return
+			case <-done:
+				return
+			}
+		}
+	}()
+	if err := stream.Send(r.capacityMessage(client.StreamMsgHeartbeat)); err != nil {
+		return true, err
+	}
+
+	for {
+		msg, err := stream.Receive()
+		if err != nil {
+			return true, err
+		}
+
+		switch msg.Type {
+		case client.StreamMsgTasks:
+			taskIDs := make([]string, 0, len(msg.Tasks))
+			for i := range msg.Tasks {
+				taskIDs = append(taskIDs, msg.Tasks[i].ID)
+			}
+			// 先确认再执行，后端不会把已确认的任务重新放回队列
+			if err := stream.Send(client.StreamMessage{Type: client.StreamMsgAck, TaskIDs: taskIDs}); err != nil {
+				return true, err
+			}
+			for i := range msg.Tasks {
+				task := &msg.Tasks[i]
+				log.Printf("New task received via stream: ID=%s, Command=%s", task.ID, task.Command)
+				r.start(task)
+			}
+		case client.StreamMsgCancel:
+			r.cancel(msg.TaskID, msg.Reason)
+		case client.StreamMsgResultAck:
+			r.ackResult(msg.TaskID)
+		default:
+			log.Printf("WARN: Unknown message type from task stream: %s", msg.Type)
+		}
+	}
+}
+
+// capacityMessage 构造携带当前执行容量的消息 (心跳或 capacity)
+func (r *runner) capacityMessage(msgType string) client.StreamMessage {
+	capacity := r.pool.Capacity()
+	return client.StreamMessage{Type: msgType, Capacity: &capacity}
+}
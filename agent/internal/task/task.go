@@ -6,11 +6,52 @@ import (
 	"time"
 
 	"github.com/GenJi77JYXC/intelligent-pioneer/agent/internal/client"
-	"github.com/GenJi77JYXC/intelligent-pioneer/agent/internal/executor"
+	"github.com/GenJi77JYXC/intelligent-pioneer/agent/internal/config"
 )
 
-// StartPolling 启动任务拉取循环
-func StartPolling(ctx context.Context, apiClient *client.APIClient, agentID string) {
+// streamFallbackInterval 是 auto 模式下持久连接不可用时，回退到长轮询多久后再尝试建立持久连接
+const streamFallbackInterval = 5 * time.Minute
+
+// Run 启动任务接收循环，每次最多接收 maxTasks 个任务，接收到的任务交给 pool 执行
+// pool 饱和 (执行槽位和本地队列都已占满) 时停止接收任务，直到有任务执行结束
+// transport 为 config.TransportAuto 时优先使用持久连接，连接失败时回退到长轮询并定期重试
+func Run(ctx context.Context, apiClient *client.APIClient, agentID string, maxTasks int, transport string, pool *Pool) {
+	r := newRunner(apiClient, agentID, pool)
+	switch transport {
+	case config.TransportPoll:
+		r.poll(ctx, maxTasks)
+		return
+	case config.TransportStream, config.TransportAuto, "":
+	default:
+		log.Printf("WARN: Unknown transport %q, using %q", transport, config.TransportAuto)
+		transport = config.TransportAuto
+	}
+
+	for ctx.Err() == nil {
+		connected, err := r.runStream(ctx, maxTasks)
+		if ctx.Err() != nil {
+			break
+		}
+		switch {
+		case connected:
+			// 已建立的连接断开，通常是网络抖动或后端重启，立即重连
+			log.Printf("WARN: Task stream disconnected: %v. Reconnecting...", err)
+			time.Sleep(time.Second)
+		case transport == config.TransportStream:
+			log.Printf("ERROR: Failed to open task stream: %v. Retrying in 10s...", err)
+			time.Sleep(10 * time.Second)
+		default:
+			log.Printf("WARN: Task stream unavailable: %v. Falling back to long polling for %s.", err, streamFallbackInterval)
+			pollCtx, cancel := context.WithTimeout(ctx, streamFallbackInterval)
+			r.poll(pollCtx, maxTasks)
+			cancel()
+		}
+	}
+	log.Println("Task service stopped.")
+}
+
+// poll 通过长轮询接收任务，直到 ctx 结束
+func (r *runner) poll(ctx context.Context, maxTasks int) {
 	log.Println("Task polling service started.")
 	for {
 		select {
@@ -18,29 +59,31 @@ func StartPolling(ctx context.Context, apiClient *client.APIClient, agentID stri
 			log.Println("Task polling service stopped.")
 			return
 		default:
+			available := r.pool.available()
+			if available == 0 {
+				log.Println("Task pool is saturated, pausing polling.")
+				if !r.pool.waitAvailable(ctx) {
+					continue
+				}
+				available = r.pool.available()
+			}
 			log.Println("Polling for new tasks...")
-			task, err := apiClient.FetchTasks(ctx, agentID)
+			tasks, err := r.apiClient.FetchTasks(ctx, r.agentID, min(maxTasks, available))
 			if err != nil {
-				if ctx.Err() == context.Canceled {
-					// 如果是主动取消，则正常退出
-					return
+				if ctx.Err() != nil {
+					// 如果是主动取消或回退时间到了，则正常退出
+					continue
 				}
 				log.Printf("ERROR: Failed to fetch tasks: %v. Retrying in 10s...", err)
 				time.Sleep(10 * time.Second)
 				continue
 			}
 
-			if task != nil {
+			for i := range tasks {
+				task := &tasks[i]
 				log.Printf("New task received: ID=%s, Command=%s", task.ID, task.Command)
 				// 异步执行任务，避免阻塞任务拉取循环
-				go func(t *client.Task) {
-					result := executor.Execute(agentID, t)
-					if err := apiClient.PostResult(result); err != nil {
-						log.Printf("ERROR: Failed to post task result: %v", err)
-					} else {
-						log.Printf("Task result posted successfully: ID=%s", t.ID)
-					}
-				}(task)
+				r.start(task)
 			}
 		}
 	}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"github.com/GenJi77JYXC/intelligent-pioneer/internal/api"
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/blob"
 	"github.com/GenJi77JYXC/intelligent-pioneer/internal/config"
 	"github.com/GenJi77JYXC/intelligent-pioneer/internal/core/engine"
 	"github.com/GenJi77JYXC/intelligent-pioneer/internal/logger"
@@ -39,6 +40,8 @@ func main() {
 	// 3. Initialize database connections (PostgreSQL, Elasticsearch)
 	store.InitPostgres()
 	store.InitElasticsearch()
+	// 文件存储 (file_push / file_pull 任务传输的文件)
+	blob.Init()
 	// 4. Initialize message queue producer/consumer (Kafka)
 	mq.InitKafka()
 	// 示例：启动后发送一条测试消息
@@ -0,0 +1,85 @@
+// kb-simulate 在本地离线模拟一个知识库工作流，不需要连接数据库、ES 或 Agent
+//
+// 用法:
+//
+//	kb-simulate -kb kb_item.json -results results.json [-json]
+//
+// kb_item.json 是知识库条目 (与 ES 中存储的格式相同)，
+// results.json 是按下发顺序排列的任务结果数组，例如 [{"success": true, "output": "..."}]
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/core/engine"
+)
+
+func main() {
+	kbPath := flag.String("kb", "", "path to the KB item JSON file (required)")
+	resultsPath := flag.String("results", "", "path to the JSON array of scripted task results")
+	asJSON := flag.Bool("json", false, "print the simulation result as JSON")
+	flag.Parse()
+
+	if *kbPath == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var kbItem engine.KnowledgeBaseItem
+	if err := readJSONFile(*kbPath, &kbItem); err != nil {
+		fatalf("read KB item: %v", err)
+	}
+	var results []engine.TaskResult
+	if *resultsPath != "" {
+		if err := readJSONFile(*resultsPath, &results); err != nil {
+			fatalf("read results: %v", err)
+		}
+	}
+
+	simulation, err := engine.Simulate(&kbItem, results)
+	if err != nil {
+		fatalf("simulate: %v", err)
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		_ = encoder.Encode(simulation)
+		return
+	}
+	printSimulation(simulation)
+}
+
+func printSimulation(simulation *engine.SimulationResult) {
+	for i, step := range simulation.Steps {
+		fmt.Printf("%d. %s -> %s (%s)\n", i+1, step.FromStatus, step.ToStatus, step.Reason)
+		if step.Result != nil {
+			fmt.Printf("   result: success=%t exit_code=%d\n", step.Result.Success, step.Result.ExitCode)
+		}
+		if step.Task != nil {
+			fmt.Printf("   send %s: %s\n", step.Task.Type, step.Task.Command)
+		}
+	}
+	fmt.Println()
+	fmt.Printf("final status: %s\n", simulation.FinalStatus)
+	if simulation.UnusedResults > 0 {
+		fmt.Printf("unused results: %d\n", simulation.UnusedResults)
+	}
+	fmt.Println(simulation.Message)
+}
+
+func readJSONFile(path string, v interface{}) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(content, v)
+}
+
+func fatalf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "kb-simulate: "+format+"\n", args...)
+	os.Exit(1)
+}
@@ -1,7 +1,9 @@
 package api
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/GenJi77JYXC/intelligent-pioneer/internal/core/engine"
@@ -27,7 +29,13 @@ func RegisterAgent(c *gin.Context) {
 		"hostname", req.Hostname,
 		"ip_address", req.IPAddress,
 		"os", req.OS,
+		"capabilities", req.Capabilities,
 	)
+	capabilities, err := engine.FormatCapabilities(req.Capabilities)
+	if err != nil {
+		ParamError(c, err.Error())
+		return
+	}
 
 	// 2. 检查该 Agent 是否已经注册过 (基于某些唯一标识，例如 Hostname + IP)
 	// 这是一个可选的健壮性设计，MVP 阶段可以先简化
@@ -37,6 +45,11 @@ func RegisterAgent(c *gin.Context) {
 	if result.Error == nil {
 		// 如果找到了记录，说明已经注册过，直接返回已有的 ID
 		logger.L.Infow("Agent already registered, returning existing ID", "agent_id", existingAgent.UUID)
+		if req.Capabilities != nil && capabilities != existingAgent.Capabilities {
+			if err := store.DB.Model(&existingAgent).UpdateColumn("capabilities", capabilities).Error; err != nil {
+				logger.L.Errorw("Failed to update agent capabilities", "agent_id", existingAgent.UUID, "error", err)
+			}
+		}
 		Result(c, http.StatusOK, "Agent already registered.", RegisterAgentResponse{
 			AgentID: existingAgent.UUID,
 			Message: "Agent already registered.",
@@ -56,7 +69,10 @@ func RegisterAgent(c *gin.Context) {
 		Hostname:  req.Hostname,
 		IPAddress: req.IPAddress,
 		OS:        req.OS,
+		Group:     req.Group,
 		Status:    "offline", // 初始状态为离线，等待心跳
+
+		Capabilities: capabilities,
 	}
 	newAgent.CreatedAt = time.Now() // 手动设置时间或让 GORM 自动处理
 	newAgent.UpdatedAt = time.Now()
@@ -90,24 +106,17 @@ func Heartbeat(c *gin.Context) {
 	}
 
 	// 2. 在数据库中更新 Agent 状态和时间戳
-	// 我们使用 GORM 的 Updates 方法，它只会更新指定的字段，效率更高
-	// 并且我们只更新 UUID 匹配的记录
-	updateData := map[string]interface{}{
-		"status":     "online",
-		"updated_at": time.Now(), // GORM 会自动处理 updated_at, 但手动更新更明确
-	}
-
-	result := store.DB.Model(&model.Agent{}).Where("uuid = ?", req.AgentID).Updates(updateData)
+	found, err := markAgentOnline(req.AgentID, req.Capacity)
 
 	// 3. 检查更新操作的结果
-	if result.Error != nil {
-		logger.L.Errorw("Failed to update agent heartbeat in database", "agent_id", req.AgentID, "error", result.Error)
+	if err != nil {
+		logger.L.Errorw("Failed to update agent heartbeat in database", "agent_id", req.AgentID, "error", err)
 		Result(c, http.StatusInternalServerError, "Database error", gin.H{"error": "Database error"})
 		return
 	}
 
-	// RowsAffected 返回受影响的行数。如果为 0，说明没有找到对应的 Agent
-	if result.RowsAffected == 0 {
+	// 没有找到对应的 Agent
+	if !found {
 		logger.L.Warnw("Heartbeat received from an unknown or unregistered agent", "agent_id", req.AgentID)
 		Result(c, http.StatusInternalServerError, "Database error", gin.H{"error": "Database error"})
 		return
@@ -121,12 +130,66 @@ func Heartbeat(c *gin.Context) {
 	logger.L.Info("Received agent heartbeat")
 }
 
-// GetTasks 处理 Agent 获取任务的长轮询请求
-func GetTasks(c *gin.Context) {
-	// 1. 从查询参数中获取 agent_id
-	agentID := c.Query("agent_id")
+// markAgentOnline 记录一次心跳: 把 Agent 标记为在线并刷新最后心跳时间，Agent 不存在时返回 false
+// HTTP 心跳和持久连接上的心跳共用这里的逻辑，capacity 不为空时同时更新 Agent 的任务执行容量
+func markAgentOnline(agentID string, capacity *AgentCapacity) (bool, error) {
+	// 我们使用 GORM 的 Updates 方法，它只会更新指定的字段，效率更高
+	// 并且我们只更新 UUID 匹配的记录
+	updateData := map[string]interface{}{
+		"status":     "online",
+		"updated_at": time.Now(), // GORM 会自动处理 updated_at, 但手动更新更明确
+	}
+	if capacity != nil {
+		updateData["max_concurrency"] = capacity.MaxConcurrency
+		updateData["queue_size"] = capacity.QueueSize
+		updateData["running_tasks"] = capacity.Running
+		updateData["queued_tasks"] = capacity.Queued
+	}
+	result := store.DB.Model(&model.Agent{}).Where("uuid = ?", agentID).Updates(updateData)
+	// RowsAffected 返回受影响的行数。如果为 0，说明没有找到对应的 Agent
+	return result.RowsAffected > 0, result.Error
+}
+
+// bindTaskPollParams 解析拉取任务的 agent_id 和 max 参数，并确认 Agent 已注册
+// 长轮询和持久连接共用，参数错误时已经写好响应，返回 false
+func bindTaskPollParams(c *gin.Context) (agentID string, limit int, ok bool) {
+	agentID = c.Query("agent_id")
 	if agentID == "" {
 		Result(c, http.StatusBadRequest, "Query parameter 'agent_id' is required.", gin.H{})
+		return "", 0, false
+	}
+	limit = 1
+	if raw := c.Query("max"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			ParamError(c, "max must be a positive integer")
+			return "", 0, false
+		}
+		limit = min(n, engine.TM.MaxBatch())
+	}
+
+	// 只为已注册的 Agent 分配任务队列，避免任意 agent_id 都创建一个永不释放的队列
+	var count int64
+	if err := store.DB.Model(&model.Agent{}).Where("uuid = ?", agentID).Count(&count).Error; err != nil {
+		logger.L.Errorw("Failed to check agent registration", "agent_id", agentID, "error", err)
+		Result(c, http.StatusInternalServerError, "Database error", gin.H{})
+		return "", 0, false
+	}
+	if count == 0 {
+		logger.L.Warnw("Task polling from an unknown or unregistered agent", "agent_id", agentID)
+		Result(c, http.StatusNotFound, "Agent not registered.", gin.H{})
+		return "", 0, false
+	}
+	return agentID, limit, true
+}
+
+// GetTasks 处理 Agent 获取任务的长轮询请求
+// Agent 通过 max 参数请求一次最多拿多少个任务，实际上限取 max 与 task_queue.max_batch 的较小值
+// 超时没有任务时返回空的任务列表；不带 max 参数的旧版本 Agent 仍然使用原来的响应格式，见 writeLegacyTask
+func GetTasks(c *gin.Context) {
+	// 1. 从查询参数中获取 agent_id 和 max
+	agentID, limit, ok := bindTaskPollParams(c)
+	if !ok {
 		return
 	}
 
@@ -136,22 +199,60 @@ func GetTasks(c *gin.Context) {
 	// 2. 调用任务管理器的长轮询方法
 	// 我们在这里设置一个 30 秒的超时时间
 	timeout := 30 * time.Second
-	task := engine.TM.GetTaskForAgent(agentID, timeout)
+	// Agent 断开连接后请求的 context 会结束，不再为它领取任务
+	tasks := engine.TM.GetTasksForAgent(c.Request.Context(), agentID, limit, timeout)
 
 	// 3. 根据结果返回响应
-	if task == nil {
-		// 如果返回 nil，说明是超时，没有任务
-		// 返回 204 No Content 是一个很好的实践，表示请求成功，但没有内容返回
-		logger.L.Debugw("Polling timeout, no tasks for agent", "agent_id", agentID)
-		Result(c, http.StatusNoContent, "查询成功", gin.H{"msg": "表示请求成功，但没有内容返回，返回空任务列表", "tasks": []string{}})
+	if c.Query("max") == "" {
+		writeLegacyTask(c, agentID, tasks)
 		return
+	}
+	if len(tasks) == 0 {
+		// 超时，没有任务，返回空任务列表
+		logger.L.Debugw("Polling timeout, no tasks for agent", "agent_id", agentID)
+		tasks = []*engine.Task{}
 	} else {
-		// 如果获取到了任务，将其序列化为 JSON 返回
-		logger.L.Infow("Dispatched task to agent via polling", "agent_id", agentID, "task_id", task.ID)
-		c.JSON(http.StatusOK, task)
-		Result(c, http.StatusOK, "查询成功", gin.H{"data": task})
+		logger.L.Infow("Dispatched tasks to agent via polling", "agent_id", agentID, "count", len(tasks))
+	}
+	Success(c, gin.H{"tasks": tasks, "max": limit})
+}
+
+// writeLegacyTask 按旧版本 Agent 能识别的格式返回任务: 有任务时直接返回任务对象，没有任务时返回 HTTP 204
+// 不带 max 参数时 limit 为 1，最多只有一个任务
+func writeLegacyTask(c *gin.Context, agentID string, tasks []*engine.Task) {
+	if len(tasks) == 0 {
+		logger.L.Debugw("Polling timeout, no tasks for agent", "agent_id", agentID)
+		c.Status(http.StatusNoContent)
+		return
+	}
+	logger.L.Infow("Dispatched task to legacy agent via polling", "agent_id", agentID, "task_id", tasks[0].ID)
+	c.JSON(http.StatusOK, tasks[0])
+}
+
+// validAgentResultStatus 判断 Agent 上报的结果状态是否合法，"expired" 只能由调度方产生
+func validAgentResultStatus(status string) bool {
+	return status == "" || status == engine.TaskResultTimedOut || status == engine.TaskResultRejected
+}
+
+// PostTaskOutput 处理 Agent 上报任务执行过程中输出的请求
+// 这是持久连接不可用时的回退方式，Agent 重试上报相同的分段不会重复保存
+func PostTaskOutput(c *gin.Context) {
+	var req PostTaskOutputRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ParamError(c, err.Error())
 		return
 	}
+
+	if err := engine.RecordTaskOutput(req.AgentID, req.Chunks); err != nil {
+		logger.L.Warnw("Failed to record task output", "agent_id", req.AgentID, "error", err)
+		if errors.Is(err, engine.ErrTaskNotOwned) {
+			Error(c, http.StatusForbidden, err.Error())
+			return
+		}
+		ParamError(c, err.Error())
+		return
+	}
+	Success(c, gin.H{"received": len(req.Chunks)})
 }
 
 // PostTaskResults 处理 Agent 上报任务结果的请求
@@ -169,6 +270,10 @@ func PostTaskResults(c *gin.Context) {
 		Result(c, http.StatusBadRequest, "Invalid request body", gin.H{"error": "Invalid request body"})
 		return
 	}
+	if !validAgentResultStatus(result.Status) {
+		Result(c, http.StatusBadRequest, "Invalid request body", gin.H{"error": "Invalid result status " + result.Status})
+		return
+	}
 
 	logger.L.Infow("Received task result from agent",
 		"agent_id", result.AgentID,
@@ -194,9 +299,20 @@ type AgentInfo struct {
 	Hostname  string    `json:"hostname"`
 	IPAddress string    `json:"ip_address"`
 	OS        string    `json:"os"`
+	Group     string    `json:"group"`
 	Status    string    `json:"status"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"` // 这就是最后心跳时间
+
+	// Capabilities 是 Agent 支持的任务执行方式，旧版本 Agent 为空
+	Capabilities []string `json:"capabilities"`
+	// Capacity 是 Agent 最近一次心跳上报的任务执行容量，旧版本 Agent 为空
+	Capacity *AgentCapacity `json:"capacity"`
+
+	InMaintenance     bool       `json:"in_maintenance"`
+	MaintenanceUntil  *time.Time `json:"maintenance_until"`
+	MaintenanceReason string     `json:"maintenance_reason"`
+	MaintenanceBy     string     `json:"maintenance_by"`
 }
 
 // GetAllAgents 获取所有已注册的 Agent
@@ -215,6 +331,7 @@ func GetAllAgents(c *gin.Context) {
 
 	// 将数据库模型转换为对外的 DTO (Data Transfer Object)
 	var agentInfos []AgentInfo
+	now := time.Now()
 	for _, agent := range agents {
 		agentInfos = append(agentInfos, AgentInfo{
 			ID:        agent.ID,
@@ -222,11 +339,142 @@ func GetAllAgents(c *gin.Context) {
 			Hostname:  agent.Hostname,
 			IPAddress: agent.IPAddress,
 			OS:        agent.OS,
+			Group:     agent.Group,
 			Status:    agent.Status,
 			CreatedAt: agent.CreatedAt,
 			UpdatedAt: agent.UpdatedAt,
+
+			Capabilities: engine.AgentCapabilities(&agent),
+			Capacity:     agentCapacity(&agent),
+
+			InMaintenance:     agent.InMaintenance(now),
+			MaintenanceUntil:  agent.MaintenanceUntil,
+			MaintenanceReason: agent.MaintenanceReason,
+			MaintenanceBy:     agent.MaintenanceBy,
 		})
 	}
 
 	Success(c, agentInfos)
 }
+
+// agentCapacity 返回 Agent 最近一次上报的任务执行容量，没有上报过时返回 nil
+func agentCapacity(agent *model.Agent) *AgentCapacity {
+	if agent.MaxConcurrency == 0 {
+		return nil
+	}
+	return &AgentCapacity{
+		MaxConcurrency: agent.MaxConcurrency,
+		QueueSize:      agent.QueueSize,
+		Running:        agent.RunningTasks,
+		Queued:         agent.QueuedTasks,
+		Available:      max(agent.MaxConcurrency+agent.QueueSize-agent.RunningTasks-agent.QueuedTasks, 0),
+	}
+}
+
+// UpdateAgentGroup 修改 Agent 所属的分组
+func UpdateAgentGroup(c *gin.Context) {
+	var req UpdateAgentGroupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ParamError(c, err.Error())
+		return
+	}
+
+	agentID := c.Param("id")
+	result := store.DB.Model(&model.Agent{}).Where("uuid = ?", agentID).UpdateColumn("agent_group", req.Group)
+	if result.Error != nil {
+		logger.L.Errorw("Failed to update agent group", "agent_id", agentID, "error", result.Error)
+		Error(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if result.RowsAffected == 0 {
+		Error(c, http.StatusNotFound, "Agent not found.")
+		return
+	}
+
+	logger.L.Infow("Agent group updated", "agent_id", agentID, "group", req.Group)
+	Success(c, gin.H{"agent_id": agentID, "group": req.Group})
+}
+
+// UpdateAgentCapabilities 处理 Agent 上报支持的任务执行方式的请求，Agent 每次启动时调用
+func UpdateAgentCapabilities(c *gin.Context) {
+	var req UpdateAgentCapabilitiesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ParamError(c, err.Error())
+		return
+	}
+	capabilities, err := engine.FormatCapabilities(req.Capabilities)
+	if err != nil {
+		ParamError(c, err.Error())
+		return
+	}
+
+	agentID := c.Param("id")
+	result := store.DB.Model(&model.Agent{}).Where("uuid = ?", agentID).UpdateColumn("capabilities", capabilities)
+	if result.Error != nil {
+		logger.L.Errorw("Failed to update agent capabilities", "agent_id", agentID, "error", result.Error)
+		Error(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if result.RowsAffected == 0 {
+		Error(c, http.StatusNotFound, "Agent not found.")
+		return
+	}
+
+	logger.L.Infow("Agent capabilities updated", "agent_id", agentID, "capabilities", capabilities)
+	Success(c, gin.H{"agent_id": agentID, "capabilities": engine.AgentCapabilities(&model.Agent{Capabilities: capabilities})})
+}
+
+// SetAgentMaintenance 让 Agent 进入维护模式，期间暂停针对它的所有自动化
+func SetAgentMaintenance(c *gin.Context) {
+	var req AgentMaintenanceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ParamError(c, err.Error())
+		return
+	}
+
+	until := time.Now().Add(1 * time.Hour)
+	switch {
+	case req.Until != nil:
+		until = *req.Until
+	case req.Duration != "":
+		duration, err := time.ParseDuration(req.Duration)
+		if err != nil || duration <= 0 {
+			ParamError(c, "invalid duration")
+			return
+		}
+		until = time.Now().Add(duration)
+	}
+	if !until.After(time.Now()) {
+		ParamError(c, "maintenance end time must be in the future")
+		return
+	}
+
+	agentID := c.Param("id")
+	if err := engine.SetAgentMaintenance(agentID, until, req.Reason, req.SetBy); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			Error(c, http.StatusNotFound, "Agent not found.")
+			return
+		}
+		logger.L.Errorw("Failed to set agent maintenance mode", "agent_id", agentID, "error", err)
+		Error(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	Success(c, gin.H{"agent_id": agentID, "maintenance_until": until, "maintenance_reason": req.Reason})
+}
+
+// ClearAgentMaintenance 提前结束 Agent 的维护模式
+func ClearAgentMaintenance(c *gin.Context) {
+	agentID := c.Param("id")
+	if err := engine.ClearAgentMaintenance(agentID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			Error(c, http.StatusNotFound, "Agent not found.")
+			return
+		}
+		logger.L.Errorw("Failed to clear agent maintenance mode", "agent_id", agentID, "error", err)
+		Error(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	Success(c, gin.H{"agent_id": agentID, "status": "maintenance cleared"})
+}
@@ -0,0 +1,72 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/core/engine"
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/logger"
+	"github.com/gin-gonic/gin"
+)
+
+// ListKBStats 查询所有知识库条目的效果指标
+// 支持 window (例如 "168h"，默认取配置 analytics.window) 和 flagged=true (只返回被标记的条目)
+func ListKBStats(c *gin.Context) {
+	query, ok := parseKBStatsQuery(c)
+	if !ok {
+		return
+	}
+
+	stats, err := engine.ComputeKBStats(query)
+	if err != nil {
+		logger.L.Errorw("Failed to compute KB stats", "error", err)
+		Error(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	if c.Query("flagged") == "true" {
+		flagged := make([]engine.KBStats, 0)
+		for _, s := range stats {
+			if s.Flagged {
+				flagged = append(flagged, s)
+			}
+		}
+		stats = flagged
+	}
+	Success(c, stats)
+}
+
+// GetKBStats 查询单个知识库条目的效果指标
+func GetKBStats(c *gin.Context) {
+	query, ok := parseKBStatsQuery(c)
+	if !ok {
+		return
+	}
+	query.KBID = c.Param("kb_id")
+
+	stats, err := engine.ComputeKBStats(query)
+	if err != nil {
+		logger.L.Errorw("Failed to compute KB stats", "kb_id", query.KBID, "error", err)
+		Error(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if len(stats) == 0 {
+		Error(c, http.StatusNotFound, "No workflows found for this KB item in the given window.")
+		return
+	}
+	Success(c, stats[0])
+}
+
+// parseKBStatsQuery 解析统计时间范围，解析失败时直接写入错误响应
+func parseKBStatsQuery(c *gin.Context) (engine.KBStatsQuery, bool) {
+	query := engine.KBStatsQuery{Since: engine.DefaultAnalyticsSince()}
+	if window := c.Query("window"); window != "" {
+		d, err := time.ParseDuration(window)
+		if err != nil || d <= 0 {
+			ParamError(c, "window must be a positive duration, e.g. 168h")
+			return query, false
+		}
+		query.Since = time.Now().Add(-d)
+	}
+	return query, true
+}
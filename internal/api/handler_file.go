@@ -0,0 +1,243 @@
+package api
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/core/engine"
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/logger"
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/model"
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/store"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ChunkSHA256Header 是文件分块 SHA-256 (十六进制) 所在的请求/响应头
+const ChunkSHA256Header = "X-Chunk-SHA256"
+
+type FileInfo struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Size      int64     `json:"size"`
+	SHA256    string    `json:"sha256"`
+	Source    string    `json:"source"`
+	AgentID   string    `json:"agent_id,omitempty"`
+	TaskID    string    `json:"task_id,omitempty"`
+	Path      string    `json:"path,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func toFileInfo(file model.File) FileInfo {
+	return FileInfo{
+		ID:        file.ID,
+		Name:      file.Name,
+		Size:      file.Size,
+		SHA256:    file.SHA256,
+		Source:    file.Source,
+		AgentID:   file.AgentID,
+		TaskID:    file.TaskID,
+		Path:      file.Path,
+		CreatedAt: file.CreatedAt,
+	}
+}
+
+// UploadFile 上传一个文件 (multipart 表单字段 "file")，知识库步骤可以通过 {"file_push": "<id>"} 把它下发到 Agent
+func UploadFile(c *gin.Context) {
+	header, err := c.FormFile("file")
+	if err != nil {
+		ParamError(c, "missing file: "+err.Error())
+		return
+	}
+	if header.Size > engine.MaxFileSize() {
+		ParamError(c, "file exceeds "+strconv.FormatInt(engine.MaxFileSize(), 10)+" bytes")
+		return
+	}
+	src, err := header.Open()
+	if err != nil {
+		ParamError(c, err.Error())
+		return
+	}
+	defer src.Close()
+
+	file, err := engine.SaveFile(c.Request.Context(), model.File{Name: header.Filename, Source: engine.FileSourceUpload}, src)
+	if err != nil {
+		if errors.Is(err, engine.ErrFileTooLarge) {
+			ParamError(c, err.Error())
+			return
+		}
+		logger.L.Errorw("Failed to save uploaded file", "name", header.Filename, "error", err)
+		Error(c, http.StatusInternalServerError, "Failed to save file")
+		return
+	}
+
+	logger.L.Infow("File uploaded", "file_id", file.ID, "name", file.Name, "size", file.Size, "sha256", file.SHA256)
+	Success(c, toFileInfo(*file))
+}
+
+// ListFiles 查询文件，可以按 task_id、agent_id 过滤 (例如查询某个 file_pull 任务收集到的文件)
+func ListFiles(c *gin.Context) {
+	query := store.DB.Order("created_at desc")
+	if taskID := c.Query("task_id"); taskID != "" {
+		query = query.Where("task_id = ?", taskID)
+	}
+	if agentID := c.Query("agent_id"); agentID != "" {
+		query = query.Where("agent_id = ?", agentID)
+	}
+
+	var files []model.File
+	if err := query.Find(&files).Error; err != nil {
+		logger.L.Errorw("Failed to list files", "error", err)
+		Error(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	fileInfos := make([]FileInfo, 0, len(files))
+	for _, file := range files {
+		fileInfos = append(fileInfos, toFileInfo(file))
+	}
+	Success(c, fileInfos)
+}
+
+// GetFile 查询单个文件的元数据
+func GetFile(c *gin.Context) {
+	file, ok := findFile(c)
+	if !ok {
+		return
+	}
+	Success(c, toFileInfo(*file))
+}
+
+// DownloadFile 下载文件内容
+func DownloadFile(c *gin.Context) {
+	file, ok := findFile(c)
+	if !ok {
+		return
+	}
+
+	content, err := engine.OpenFile(c.Request.Context(), file)
+	if err != nil {
+		logger.L.Errorw("Failed to open file content", "file_id", file.ID, "error", err)
+		Error(c, http.StatusInternalServerError, "Failed to read file")
+		return
+	}
+	defer content.Close()
+
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": file.Name}))
+	c.DataFromReader(http.StatusOK, file.Size, "application/octet-stream", content, map[string]string{
+		"X-File-SHA256": file.SHA256,
+	})
+}
+
+// DeleteFile 删除文件，之后引用它的 file_push 步骤会让工作流失败
+func DeleteFile(c *gin.Context) {
+	file, ok := findFile(c)
+	if !ok {
+		return
+	}
+
+	if err := engine.DeleteFile(c.Request.Context(), file); err != nil {
+		logger.L.Errorw("Failed to delete file", "file_id", file.ID, "error", err)
+		Error(c, http.StatusInternalServerError, "Failed to delete file")
+		return
+	}
+
+	logger.L.Infow("File deleted", "file_id", file.ID, "name", file.Name)
+	Success(c, gin.H{"status": "deleted"})
+}
+
+// findFile 根据路径参数 :id 查询文件，找不到时直接写入错误响应
+func findFile(c *gin.Context) (*model.File, bool) {
+	id := c.Param("id")
+	var file model.File
+	if err := store.DB.Where("id = ?", id).First(&file).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			Error(c, http.StatusNotFound, "File not found.")
+			return nil, false
+		}
+		logger.L.Errorw("Failed to get file", "file_id", id, "error", err)
+		Error(c, http.StatusInternalServerError, "Database error")
+		return nil, false
+	}
+	return &file, true
+}
+
+// DownloadTaskFileChunk 处理 Agent 下载 file_push 任务文件分块的请求
+// 成功时响应体是分块的原始内容，分块的 SHA-256 在 X-Chunk-SHA256 响应头中；失败时与其他接口一样返回 JSON
+func DownloadTaskFileChunk(c *gin.Context) {
+	agentID := c.Query("agent_id")
+	offset, err1 := strconv.ParseInt(c.Query("offset"), 10, 64)
+	length, err2 := strconv.ParseInt(c.Query("length"), 10, 64)
+	if agentID == "" || err1 != nil || err2 != nil {
+		ParamError(c, "agent_id, offset and length are required")
+		return
+	}
+
+	data, checksum, err := engine.ReadFileChunk(c.Request.Context(), agentID, c.Param("id"), offset, length)
+	if err != nil {
+		fileTransferError(c, agentID, err)
+		return
+	}
+	c.Header(ChunkSHA256Header, checksum)
+	c.Data(http.StatusOK, "application/octet-stream", data)
+}
+
+// UploadTaskFileChunk 处理 Agent 上传 file_pull 任务文件分块的请求，请求体是分块的原始内容
+func UploadTaskFileChunk(c *gin.Context) {
+	agentID := c.Query("agent_id")
+	offset, err := strconv.ParseInt(c.Query("offset"), 10, 64)
+	checksum := c.GetHeader(ChunkSHA256Header)
+	if agentID == "" || err != nil || checksum == "" {
+		ParamError(c, "agent_id, offset and the "+ChunkSHA256Header+" header are required")
+		return
+	}
+
+	data, err := io.ReadAll(io.LimitReader(c.Request.Body, engine.MaxFileChunkSize+1))
+	if err != nil {
+		ParamError(c, err.Error())
+		return
+	}
+	if err := engine.ReceiveFileChunk(c.Request.Context(), agentID, c.Param("id"), offset, data, checksum); err != nil {
+		fileTransferError(c, agentID, err)
+		return
+	}
+	Success(c, gin.H{"received": len(data)})
+}
+
+// CompleteTaskFileUpload 处理 Agent 完成 file_pull 任务文件上传的请求，返回保存的文件
+func CompleteTaskFileUpload(c *gin.Context) {
+	var req CompleteFileUploadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ParamError(c, err.Error())
+		return
+	}
+
+	file, err := engine.CompleteFileUpload(c.Request.Context(), req.AgentID, c.Param("id"), req.Name, req.Path, req.Size, req.SHA256)
+	if err != nil {
+		fileTransferError(c, req.AgentID, err)
+		return
+	}
+	logger.L.Infow("File collected from agent", "agent_id", req.AgentID, "task_id", c.Param("id"), "file_id", file.ID, "size", file.Size)
+	Success(c, toFileInfo(*file))
+}
+
+func fileTransferError(c *gin.Context, agentID string, err error) {
+	logger.L.Warnw("File transfer request failed", "agent_id", agentID, "task_id", c.Param("id"), "error", err)
+	switch {
+	case errors.Is(err, engine.ErrInvalidFileTask):
+		Error(c, http.StatusForbidden, err.Error())
+	case errors.Is(err, engine.ErrFileNotFound):
+		Error(c, http.StatusNotFound, err.Error())
+	case errors.Is(err, engine.ErrFileTooLarge):
+		Error(c, http.StatusRequestEntityTooLarge, err.Error())
+	case errors.Is(err, engine.ErrChecksumMismatch):
+		Error(c, http.StatusUnprocessableEntity, err.Error())
+	case errors.Is(err, engine.ErrInvalidChunk):
+		ParamError(c, err.Error())
+	default:
+		Error(c, http.StatusInternalServerError, "File storage error")
+	}
+}
@@ -0,0 +1,112 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/config"
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/core/engine"
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/logger"
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/model"
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/store"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type GuardrailInfo struct {
+	KBID            string    `json:"kb_id"`
+	MaxConcurrent   int       `json:"max_concurrent"`
+	MaxPerHour      int       `json:"max_per_hour"`
+	MaxGroupPercent int       `json:"max_group_percent"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// GuardrailsOverview 同时返回配置文件中的全局限制和各知识库条目的限制
+type GuardrailsOverview struct {
+	Global config.GuardrailsConfig `json:"global"`
+	KB     []GuardrailInfo         `json:"kb"`
+}
+
+func toGuardrailInfo(guardrail model.KBGuardrail) GuardrailInfo {
+	return GuardrailInfo{
+		KBID:            guardrail.KBID,
+		MaxConcurrent:   guardrail.MaxConcurrent,
+		MaxPerHour:      guardrail.MaxPerHour,
+		MaxGroupPercent: guardrail.MaxGroupPercent,
+		UpdatedAt:       guardrail.UpdatedAt,
+	}
+}
+
+// ListGuardrails 查询所有修复限制
+func ListGuardrails(c *gin.Context) {
+	var guardrails []model.KBGuardrail
+	if err := store.DB.Order("kb_id asc").Find(&guardrails).Error; err != nil {
+		logger.L.Errorw("Failed to list guardrails", "error", err)
+		Error(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	overview := GuardrailsOverview{
+		Global: config.C.Guardrails,
+		KB:     make([]GuardrailInfo, 0, len(guardrails)),
+	}
+	for _, guardrail := range guardrails {
+		overview.KB = append(overview.KB, toGuardrailInfo(guardrail))
+	}
+	Success(c, overview)
+}
+
+// SetKBGuardrail 创建或更新知识库条目的修复限制
+func SetKBGuardrail(c *gin.Context) {
+	var req GuardrailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ParamError(c, err.Error())
+		return
+	}
+
+	kbID := c.Param("kb_id")
+	var guardrail model.KBGuardrail
+	err := store.DB.Where("kb_id = ?", kbID).First(&guardrail).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		logger.L.Errorw("Failed to get guardrail", "kb_id", kbID, "error", err)
+		Error(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	guardrail.KBID = kbID
+	guardrail.MaxConcurrent = req.MaxConcurrent
+	guardrail.MaxPerHour = req.MaxPerHour
+	guardrail.MaxGroupPercent = req.MaxGroupPercent
+	if err := engine.ValidateKBGuardrail(&guardrail); err != nil {
+		ParamError(c, err.Error())
+		return
+	}
+	if err := store.DB.Save(&guardrail).Error; err != nil {
+		logger.L.Errorw("Failed to save guardrail", "kb_id", kbID, "error", err)
+		Error(c, http.StatusInternalServerError, "Failed to save guardrail")
+		return
+	}
+
+	logger.L.Infow("KB guardrail updated", "kb_id", kbID, "max_concurrent", guardrail.MaxConcurrent,
+		"max_per_hour", guardrail.MaxPerHour, "max_group_percent", guardrail.MaxGroupPercent)
+	Success(c, toGuardrailInfo(guardrail))
+}
+
+// DeleteKBGuardrail 删除知识库条目的修复限制，之后只受全局限制约束
+func DeleteKBGuardrail(c *gin.Context) {
+	kbID := c.Param("kb_id")
+	result := store.DB.Where("kb_id = ?", kbID).Delete(&model.KBGuardrail{})
+	if result.Error != nil {
+		logger.L.Errorw("Failed to delete guardrail", "kb_id", kbID, "error", result.Error)
+		Error(c, http.StatusInternalServerError, "Failed to delete guardrail")
+		return
+	}
+	if result.RowsAffected == 0 {
+		Error(c, http.StatusNotFound, "Guardrail not found.")
+		return
+	}
+
+	logger.L.Infow("KB guardrail deleted", "kb_id", kbID)
+	Success(c, gin.H{"status": "deleted"})
+}
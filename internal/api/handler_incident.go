@@ -0,0 +1,321 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/core/engine"
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/logger"
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/model"
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/store"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type IncidentInfo struct {
+	ID             string     `json:"id"`
+	Title          string     `json:"title"`
+	KBID           string     `json:"kb_id"`
+	AlertType      string     `json:"alert_type"`
+	AgentGroup     string     `json:"agent_group"`
+	Status         string     `json:"status"`
+	Severity       string     `json:"severity"`
+	Assignee       string     `json:"assignee"`
+	WorkflowCount  int        `json:"workflow_count"`
+	AlertCount     int        `json:"alert_count"`
+	FirstEventAt   time.Time  `json:"first_event_at"`
+	LastEventAt    time.Time  `json:"last_event_at"`
+	AcknowledgedAt *time.Time `json:"acknowledged_at"`
+	ResolvedAt     *time.Time `json:"resolved_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+type AlertInfo struct {
+	ID         uint      `json:"id"`
+	Type       string    `json:"type"`
+	Source     string    `json:"source"`
+	Severity   string    `json:"severity"`
+	AgentID    string    `json:"agent_id"`
+	AgentGroup string    `json:"agent_group"`
+	KBID       string    `json:"kb_id"`
+	Message    string    `json:"message"`
+	IncidentID string    `json:"incident_id"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type IncidentNoteInfo struct {
+	ID        uint      `json:"id"`
+	Author    string    `json:"author"`
+	Content   string    `json:"content"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// IncidentDetail 是事件详情，包含聚合进来的工作流、告警和备注
+type IncidentDetail struct {
+	IncidentInfo
+	Workflows []WorkflowInfo     `json:"workflows"`
+	Alerts    []AlertInfo        `json:"alerts"`
+	Notes     []IncidentNoteInfo `json:"notes"`
+}
+
+func toIncidentInfo(incident model.Incident) IncidentInfo {
+	return IncidentInfo{
+		ID:             incident.ID,
+		Title:          incident.Title,
+		KBID:           incident.KBID,
+		AlertType:      incident.AlertType,
+		AgentGroup:     incident.AgentGroup,
+		Status:         incident.Status,
+		Severity:       incident.Severity,
+		Assignee:       incident.Assignee,
+		WorkflowCount:  incident.WorkflowCount,
+		AlertCount:     incident.AlertCount,
+		FirstEventAt:   incident.FirstEventAt,
+		LastEventAt:    incident.LastEventAt,
+		AcknowledgedAt: incident.AcknowledgedAt,
+		ResolvedAt:     incident.ResolvedAt,
+		CreatedAt:      incident.CreatedAt,
+	}
+}
+
+func toAlertInfo(alert model.Alert) AlertInfo {
+	return AlertInfo{
+		ID:         alert.ID,
+		Type:       alert.Type,
+		Source:     alert.Source,
+		Severity:   alert.Severity,
+		AgentID:    alert.AgentID,
+		AgentGroup: alert.AgentGroup,
+		KBID:       alert.KBID,
+		Message:    alert.Message,
+		IncidentID: alert.IncidentID,
+		CreatedAt:  alert.CreatedAt,
+	}
+}
+
+// ListIncidents 查询事件列表，支持按 status / kb_id / assignee 过滤
+func ListIncidents(c *gin.Context) {
+	query := store.DB.Order("last_event_at desc")
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if kbID := c.Query("kb_id"); kbID != "" {
+		query = query.Where("kb_id = ?", kbID)
+	}
+	if assignee := c.Query("assignee"); assignee != "" {
+		query = query.Where("assignee = ?", assignee)
+	}
+
+	var incidents []model.Incident
+	if err := query.Limit(200).Find(&incidents).Error; err != nil {
+		logger.L.Errorw("Failed to list incidents", "error", err)
+		Error(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	incidentInfos := make([]IncidentInfo, 0, len(incidents))
+	for _, incident := range incidents {
+		incidentInfos = append(incidentInfos, toIncidentInfo(incident))
+	}
+	Success(c, incidentInfos)
+}
+
+// GetIncident 查询事件详情
+func GetIncident(c *gin.Context) {
+	incident, ok := findIncident(c)
+	if !ok {
+		return
+	}
+
+	var workflows []model.Workflow
+	var alerts []model.Alert
+	var notes []model.IncidentNote
+	err := store.DB.Where("incident_id = ?", incident.ID).Order("created_at asc").Find(&workflows).Error
+	if err == nil {
+		err = store.DB.Where("incident_id = ?", incident.ID).Order("created_at asc").Find(&alerts).Error
+	}
+	if err == nil {
+		err = store.DB.Where("incident_id = ?", incident.ID).Order("created_at asc").Find(&notes).Error
+	}
+	if err != nil {
+		logger.L.Errorw("Failed to get incident details", "incident_id", incident.ID, "error", err)
+		Error(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	detail := IncidentDetail{
+		IncidentInfo: toIncidentInfo(*incident),
+		Workflows:    make([]WorkflowInfo, 0, len(workflows)),
+		Alerts:       make([]AlertInfo, 0, len(alerts)),
+		Notes:        make([]IncidentNoteInfo, 0, len(notes)),
+	}
+	for _, workflow := range workflows {
+		detail.Workflows = append(detail.Workflows, toWorkflowInfo(workflow))
+	}
+	for _, alert := range alerts {
+		detail.Alerts = append(detail.Alerts, toAlertInfo(alert))
+	}
+	for _, note := range notes {
+		detail.Notes = append(detail.Notes, IncidentNoteInfo{ID: note.ID, Author: note.Author, Content: note.Content, CreatedAt: note.CreatedAt})
+	}
+	Success(c, detail)
+}
+
+// AcknowledgeIncident 确认事件，表示已有人在处理
+func AcknowledgeIncident(c *gin.Context) {
+	changeIncidentStatus(c, engine.IncidentAcknowledged)
+}
+
+// ResolveIncident 解决事件，之后的同类工作流和告警会聚合到新的事件中
+func ResolveIncident(c *gin.Context) {
+	changeIncidentStatus(c, engine.IncidentResolved)
+}
+
+// ReopenIncident 重新打开一个已确认或已解决的事件
+func ReopenIncident(c *gin.Context) {
+	changeIncidentStatus(c, engine.IncidentOpen)
+}
+
+// UpdateIncidentAssignee 修改事件的处理人
+func UpdateIncidentAssignee(c *gin.Context) {
+	incident, ok := findIncident(c)
+	if !ok {
+		return
+	}
+
+	var req IncidentAssigneeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ParamError(c, err.Error())
+		return
+	}
+
+	if err := store.DB.Model(incident).Update("assignee", req.Assignee).Error; err != nil {
+		logger.L.Errorw("Failed to update incident assignee", "incident_id", incident.ID, "error", err)
+		Error(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+	Success(c, toIncidentInfo(*incident))
+}
+
+// AddIncidentNote 为事件添加一条备注
+func AddIncidentNote(c *gin.Context) {
+	incident, ok := findIncident(c)
+	if !ok {
+		return
+	}
+
+	var req IncidentNoteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ParamError(c, err.Error())
+		return
+	}
+
+	note := model.IncidentNote{IncidentID: incident.ID, Author: req.Author, Content: req.Content}
+	if err := store.DB.Create(&note).Error; err != nil {
+		logger.L.Errorw("Failed to add incident note", "incident_id", incident.ID, "error", err)
+		Error(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+	Success(c, IncidentNoteInfo{ID: note.ID, Author: note.Author, Content: note.Content, CreatedAt: note.CreatedAt})
+}
+
+// CreateAlert 接收一条告警，并把它聚合到事件中
+func CreateAlert(c *gin.Context) {
+	var req AlertRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ParamError(c, err.Error())
+		return
+	}
+
+	alert := &model.Alert{
+		Type:       req.Type,
+		Source:     req.Source,
+		Severity:   req.Severity,
+		AgentID:    req.AgentID,
+		AgentGroup: req.AgentGroup,
+		KBID:       req.KBID,
+		Message:    req.Message,
+	}
+	if err := engine.RecordAlert(alert); err != nil {
+		if errors.Is(err, engine.ErrInvalidSeverity) {
+			ParamError(c, err.Error())
+			return
+		}
+		logger.L.Errorw("Failed to record alert", "type", req.Type, "error", err)
+		Error(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+	Success(c, toAlertInfo(*alert))
+}
+
+// ListAlerts 查询告警列表，支持按 type / agent_id / incident_id 过滤
+func ListAlerts(c *gin.Context) {
+	query := store.DB.Order("created_at desc")
+	if alertType := c.Query("type"); alertType != "" {
+		query = query.Where("type = ?", alertType)
+	}
+	if agentID := c.Query("agent_id"); agentID != "" {
+		query = query.Where("agent_id = ?", agentID)
+	}
+	if incidentID := c.Query("incident_id"); incidentID != "" {
+		query = query.Where("incident_id = ?", incidentID)
+	}
+
+	var alerts []model.Alert
+	if err := query.Limit(200).Find(&alerts).Error; err != nil {
+		logger.L.Errorw("Failed to list alerts", "error", err)
+		Error(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	alertInfos := make([]AlertInfo, 0, len(alerts))
+	for _, alert := range alerts {
+		alertInfos = append(alertInfos, toAlertInfo(alert))
+	}
+	Success(c, alertInfos)
+}
+
+// changeIncidentStatus 变更事件状态，请求体中的备注会一并记录
+func changeIncidentStatus(c *gin.Context, to string) {
+	incident, ok := findIncident(c)
+	if !ok {
+		return
+	}
+
+	var req IncidentActionRequest
+	_ = c.ShouldBindJSON(&req) // 请求体是可选的
+
+	if err := engine.ChangeIncidentStatus(incident, to, req.Assignee); err != nil {
+		if errors.Is(err, engine.ErrInvalidIncidentTransition) {
+			Error(c, http.StatusConflict, err.Error())
+			return
+		}
+		logger.L.Errorw("Failed to change incident status", "incident_id", incident.ID, "to", to, "error", err)
+		Error(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	if req.Note != "" {
+		note := model.IncidentNote{IncidentID: incident.ID, Author: req.Author, Content: req.Note}
+		if err := store.DB.Create(&note).Error; err != nil {
+			logger.L.Errorw("Failed to add incident note", "incident_id", incident.ID, "error", err)
+		}
+	}
+	Success(c, toIncidentInfo(*incident))
+}
+
+// findIncident 根据路径参数 :id 查询事件，找不到时直接写入错误响应
+func findIncident(c *gin.Context) (*model.Incident, bool) {
+	var incident model.Incident
+	if err := store.DB.Where("id = ?", c.Param("id")).First(&incident).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			Error(c, http.StatusNotFound, "Incident not found.")
+			return nil, false
+		}
+		logger.L.Errorw("Failed to get incident", "incident_id", c.Param("id"), "error", err)
+		Error(c, http.StatusInternalServerError, "Database error")
+		return nil, false
+	}
+	return &incident, true
+}
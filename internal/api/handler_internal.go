@@ -1,7 +1,9 @@
 package api
 
 import (
+	"errors"
 	"net/http"
+	"time"
 
 	"github.com/GenJi77JYXC/intelligent-pioneer/internal/core/engine"
 	"github.com/GenJi77JYXC/intelligent-pioneer/internal/logger"
@@ -20,7 +22,21 @@ func TriggerKB(c *gin.Context) {
 		return
 	}
 
-	logger.L.Infow("Manual KB trigger received", "agent_id", req.AgentID, "kb_id", req.KBID)
+	logger.L.Infow("Manual KB trigger received", "agent_id", req.AgentID, "kb_id", req.KBID, "priority", req.Priority)
+
+	priority, err := engine.ParsePriority(req.Priority)
+	if err != nil {
+		ParamError(c, err.Error())
+		return
+	}
+	var ttl time.Duration
+	if req.TTL != "" {
+		ttl, err = time.ParseDuration(req.TTL)
+		if err != nil || ttl <= 0 {
+			ParamError(c, "ttl must be a positive duration, e.g. \"15m\"")
+			return
+		}
+	}
 
 	// 2.验证 Agent 是否存在且在线
 	var agent model.Agent
@@ -34,7 +50,16 @@ func TriggerKB(c *gin.Context) {
 
 	// 3. 调用引擎，启动工作流
 	// 注意：StartKBWorkflow 目前返回的是 error，未来可以修改它返回 (workflowID, error)
-	workflowID, err := engine.StartKBWorkflow(req.AgentID, req.KBID)
+	workflowID, err := engine.StartKBWorkflow(req.AgentID, req.KBID, engine.WorkflowOptions{
+		Priority:  priority,
+		Preempt:   req.Preempt,
+		NotBefore: req.NotBefore,
+		TTL:       ttl,
+	})
+	if errors.Is(err, engine.ErrAgentInMaintenance) {
+		Error(c, http.StatusConflict, err.Error())
+		return
+	}
 	if err != nil {
 		logger.L.Errorw("Failed to start KB workflow", "agent_id:", req.AgentID, "kb_id:", req.KBID, "workflowID:", workflowID, "error", err)
 		// 根据错误类型返回不同的 HTTP 状态码
@@ -44,10 +69,17 @@ func TriggerKB(c *gin.Context) {
 	}
 
 	// 4. 返回成功响应
-	// 当 StartKBWorkflow 返回 workflowID 时，在这里一并返回
+	// Agent 任务队列已满时工作流会被推迟 (deferred)，稍后自动重新提交，这里把当前状态一并返回
+	status := engine.StatusDiagnosing
+	var workflow model.Workflow
+	if err := store.DB.Select("status").Where("id = ?", workflowID).First(&workflow).Error; err == nil {
+		status = workflow.Status
+	}
 	Success(c, gin.H{
 		"message":     "KB workflow triggered successfully.",
 		"workflow_id": workflowID,
+		"priority":    priority.String(),
+		"status":      status,
 	})
 	logger.L.Info("Manual KB trigger received")
 }
@@ -0,0 +1,169 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/core/engine"
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/logger"
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/model"
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/store"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type MaintenanceWindowInfo struct {
+	ID         uint      `json:"id"`
+	Name       string    `json:"name"`
+	Mode       string    `json:"mode"`
+	Action     string    `json:"action"`
+	Timezone   string    `json:"timezone"`
+	Weekdays   []int     `json:"weekdays"`
+	StartTime  string    `json:"start_time"`
+	EndTime    string    `json:"end_time"`
+	AgentGroup string    `json:"agent_group"`
+	KBID       string    `json:"kb_id"`
+	Enabled    bool      `json:"enabled"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+func toMaintenanceWindowInfo(window model.MaintenanceWindow) MaintenanceWindowInfo {
+	return MaintenanceWindowInfo{
+		ID:         window.ID,
+		Name:       window.Name,
+		Mode:       window.Mode,
+		Action:     window.Action,
+		Timezone:   window.Timezone,
+		Weekdays:   window.Weekdays,
+		StartTime:  window.StartTime,
+		EndTime:    window.EndTime,
+		AgentGroup: window.AgentGroup,
+		KBID:       window.KBID,
+		Enabled:    window.Enabled,
+		CreatedAt:  window.CreatedAt,
+		UpdatedAt:  window.UpdatedAt,
+	}
+}
+
+// applyMaintenanceWindowRequest 把请求体的内容写入维护窗口，并校验配置
+func applyMaintenanceWindowRequest(window *model.MaintenanceWindow, req *MaintenanceWindowRequest) error {
+	window.Name = req.Name
+	window.Mode = req.Mode
+	window.Action = req.Action
+	window.Timezone = req.Timezone
+	window.Weekdays = req.Weekdays
+	window.StartTime = req.StartTime
+	window.EndTime = req.EndTime
+	window.AgentGroup = req.AgentGroup
+	window.KBID = req.KBID
+	if req.Enabled != nil {
+		window.Enabled = *req.Enabled
+	}
+	return engine.ValidateMaintenanceWindow(window)
+}
+
+// CreateMaintenanceWindow 创建一个维护窗口
+func CreateMaintenanceWindow(c *gin.Context) {
+	var req MaintenanceWindowRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ParamError(c, err.Error())
+		return
+	}
+
+	window := model.MaintenanceWindow{Enabled: true}
+	if err := applyMaintenanceWindowRequest(&window, &req); err != nil {
+		ParamError(c, err.Error())
+		return
+	}
+	if err := store.DB.Create(&window).Error; err != nil {
+		logger.L.Errorw("Failed to create maintenance window", "name", req.Name, "error", err)
+		Error(c, http.StatusInternalServerError, "Failed to create maintenance window")
+		return
+	}
+
+	logger.L.Infow("Maintenance window created", "window_id", window.ID, "name", window.Name)
+	Success(c, toMaintenanceWindowInfo(window))
+}
+
+// ListMaintenanceWindows 查询所有维护窗口
+func ListMaintenanceWindows(c *gin.Context) {
+	var windows []model.MaintenanceWindow
+	if err := store.DB.Order("id asc").Find(&windows).Error; err != nil {
+		logger.L.Errorw("Failed to list maintenance windows", "error", err)
+		Error(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	windowInfos := make([]MaintenanceWindowInfo, 0, len(windows))
+	for _, window := range windows {
+		windowInfos = append(windowInfos, toMaintenanceWindowInfo(window))
+	}
+	Success(c, windowInfos)
+}
+
+// UpdateMaintenanceWindow 更新维护窗口
+func UpdateMaintenanceWindow(c *gin.Context) {
+	window, ok := findMaintenanceWindow(c)
+	if !ok {
+		return
+	}
+
+	var req MaintenanceWindowRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ParamError(c, err.Error())
+		return
+	}
+	if err := applyMaintenanceWindowRequest(window, &req); err != nil {
+		ParamError(c, err.Error())
+		return
+	}
+	if err := store.DB.Save(window).Error; err != nil {
+		logger.L.Errorw("Failed to update maintenance window", "window_id", window.ID, "error", err)
+		Error(c, http.StatusInternalServerError, "Failed to update maintenance window")
+		return
+	}
+
+	logger.L.Infow("Maintenance window updated", "window_id", window.ID, "name", window.Name)
+	Success(c, toMaintenanceWindowInfo(*window))
+}
+
+// DeleteMaintenanceWindow 删除维护窗口
+func DeleteMaintenanceWindow(c *gin.Context) {
+	window, ok := findMaintenanceWindow(c)
+	if !ok {
+		return
+	}
+
+	if err := store.DB.Delete(window).Error; err != nil {
+		logger.L.Errorw("Failed to delete maintenance window", "window_id", window.ID, "error", err)
+		Error(c, http.StatusInternalServerError, "Failed to delete maintenance window")
+		return
+	}
+
+	logger.L.Infow("Maintenance window deleted", "window_id", window.ID, "name", window.Name)
+	Success(c, gin.H{"status": "deleted"})
+}
+
+// findMaintenanceWindow 根据路径参数 :id 查询维护窗口，找不到时直接写入错误响应
+func findMaintenanceWindow(c *gin.Context) (*model.MaintenanceWindow, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		ParamError(c, "invalid maintenance window id")
+		return nil, false
+	}
+
+	var window model.MaintenanceWindow
+	if err := store.DB.First(&window, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			Error(c, http.StatusNotFound, "Maintenance window not found.")
+			return nil, false
+		}
+		logger.L.Errorw("Failed to get maintenance window", "window_id", id, "error", err)
+		Error(c, http.StatusInternalServerError, "Database error")
+		return nil, false
+	}
+	return &window, true
+}
@@ -0,0 +1,60 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/core/engine"
+	"github.com/gin-gonic/gin"
+)
+
+type QueuedTaskInfo struct {
+	ID         string     `json:"id"`
+	WorkflowID string     `json:"workflow_id"`
+	Type       string     `json:"type"`
+	Command    string     `json:"command"`
+	Priority   string     `json:"priority"`
+	CreatedAt  time.Time  `json:"created_at"`
+	NotBefore  *time.Time `json:"not_before,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+}
+
+// AgentQueueDetail 是单个 Agent 任务队列的统计信息和排队中的任务 (按分发顺序)
+type AgentQueueDetail struct {
+	engine.QueueStats
+	Tasks []QueuedTaskInfo `json:"tasks"`
+}
+
+// ListQueues 查询所有 Agent 任务队列的统计信息
+func ListQueues(c *gin.Context) {
+	Success(c, engine.TM.QueueStats())
+}
+
+// GetAgentQueue 查询单个 Agent 的任务队列，包含排队中的任务
+func GetAgentQueue(c *gin.Context) {
+	agentID := c.Param("agent_id")
+	stats, ok := engine.TM.AgentQueueStats(agentID)
+	if !ok {
+		Error(c, http.StatusNotFound, "Task queue not found for this agent.")
+		return
+	}
+
+	tasks := engine.TM.QueuedTasks(agentID)
+	detail := AgentQueueDetail{
+		QueueStats: stats,
+		Tasks:      make([]QueuedTaskInfo, 0, len(tasks)),
+	}
+	for _, task := range tasks {
+		detail.Tasks = append(detail.Tasks, QueuedTaskInfo{
+			ID:         task.ID,
+			WorkflowID: task.WorkflowID,
+			Type:       task.Type,
+			Command:    task.Command,
+			Priority:   task.Priority.String(),
+			CreatedAt:  task.CreatedAt,
+			NotBefore:  task.NotBefore,
+			ExpiresAt:  task.ExpiresAt,
+		})
+	}
+	Success(c, detail)
+}
@@ -0,0 +1,305 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/core/engine"
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/logger"
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/model"
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/scheduler"
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/store"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type ScheduleInfo struct {
+	ID            uint                `json:"id"`
+	Name          string              `json:"name"`
+	CronExpr      string              `json:"cron_expr"`
+	KBID          string              `json:"kb_id"`
+	AgentSelector model.AgentSelector `json:"agent_selector"`
+	Priority      string              `json:"priority"`
+	Enabled       bool                `json:"enabled"`
+	LastRunAt     *time.Time          `json:"last_run_at"`
+	NextRunAt     *time.Time          `json:"next_run_at"`
+	CreatedAt     time.Time           `json:"created_at"`
+	UpdatedAt     time.Time           `json:"updated_at"`
+}
+
+type ScheduleRunInfo struct {
+	ID          uint      `json:"id"`
+	Trigger     string    `json:"trigger"`
+	Outcome     string    `json:"outcome"`
+	Matched     int       `json:"matched"`
+	Started     int       `json:"started"`
+	Skipped     int       `json:"skipped"`
+	Failed      int       `json:"failed"`
+	WorkflowIDs []string  `json:"workflow_ids"`
+	Message     string    `json:"message"`
+	StartedAt   time.Time `json:"started_at"`
+	FinishedAt  time.Time `json:"finished_at"`
+	// WorkflowStatuses 统计本次执行启动的工作流当前各状态的数量，用于查看最终结果
+	WorkflowStatuses map[string]int `json:"workflow_statuses"`
+}
+
+func toScheduleInfo(schedule model.KBSchedule) ScheduleInfo {
+	return ScheduleInfo{
+		ID:            schedule.ID,
+		Name:          schedule.Name,
+		CronExpr:      schedule.CronExpr,
+		KBID:          schedule.KBID,
+		AgentSelector: schedule.AgentSelector,
+		Priority:      engine.Priority(schedule.Priority).String(),
+		Enabled:       schedule.Enabled,
+		LastRunAt:     schedule.LastRunAt,
+		NextRunAt:     scheduler.NextKBScheduleRun(schedule.ID),
+		CreatedAt:     schedule.CreatedAt,
+		UpdatedAt:     schedule.UpdatedAt,
+	}
+}
+
+// CreateSchedule 创建一个新的知识库计划，并立即加载到调度器中
+func CreateSchedule(c *gin.Context) {
+	var req ScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ParamError(c, err.Error())
+		return
+	}
+	if msg, ok := validateScheduleRequest(&req); !ok {
+		ParamError(c, msg)
+		return
+	}
+
+	priority, _ := engine.ParsePriority(req.Priority) // 已在 validateScheduleRequest 中校验
+	schedule := model.KBSchedule{
+		Name:          req.Name,
+		CronExpr:      req.CronExpr,
+		KBID:          req.KBID,
+		AgentSelector: req.AgentSelector,
+		Priority:      int(priority),
+		Enabled:       req.Enabled == nil || *req.Enabled,
+	}
+	if err := store.DB.Create(&schedule).Error; err != nil {
+		logger.L.Errorw("Failed to create KB schedule", "name", req.Name, "error", err)
+		Error(c, http.StatusInternalServerError, "Failed to create schedule")
+		return
+	}
+	if err := scheduler.RegisterKBSchedule(&schedule); err != nil {
+		logger.L.Errorw("Failed to register KB schedule", "schedule_id", schedule.ID, "error", err)
+	}
+
+	logger.L.Infow("KB schedule created", "schedule_id", schedule.ID, "name", schedule.Name)
+	Success(c, toScheduleInfo(schedule))
+}
+
+// ListSchedules 查询所有知识库计划
+func ListSchedules(c *gin.Context) {
+	var schedules []model.KBSchedule
+	if err := store.DB.Order("id asc").Find(&schedules).Error; err != nil {
+		logger.L.Errorw("Failed to list KB schedules", "error", err)
+		Error(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	scheduleInfos := make([]ScheduleInfo, 0, len(schedules))
+	for _, schedule := range schedules {
+		scheduleInfos = append(scheduleInfos, toScheduleInfo(schedule))
+	}
+	Success(c, scheduleInfos)
+}
+
+// GetSchedule 查询单个知识库计划
+func GetSchedule(c *gin.Context) {
+	schedule, ok := findSchedule(c)
+	if !ok {
+		return
+	}
+	Success(c, toScheduleInfo(*schedule))
+}
+
+// UpdateSchedule 更新知识库计划，并重新加载到调度器中
+func UpdateSchedule(c *gin.Context) {
+	schedule, ok := findSchedule(c)
+	if !ok {
+		return
+	}
+
+	var req ScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ParamError(c, err.Error())
+		return
+	}
+	if msg, ok := validateScheduleRequest(&req); !ok {
+		ParamError(c, msg)
+		return
+	}
+
+	schedule.Name = req.Name
+	schedule.CronExpr = req.CronExpr
+	schedule.KBID = req.KBID
+	schedule.AgentSelector = req.AgentSelector
+	priority, _ := engine.ParsePriority(req.Priority) // 已在 validateScheduleRequest 中校验
+	schedule.Priority = int(priority)
+	if req.Enabled != nil {
+		schedule.Enabled = *req.Enabled
+	}
+	if err := store.DB.Save(schedule).Error; err != nil {
+		logger.L.Errorw("Failed to update KB schedule", "schedule_id", schedule.ID, "error", err)
+		Error(c, http.StatusInternalServerError, "Failed to update schedule")
+		return
+	}
+	if err := scheduler.RegisterKBSchedule(schedule); err != nil {
+		logger.L.Errorw("Failed to register KB schedule", "schedule_id", schedule.ID, "error", err)
+	}
+
+	logger.L.Infow("KB schedule updated", "schedule_id", schedule.ID, "name", schedule.Name)
+	Success(c, toScheduleInfo(*schedule))
+}
+
+// DeleteSchedule 删除知识库计划 (执行历史会被保留)
+func DeleteSchedule(c *gin.Context) {
+	schedule, ok := findSchedule(c)
+	if !ok {
+		return
+	}
+
+	scheduler.UnregisterKBSchedule(schedule.ID)
+	if err := store.DB.Delete(schedule).Error; err != nil {
+		logger.L.Errorw("Failed to delete KB schedule", "schedule_id", schedule.ID, "error", err)
+		Error(c, http.StatusInternalServerError, "Failed to delete schedule")
+		return
+	}
+
+	logger.L.Infow("KB schedule deleted", "schedule_id", schedule.ID, "name", schedule.Name)
+	Success(c, gin.H{"status": "deleted"})
+}
+
+// RunScheduleNow 立即手动执行一次计划，不影响 cron 的正常调度
+func RunScheduleNow(c *gin.Context) {
+	schedule, ok := findSchedule(c)
+	if !ok {
+		return
+	}
+
+	run, err := scheduler.RunKBSchedule(schedule.ID, "manual")
+	if err != nil {
+		logger.L.Errorw("Failed to run KB schedule", "schedule_id", schedule.ID, "error", err)
+		Error(c, http.StatusInternalServerError, "Failed to run schedule: "+err.Error())
+		return
+	}
+	Success(c, toScheduleRunInfo(*run, workflowStatusCounts(run.WorkflowIDs)))
+}
+
+// ListScheduleRuns 查询计划的执行历史 (最近的在前)
+func ListScheduleRuns(c *gin.Context) {
+	schedule, ok := findSchedule(c)
+	if !ok {
+		return
+	}
+
+	var runs []model.KBScheduleRun
+	if err := store.DB.Where("schedule_id = ?", schedule.ID).Order("started_at desc").Limit(100).Find(&runs).Error; err != nil {
+		logger.L.Errorw("Failed to list KB schedule runs", "schedule_id", schedule.ID, "error", err)
+		Error(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	// 一次性查出所有相关工作流的状态，避免逐条查询
+	var workflowIDs []string
+	for _, run := range runs {
+		workflowIDs = append(workflowIDs, run.WorkflowIDs...)
+	}
+	statusByWorkflow := make(map[string]string)
+	if len(workflowIDs) > 0 {
+		var workflows []model.Workflow
+		if err := store.DB.Select("id", "status").Where("id IN ?", workflowIDs).Find(&workflows).Error; err != nil {
+			logger.L.Errorw("Failed to get workflows for KB schedule runs", "schedule_id", schedule.ID, "error", err)
+		}
+		for _, workflow := range workflows {
+			statusByWorkflow[workflow.ID] = workflow.Status
+		}
+	}
+
+	runInfos := make([]ScheduleRunInfo, 0, len(runs))
+	for _, run := range runs {
+		statuses := make(map[string]int)
+		for _, id := range run.WorkflowIDs {
+			statuses[statusByWorkflow[id]]++
+		}
+		runInfos = append(runInfos, toScheduleRunInfo(run, statuses))
+	}
+	Success(c, runInfos)
+}
+
+func toScheduleRunInfo(run model.KBScheduleRun, statuses map[string]int) ScheduleRunInfo {
+	return ScheduleRunInfo{
+		ID:               run.ID,
+		Trigger:          run.Trigger,
+		Outcome:          run.Outcome,
+		Matched:          run.Matched,
+		Started:          run.Started,
+		Skipped:          run.Skipped,
+		Failed:           run.Failed,
+		WorkflowIDs:      run.WorkflowIDs,
+		Message:          run.Message,
+		StartedAt:        run.StartedAt,
+		FinishedAt:       run.FinishedAt,
+		WorkflowStatuses: statuses,
+	}
+}
+
+// workflowStatusCounts 统计一组工作流当前各状态的数量
+func workflowStatusCounts(workflowIDs []string) map[string]int {
+	statuses := make(map[string]int)
+	if len(workflowIDs) == 0 {
+		return statuses
+	}
+
+	var workflows []model.Workflow
+	if err := store.DB.Select("id", "status").Where("id IN ?", workflowIDs).Find(&workflows).Error; err != nil {
+		logger.L.Errorw("Failed to count workflow statuses", "error", err)
+		return statuses
+	}
+	for _, workflow := range workflows {
+		statuses[workflow.Status]++
+	}
+	return statuses
+}
+
+// validateScheduleRequest 校验 cron 表达式和 Agent 选择器
+func validateScheduleRequest(req *ScheduleRequest) (string, bool) {
+	if _, err := scheduler.ParseScheduleExpr(req.CronExpr); err != nil {
+		return "invalid cron_expr: " + err.Error(), false
+	}
+	if _, err := engine.ParsePriority(req.Priority); err != nil {
+		return err.Error(), false
+	}
+	if req.AgentSelector.IsEmpty() {
+		return "agent_selector must set at least one of agent_ids, hostname, os, group or all", false
+	}
+	return "", true
+}
+
+// findSchedule 根据路径参数 :id 查询计划，找不到时直接写入错误响应
+func findSchedule(c *gin.Context) (*model.KBSchedule, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		ParamError(c, "invalid schedule id")
+		return nil, false
+	}
+
+	var schedule model.KBSchedule
+	if err := store.DB.First(&schedule, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			Error(c, http.StatusNotFound, "Schedule not found.")
+			return nil, false
+		}
+		logger.L.Errorw("Failed to get KB schedule", "schedule_id", id, "error", err)
+		Error(c, http.StatusInternalServerError, "Database error")
+		return nil, false
+	}
+	return &schedule, true
+}
@@ -0,0 +1,204 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/core/engine"
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/logger"
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/model"
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/store"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// maxScriptSize 是脚本内容的最大字节数
+const maxScriptSize = 256 * 1024
+
+type ScriptInfo struct {
+	ID          uint      `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Interpreter string    `json:"interpreter"`
+	SHA256      string    `json:"sha256"`
+	Size        int       `json:"size"`
+	Body        string    `json:"body,omitempty"` // 列表中不返回脚本内容
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func toScriptInfo(script model.Script, withBody bool) ScriptInfo {
+	info := ScriptInfo{
+		ID:          script.ID,
+		Name:        script.Name,
+		Description: script.Description,
+		Interpreter: script.Interpreter,
+		SHA256:      script.SHA256,
+		Size:        len(script.Body),
+		CreatedAt:   script.CreatedAt,
+		UpdatedAt:   script.UpdatedAt,
+	}
+	if withBody {
+		info.Body = script.Body
+	}
+	return info
+}
+
+// CreateScript 创建一个脚本，知识库步骤可以通过 {"script": "<name>"} 引用
+func CreateScript(c *gin.Context) {
+	var req ScriptRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ParamError(c, err.Error())
+		return
+	}
+	if msg, ok := validateScriptRequest(&req); !ok {
+		ParamError(c, msg)
+		return
+	}
+	if !scriptNameAvailable(c, req.Name, 0) {
+		return
+	}
+
+	script := model.Script{
+		Name:        req.Name,
+		Description: req.Description,
+		Interpreter: req.Interpreter,
+		Body:        req.Body,
+		SHA256:      engine.ScriptSHA256(req.Body),
+	}
+	if err := store.DB.Create(&script).Error; err != nil {
+		logger.L.Errorw("Failed to create script", "name", req.Name, "error", err)
+		Error(c, http.StatusInternalServerError, "Failed to create script")
+		return
+	}
+
+	logger.L.Infow("Script created", "script_id", script.ID, "name", script.Name, "sha256", script.SHA256)
+	Success(c, toScriptInfo(script, true))
+}
+
+// ListScripts 查询所有脚本 (不包含脚本内容)
+func ListScripts(c *gin.Context) {
+	var scripts []model.Script
+	if err := store.DB.Order("name asc").Find(&scripts).Error; err != nil {
+		logger.L.Errorw("Failed to list scripts", "error", err)
+		Error(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	scriptInfos := make([]ScriptInfo, 0, len(scripts))
+	for _, script := range scripts {
+		scriptInfos = append(scriptInfos, toScriptInfo(script, false))
+	}
+	Success(c, scriptInfos)
+}
+
+// GetScript 查询单个脚本，包含脚本内容
+func GetScript(c *gin.Context) {
+	script, ok := findScript(c)
+	if !ok {
+		return
+	}
+	Success(c, toScriptInfo(*script, true))
+}
+
+// UpdateScript 更新脚本，已经下发的任务仍使用下发时的脚本内容
+func UpdateScript(c *gin.Context) {
+	script, ok := findScript(c)
+	if !ok {
+		return
+	}
+
+	var req ScriptRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ParamError(c, err.Error())
+		return
+	}
+	if msg, ok := validateScriptRequest(&req); !ok {
+		ParamError(c, msg)
+		return
+	}
+	if req.Name != script.Name && !scriptNameAvailable(c, req.Name, script.ID) {
+		return
+	}
+
+	script.Name = req.Name
+	script.Description = req.Description
+	script.Interpreter = req.Interpreter
+	script.Body = req.Body
+	script.SHA256 = engine.ScriptSHA256(req.Body)
+	if err := store.DB.Save(script).Error; err != nil {
+		logger.L.Errorw("Failed to update script", "script_id", script.ID, "error", err)
+		Error(c, http.StatusInternalServerError, "Failed to update script")
+		return
+	}
+
+	logger.L.Infow("Script updated", "script_id", script.ID, "name", script.Name, "sha256", script.SHA256)
+	Success(c, toScriptInfo(*script, true))
+}
+
+// DeleteScript 删除脚本，之后引用它的知识库步骤会让工作流失败
+func DeleteScript(c *gin.Context) {
+	script, ok := findScript(c)
+	if !ok {
+		return
+	}
+
+	if err := store.DB.Delete(script).Error; err != nil {
+		logger.L.Errorw("Failed to delete script", "script_id", script.ID, "error", err)
+		Error(c, http.StatusInternalServerError, "Failed to delete script")
+		return
+	}
+
+	logger.L.Infow("Script deleted", "script_id", script.ID, "name", script.Name)
+	Success(c, gin.H{"status": "deleted"})
+}
+
+func validateScriptRequest(req *ScriptRequest) (string, bool) {
+	switch req.Interpreter {
+	case "", "sh", "bash", "python3", "direct":
+	default:
+		return "interpreter must be one of sh, bash, python3 or direct", false
+	}
+	if len(req.Body) > maxScriptSize {
+		return "body exceeds " + strconv.Itoa(maxScriptSize) + " bytes", false
+	}
+	return "", true
+}
+
+// scriptNameAvailable 检查脚本名称是否已被其他脚本使用，已被使用时直接写入错误响应
+func scriptNameAvailable(c *gin.Context, name string, exceptID uint) bool {
+	var count int64
+	if err := store.DB.Model(&model.Script{}).Where("name = ? AND id <> ?", name, exceptID).Count(&count).Error; err != nil {
+		logger.L.Errorw("Failed to check script name", "name", name, "error", err)
+		Error(c, http.StatusInternalServerError, "Database error")
+		return false
+	}
+	if count > 0 {
+		Error(c, http.StatusConflict, "A script with this name already exists.")
+		return false
+	}
+	return true
+}
+
+// findScript 根据路径参数 :id 查询脚本，找不到时直接写入错误响应
+func findScript(c *gin.Context) (*model.Script, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		ParamError(c, "invalid script id")
+		return nil, false
+	}
+
+	var script model.Script
+	if err := store.DB.First(&script, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			Error(c, http.StatusNotFound, "Script not found.")
+			return nil, false
+		}
+		logger.L.Errorw("Failed to get script", "script_id", id, "error", err)
+		Error(c, http.StatusInternalServerError, "Database error")
+		return nil, false
+	}
+	return &script, true
+}
@@ -0,0 +1,74 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/core/engine"
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/logger"
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/model"
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/store"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// SimulateWorkflow 用模拟的或历史工作流的任务结果离线运行状态机，返回经过的状态和会下发的命令
+// 不会创建工作流，也不会向 Agent 下发任何任务
+func SimulateWorkflow(c *gin.Context) {
+	var req SimulationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ParamError(c, err.Error())
+		return
+	}
+	if req.KBItem == nil && req.KBID == "" && req.WorkflowID == "" {
+		ParamError(c, "one of kb_item, kb_id or workflow_id is required")
+		return
+	}
+
+	var workflow *model.Workflow
+	if req.WorkflowID != "" {
+		workflow = &model.Workflow{}
+		if err := store.DB.Where("id = ?", req.WorkflowID).First(workflow).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				Error(c, http.StatusNotFound, "Workflow not found.")
+				return
+			}
+			logger.L.Errorw("Failed to get workflow for simulation", "workflow_id", req.WorkflowID, "error", err)
+			Error(c, http.StatusInternalServerError, "Database error")
+			return
+		}
+	}
+
+	kbItem := req.KBItem
+	if kbItem == nil {
+		kbID := req.KBID
+		if kbID == "" {
+			kbID = workflow.KBID
+		}
+		item, err := engine.GetKBItem(kbID)
+		if err != nil {
+			logger.L.Warnw("Failed to get KB item for simulation", "kb_id", kbID, "error", err)
+			Error(c, http.StatusNotFound, "KB item not found.")
+			return
+		}
+		kbItem = item
+	}
+
+	results := req.Results
+	if len(results) == 0 && workflow != nil {
+		recorded, err := engine.RecordedResults(workflow.ID)
+		if err != nil {
+			logger.L.Errorw("Failed to load recorded task results", "workflow_id", workflow.ID, "error", err)
+			Error(c, http.StatusInternalServerError, "Database error")
+			return
+		}
+		results = recorded
+	}
+
+	simulation, err := engine.Simulate(kbItem, results)
+	if err != nil {
+		ParamError(c, err.Error())
+		return
+	}
+	Success(c, simulation)
+}
@@ -0,0 +1,283 @@
+package api
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/core/engine"
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/logger"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/net/websocket"
+)
+
+// 持久连接上的消息类型
+const (
+	StreamMsgTasks     = "tasks"      // 服务端 -> Agent: 下发一批任务
+	StreamMsgAck       = "ack"        // Agent -> 服务端: 确认收到任务 (TaskIDs)
+	StreamMsgCancel    = "cancel"     // 服务端 -> Agent: 终止正在执行的任务 (TaskID, Reason)
+	StreamMsgHeartbeat = "heartbeat"  // Agent -> 服务端: 心跳
+	StreamMsgResult    = "result"     // Agent -> 服务端: 任务结果 (Result)
+	StreamMsgResultAck = "result_ack" // 服务端 -> Agent: 确认收到任务结果 (TaskID)
+	StreamMsgOutput    = "output"     // Agent -> 服务端: 任务执行过程中的输出 (Output)
+	StreamMsgCapacity  = "capacity"   // Agent -> 服务端: 任务执行容量变化 (Capacity)，心跳也会携带
+)
+
+const (
+	// streamPollTimeout 是持久连接内部每一轮等待任务的时间，与 HTTP 长轮询一致
+	streamPollTimeout = 30 * time.Second
+	// streamReadTimeout 是多久没有收到 Agent 的任何消息就断开连接，Agent 应该以更短的间隔发送心跳
+	streamReadTimeout = 90 * time.Second
+	// streamWriteTimeout 是单条消息的写超时
+	streamWriteTimeout = 10 * time.Second
+)
+
+// StreamMessage 是持久连接上传输的消息，Type 决定哪些字段有意义
+type StreamMessage struct {
+	Type    string                   `json:"type"`
+	Tasks   []*engine.Task           `json:"tasks,omitempty"`
+	TaskIDs []string                 `json:"task_ids,omitempty"`
+	TaskID  string                   `json:"task_id,omitempty"`
+	Reason  string                   `json:"reason,omitempty"`
+	Result  *engine.TaskResult       `json:"result,omitempty"`
+	Output  []engine.TaskOutputChunk `json:"output,omitempty"`
+	// Capacity 是 Agent 的任务执行容量，随心跳和 capacity 消息上报
+	Capacity *AgentCapacity `json:"capacity,omitempty"`
+}
+
+// AgentStream 处理 Agent 的持久连接 (WebSocket)
+// 一个连接同时承载任务下发、任务确认、取消、心跳和结果上报，替代长轮询 + 心跳 + 结果上报三种 HTTP 请求
+// 参数与 GET /api/v1/agent/tasks 相同；不支持持久连接的 Agent 继续使用 HTTP 接口
+func AgentStream(c *gin.Context) {
+	agentID, limit, ok := bindTaskPollParams(c)
+	if !ok {
+		return
+	}
+
+	server := websocket.Server{
+		// Agent 不是浏览器，不校验 Origin
+		Handler: func(conn *websocket.Conn) {
+			ctx, cancel := context.WithCancel(context.Background())
+			session := &agentStreamSession{
+				conn:      conn,
+				agentID:   agentID,
+				limit:     limit,
+				pending:   make(map[string]*engine.Task),
+				available: -1,
+				capacity:  make(chan struct{}, 1),
+				done:      make(chan struct{}),
+				ctx:       ctx,
+				cancel:    cancel,
+			}
+			session.serve()
+		},
+	}
+	server.ServeHTTP(c.Writer, c.Request)
+}
+
+// agentStreamSession 是一个 Agent 持久连接的服务端状态
+type agentStreamSession struct {
+	conn    *websocket.Conn
+	agentID string
+	limit   int
+
+	writeMu sync.Mutex // websocket 连接不支持并发写
+
+	mu      sync.Mutex
+	pending map[string]*engine.Task // 已经发送但 Agent 还没有确认收到的任务
+	closed  bool
+	done    chan struct{}
+	ctx     context.Context // 连接关闭时取消，用于中断正在进行的任务领取
+	cancel  context.CancelFunc
+
+	// available 是 Agent 还能接收的任务数，-1 表示 Agent 没有上报容量 (旧版本)，只受 limit 限制
+	// 每次下发任务后扣减，收到 Agent 上报的容量后以上报值为准
+	available int
+	capacity  chan struct{} // 容量变化通知，容量为 0 时 dispatchLoop 在这里等待
+}
+
+func (s *agentStreamSession) serve() {
+	logger.L.Infow("Agent stream connected", "agent_id", s.agentID, "remote_addr", s.conn.Request().RemoteAddr)
+	if _, err := markAgentOnline(s.agentID, nil); err != nil {
+		logger.L.Errorw("Failed to update agent heartbeat in database", "agent_id", s.agentID, "error", err)
+	}
+
+	unregister := engine.RegisterTaskCanceler(s.agentID, func(taskID, reason string) {
+		if err := s.send(StreamMessage{Type: StreamMsgCancel, TaskID: taskID, Reason: reason}); err != nil {
+			logger.L.Warnw("Failed to send task cancellation to agent", "agent_id", s.agentID, "task_id", taskID, "error", err)
+		}
+	})
+	defer unregister()
+
+	go s.dispatchLoop()
+	err := s.readLoop()
+	s.close()
+	logger.L.Infow("Agent stream disconnected", "agent_id", s.agentID, "error", err)
+}
+
+// dispatchLoop 持续为 Agent 领取任务并通过连接下发，直到连接关闭
+// 每次最多领取 Agent 剩余容量个任务，Agent 饱和时暂停领取，任务留在队列中等待或被其他 Agent 领取
+func (s *agentStreamSession) dispatchLoop() {
+	for {
+		select {
+		case <-s.done:
+			return
+		default:
+		}
+
+		limit := s.dispatchLimit()
+		if limit == 0 {
+			select {
+			case <-s.capacity:
+			case <-s.done:
+				return
+			}
+			continue
+		}
+		// 连接关闭后 ctx 被取消，已经断开的会话不会再领取任务 (否则只能再放回队尾)
+		tasks := engine.TM.GetTasksForAgent(s.ctx, s.agentID, limit, streamPollTimeout)
+		if len(tasks) == 0 {
+			continue
+		}
+		s.consumeCapacity(len(tasks))
+		if !s.addPending(tasks) {
+			// 连接已经关闭，领取到的任务放回队列
+			for _, task := range tasks {
+				engine.RequeueTask(task)
+			}
+			return
+		}
+		if err := s.send(StreamMessage{Type: StreamMsgTasks, Tasks: tasks}); err != nil {
+			logger.L.Warnw("Failed to send tasks to agent", "agent_id", s.agentID, "error", err)
+			s.conn.Close()
+			return
+		}
+		logger.L.Infow("Dispatched tasks to agent via stream", "agent_id", s.agentID, "count", len(tasks))
+	}
+}
+
+// readLoop 处理 Agent 发来的消息，连接断开或超时后返回
+func (s *agentStreamSession) readLoop() error {
+	for {
+		s.conn.SetReadDeadline(time.Now().Add(streamReadTimeout))
+		var msg StreamMessage
+		if err := websocket.JSON.Receive(s.conn, &msg); err != nil {
+			return err
+		}
+
+		switch msg.Type {
+		case StreamMsgAck:
+			s.ack(msg.TaskIDs)
+		case StreamMsgHeartbeat:
+			if msg.Capacity != nil {
+				s.updateCapacity(msg.Capacity)
+			}
+			if _, err := markAgentOnline(s.agentID, msg.Capacity); err != nil {
+				logger.L.Errorw("Failed to update agent heartbeat in database", "agent_id", s.agentID, "error", err)
+			}
+		case StreamMsgCapacity:
+			// 容量变化很频繁，只更新连接状态，数据库中的容量随心跳更新
+			if msg.Capacity != nil {
+				s.updateCapacity(msg.Capacity)
+			}
+		case StreamMsgOutput:
+			// 输出只用于实时查看，保存失败不影响任务执行，完整输出仍随结果上报
+			if err := engine.RecordTaskOutput(s.agentID, msg.Output); err != nil {
+				logger.L.Warnw("Failed to record task output from agent stream", "agent_id", s.agentID, "error", err)
+			}
+		case StreamMsgResult:
+			if msg.Result == nil || msg.Result.TaskID == "" || !validAgentResultStatus(msg.Result.Status) {
+				logger.L.Warnw("Invalid task result from agent stream", "agent_id", s.agentID)
+				continue
+			}
+			result := msg.Result
+			result.AgentID = s.agentID
+			// Agent 在执行过程中断线重连时，任务确认可能丢失，收到结果同样说明任务已送达
+			s.ack([]string{result.TaskID})
+			logger.L.Infow("Received task result from agent stream", "agent_id", s.agentID, "task_id", result.TaskID, "success", result.Success)
+			go engine.HandleTaskResult(result)
+			if err := s.send(StreamMessage{Type: StreamMsgResultAck, TaskID: result.TaskID}); err != nil {
+				return err
+			}
+		default:
+			logger.L.Warnw("Unknown message type from agent stream", "agent_id", s.agentID, "type", msg.Type)
+		}
+	}
+}
+
+func (s *agentStreamSession) send(msg StreamMessage) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	s.conn.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+	return websocket.JSON.Send(s.conn, msg)
+}
+
+// addPending 记录已下发、等待确认的任务，连接已关闭时返回 false
+func (s *agentStreamSession) addPending(tasks []*engine.Task) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return false
+	}
+	for _, task := range tasks {
+		s.pending[task.ID] = task
+	}
+	return true
+}
+
+// dispatchLimit 返回本轮最多领取的任务数，Agent 饱和时返回 0
+func (s *agentStreamSession) dispatchLimit() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.available < 0 {
+		return s.limit
+	}
+	return min(s.limit, s.available)
+}
+
+// consumeCapacity 扣减已下发任务占用的容量，直到 Agent 上报新的容量
+func (s *agentStreamSession) consumeCapacity(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.available >= 0 {
+		s.available = max(s.available-n, 0)
+	}
+}
+
+// updateCapacity 记录 Agent 上报的容量，并唤醒等待容量的 dispatchLoop
+func (s *agentStreamSession) updateCapacity(capacity *AgentCapacity) {
+	s.mu.Lock()
+	s.available = max(capacity.Available, 0)
+	s.mu.Unlock()
+	select {
+	case s.capacity <- struct{}{}:
+	default:
+	}
+}
+
+func (s *agentStreamSession) ack(taskIDs []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, taskID := range taskIDs {
+		delete(s.pending, taskID)
+	}
+}
+
+// close 关闭连接，并把 Agent 没有确认收到的任务放回队列
+func (s *agentStreamSession) close() {
+	s.mu.Lock()
+	s.closed = true
+	close(s.done)
+	s.cancel()
+	unacked := make([]*engine.Task, 0, len(s.pending))
+	for _, task := range s.pending {
+		unacked = append(unacked, task)
+	}
+	s.pending = nil
+	s.mu.Unlock()
+
+	s.conn.Close()
+	for _, task := range unacked {
+		engine.RequeueTask(task)
+	}
+}
@@ -0,0 +1,100 @@
+package api
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/core/engine"
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/logger"
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/model"
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/store"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	// outputTailBatch 是每次从数据库读取的输出段数
+	outputTailBatch = 500
+	// outputTailPollInterval 是查询其他副本写入的输出的间隔，本副本写入的输出会立即推送
+	outputTailPollInterval = time.Second
+)
+
+// TaskOutputInfo 是推送给查看者的一段任务输出
+type TaskOutputInfo struct {
+	Seq       int       `json:"seq"`
+	Stream    string    `json:"stream"`
+	Data      string    `json:"data"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TailTaskOutput 以 Server-Sent Events 的形式实时推送任务输出
+// 先推送序号大于 since 的已保存输出 (默认从头开始)，然后持续推送新的输出，任务结束后发送 end 事件并关闭连接
+func TailTaskOutput(c *gin.Context) {
+	taskID := c.Param("id")
+	since := -1
+	if raw := c.Query("since"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			ParamError(c, "since must be an integer")
+			return
+		}
+		since = n
+	}
+
+	var task model.WorkflowTask
+	if err := store.DB.Select("id", "status").Where("id = ?", taskID).First(&task).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			Error(c, http.StatusNotFound, "Task not found.")
+			return
+		}
+		Error(c, http.StatusInternalServerError, "Failed to load task: "+err.Error())
+		return
+	}
+
+	notify, stop := engine.WatchTaskOutput(taskID)
+	defer stop()
+	ticker := time.NewTicker(outputTailPollInterval)
+	defer ticker.Stop()
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no") // 避免反向代理缓冲
+	c.Stream(func(w io.Writer) bool {
+		// 先读取任务状态再读取输出: 任务结束后不会再有新的输出，这样不会漏掉最后一段
+		if err := store.DB.Select("id", "status").Where("id = ?", taskID).First(&task).Error; err != nil {
+			logger.L.Errorw("Failed to load task while tailing output", "task_id", taskID, "error", err)
+			return false
+		}
+		chunks, err := engine.TaskOutputSince(taskID, since, outputTailBatch)
+		if err != nil {
+			logger.L.Errorw("Failed to load task output", "task_id", taskID, "error", err)
+			return false
+		}
+		for _, chunk := range chunks {
+			c.SSEvent("output", TaskOutputInfo{
+				Seq:       chunk.Seq,
+				Stream:    chunk.Stream,
+				Data:      string(chunk.Data),
+				CreatedAt: chunk.CreatedAt,
+			})
+			since = chunk.Seq
+		}
+		if len(chunks) == outputTailBatch {
+			return true
+		}
+		if len(chunks) == 0 && engine.IsTaskFinished(task.Status) {
+			c.SSEvent("end", gin.H{"status": task.Status})
+			return false
+		}
+
+		select {
+		case <-notify:
+		case <-ticker.C:
+		case <-c.Request.Context().Done():
+			return false
+		}
+		return true
+	})
+}
@@ -0,0 +1,227 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/core/engine"
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/logger"
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/model"
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/report"
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/store"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type WorkflowInfo struct {
+	ID            string     `json:"id"`
+	KBID          string     `json:"kb_id"`
+	AgentID       string     `json:"agent_id"`
+	Status        string     `json:"status"`
+	Priority      string     `json:"priority"`
+	StatusReason  string     `json:"status_reason"`
+	CurrentTaskID string     `json:"current_task_id"`
+	ResumeStatus  string     `json:"resume_status"`
+	DeferredUntil *time.Time `json:"deferred_until"`
+	Version       int        `json:"version"`
+	IncidentID    string     `json:"incident_id"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+	FinishedAt    *time.Time `json:"finished_at"`
+}
+
+type WorkflowTransitionInfo struct {
+	FromStatus string    `json:"from_status"`
+	ToStatus   string    `json:"to_status"`
+	Reason     string    `json:"reason"`
+	Version    int       `json:"version"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func toWorkflowInfo(workflow model.Workflow) WorkflowInfo {
+	return WorkflowInfo{
+		ID:            workflow.ID,
+		KBID:          workflow.KBID,
+		AgentID:       workflow.AgentID,
+		Status:        workflow.Status,
+		Priority:      engine.Priority(workflow.Priority).String(),
+		StatusReason:  workflow.StatusReason,
+		CurrentTaskID: workflow.CurrentTaskID,
+		ResumeStatus:  workflow.ResumeStatus,
+		DeferredUntil: workflow.DeferredUntil,
+		Version:       workflow.Version,
+		IncidentID:    workflow.IncidentID,
+		CreatedAt:     workflow.CreatedAt,
+		UpdatedAt:     workflow.UpdatedAt,
+		FinishedAt:    workflow.FinishedAt,
+	}
+}
+
+// ListWorkflows 查询工作流列表，支持按 status / agent_id / kb_id / incident_id 过滤
+func ListWorkflows(c *gin.Context) {
+	query := store.DB.Order("created_at desc")
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if agentID := c.Query("agent_id"); agentID != "" {
+		query = query.Where("agent_id = ?", agentID)
+	}
+	if kbID := c.Query("kb_id"); kbID != "" {
+		query = query.Where("kb_id = ?", kbID)
+	}
+	if incidentID := c.Query("incident_id"); incidentID != "" {
+		query = query.Where("incident_id = ?", incidentID)
+	}
+
+	var workflows []model.Workflow
+	if err := query.Limit(200).Find(&workflows).Error; err != nil {
+		logger.L.Errorw("Failed to list workflows", "error", err)
+		Error(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	workflowInfos := make([]WorkflowInfo, 0, len(workflows))
+	for _, workflow := range workflows {
+		workflowInfos = append(workflowInfos, toWorkflowInfo(workflow))
+	}
+	Success(c, workflowInfos)
+}
+
+// GetWorkflow 查询单个工作流的当前状态
+func GetWorkflow(c *gin.Context) {
+	workflow, ok := findWorkflow(c)
+	if !ok {
+		return
+	}
+	Success(c, toWorkflowInfo(*workflow))
+}
+
+// GetWorkflowTransitions 查询工作流的状态流转历史 (按时间正序)
+func GetWorkflowTransitions(c *gin.Context) {
+	workflow, ok := findWorkflow(c)
+	if !ok {
+		return
+	}
+
+	var transitions []model.WorkflowTransition
+	if err := store.DB.Where("workflow_id = ?", workflow.ID).Order("version asc, id asc").Find(&transitions).Error; err != nil {
+		logger.L.Errorw("Failed to get workflow transitions", "workflow_id", workflow.ID, "error", err)
+		Error(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	transitionInfos := make([]WorkflowTransitionInfo, 0, len(transitions))
+	for _, t := range transitions {
+		transitionInfos = append(transitionInfos, WorkflowTransitionInfo{
+			FromStatus: t.FromStatus,
+			ToStatus:   t.ToStatus,
+			Reason:     t.Reason,
+			Version:    t.Version,
+			CreatedAt:  t.CreatedAt,
+		})
+	}
+	Success(c, transitionInfos)
+}
+
+// GetWorkflowReport 导出工作流的执行报告，用于事故复盘
+// format 支持 json (默认) / md / html
+func GetWorkflowReport(c *gin.Context) {
+	format := c.DefaultQuery("format", report.FormatJSON)
+	if format != report.FormatJSON && format != report.FormatMarkdown && format != report.FormatHTML {
+		ParamError(c, report.ErrUnknownFormat.Error())
+		return
+	}
+
+	workflow, ok := findWorkflow(c)
+	if !ok {
+		return
+	}
+
+	workflowReport, err := report.BuildWorkflowReport(workflow)
+	if err != nil {
+		logger.L.Errorw("Failed to build workflow report", "workflow_id", workflow.ID, "error", err)
+		Error(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if format == report.FormatJSON {
+		Success(c, workflowReport)
+		return
+	}
+
+	content, contentType, err := report.Render(workflowReport, format)
+	if err != nil {
+		logger.L.Errorw("Failed to render workflow report", "workflow_id", workflow.ID, "format", format, "error", err)
+		Error(c, http.StatusInternalServerError, "Failed to render report")
+		return
+	}
+	c.Data(http.StatusOK, contentType, content)
+}
+
+// ReleaseWorkflow 人工放行一个被推迟或挂起的工作流，立即继续执行被阻止的步骤
+func ReleaseWorkflow(c *gin.Context) {
+	workflow, ok := findWorkflow(c)
+	if !ok {
+		return
+	}
+
+	var req WorkflowActionRequest
+	_ = c.ShouldBindJSON(&req) // 请求体是可选的
+	reason := "released by operator"
+	if req.Reason != "" {
+		reason += ": " + req.Reason
+	}
+
+	if err := engine.ResumeWorkflow(workflow, reason, true); err != nil {
+		respondWorkflowActionError(c, workflow, err)
+		return
+	}
+
+	logger.L.Infow("Workflow released by operator", "workflow_id", workflow.ID, "status", workflow.Status)
+	Success(c, toWorkflowInfo(*workflow))
+}
+
+// AbortWorkflow 人工终止一个尚未结束的工作流
+func AbortWorkflow(c *gin.Context) {
+	workflow, ok := findWorkflow(c)
+	if !ok {
+		return
+	}
+
+	var req WorkflowActionRequest
+	_ = c.ShouldBindJSON(&req) // 请求体是可选的
+
+	if err := engine.AbortWorkflow(workflow, req.Reason); err != nil {
+		respondWorkflowActionError(c, workflow, err)
+		return
+	}
+
+	logger.L.Infow("Workflow aborted by operator", "workflow_id", workflow.ID, "reason", req.Reason)
+	Success(c, toWorkflowInfo(*workflow))
+}
+
+// respondWorkflowActionError 把引擎返回的错误映射为合适的响应码
+func respondWorkflowActionError(c *gin.Context, workflow *model.Workflow, err error) {
+	switch {
+	case errors.Is(err, engine.ErrInvalidTransition), errors.Is(err, engine.ErrWorkflowConflict):
+		Error(c, http.StatusConflict, err.Error())
+	default:
+		logger.L.Errorw("Workflow action failed", "workflow_id", workflow.ID, "error", err)
+		Error(c, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// findWorkflow 根据路径参数 :id 查询工作流，找不到时直接写入错误响应
+func findWorkflow(c *gin.Context) (*model.Workflow, bool) {
+	var workflow model.Workflow
+	if err := store.DB.Where("id = ?", c.Param("id")).First(&workflow).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			Error(c, http.StatusNotFound, "Workflow not found.")
+			return nil, false
+		}
+		logger.L.Errorw("Failed to get workflow", "workflow_id", c.Param("id"), "error", err)
+		Error(c, http.StatusInternalServerError, "Database error")
+		return nil, false
+	}
+	return &workflow, true
+}
@@ -61,8 +61,120 @@ func NewRouter() *gin.Engine {
 		agentGroup.GET("", GetAllAgents)
 		agentGroup.POST("/register", RegisterAgent)
 		agentGroup.POST("/heartbeat", Heartbeat)
-		agentGroup.GET("/tasks", GetTasks) // 长轮询接口
+		agentGroup.GET("/tasks", GetTasks)     // 长轮询接口
+		agentGroup.GET("/stream", AgentStream) // 持久连接 (WebSocket)，不可用时 Agent 回退到长轮询
 		agentGroup.POST("/tasks/results", PostTaskResults)
+		agentGroup.POST("/tasks/output", PostTaskOutput)         // 持久连接不可用时上报任务输出
+		agentGroup.GET("/tasks/:id/file", DownloadTaskFileChunk) // file_push 任务下载文件分块
+		agentGroup.PUT("/tasks/:id/file", UploadTaskFileChunk)   // file_pull 任务上传文件分块
+		agentGroup.POST("/tasks/:id/file/complete", CompleteTaskFileUpload)
+		agentGroup.PUT("/:id/group", UpdateAgentGroup)
+		agentGroup.PUT("/:id/capabilities", UpdateAgentCapabilities) // Agent 启动时上报支持的任务执行方式
+		agentGroup.PUT("/:id/maintenance", SetAgentMaintenance)
+		agentGroup.DELETE("/:id/maintenance", ClearAgentMaintenance)
+	}
+
+	// --- 工作流相关的 API 路由组 ---
+	workflowGroup := router.Group("/api/v1/workflows")
+	{
+		workflowGroup.GET("", ListWorkflows)
+		workflowGroup.GET("/:id", GetWorkflow)
+		workflowGroup.GET("/:id/transitions", GetWorkflowTransitions)
+		workflowGroup.GET("/:id/report", GetWorkflowReport)
+		workflowGroup.POST("/:id/release", ReleaseWorkflow)
+		workflowGroup.POST("/:id/abort", AbortWorkflow)
+	}
+
+	// --- 任务相关的 API 路由组 ---
+	taskGroup := router.Group("/api/v1/tasks")
+	{
+		taskGroup.GET("/:id/output", TailTaskOutput) // Server-Sent Events
+	}
+
+	// --- 脚本相关的 API 路由组 ---
+	scriptGroup := router.Group("/api/v1/scripts")
+	{
+		scriptGroup.GET("", ListScripts)
+		scriptGroup.POST("", CreateScript)
+		scriptGroup.GET("/:id", GetScript)
+		scriptGroup.PUT("/:id", UpdateScript)
+		scriptGroup.DELETE("/:id", DeleteScript)
+	}
+
+	// --- 文件相关的 API 路由组 (file_push / file_pull 任务) ---
+	fileGroup := router.Group("/api/v1/files")
+	{
+		fileGroup.GET("", ListFiles)
+		fileGroup.POST("", UploadFile)
+		fileGroup.GET("/:id", GetFile)
+		fileGroup.GET("/:id/content", DownloadFile)
+		fileGroup.DELETE("/:id", DeleteFile)
+	}
+
+	// --- 维护窗口相关的 API 路由组 ---
+	maintenanceWindowGroup := router.Group("/api/v1/maintenance-windows")
+	{
+		maintenanceWindowGroup.GET("", ListMaintenanceWindows)
+		maintenanceWindowGroup.POST("", CreateMaintenanceWindow)
+		maintenanceWindowGroup.PUT("/:id", UpdateMaintenanceWindow)
+		maintenanceWindowGroup.DELETE("/:id", DeleteMaintenanceWindow)
+	}
+
+	// --- 知识库计划 (周期性工作流) 相关的 API 路由组 ---
+	scheduleGroup := router.Group("/api/v1/schedules")
+	{
+		scheduleGroup.GET("", ListSchedules)
+		scheduleGroup.POST("", CreateSchedule)
+		scheduleGroup.GET("/:id", GetSchedule)
+		scheduleGroup.PUT("/:id", UpdateSchedule)
+		scheduleGroup.DELETE("/:id", DeleteSchedule)
+		scheduleGroup.POST("/:id/run", RunScheduleNow)
+		scheduleGroup.GET("/:id/runs", ListScheduleRuns)
+	}
+
+	// --- Agent 任务队列相关的 API 路由组 ---
+	queueGroup := router.Group("/api/v1/queues")
+	{
+		queueGroup.GET("", ListQueues)
+		queueGroup.GET("/:agent_id", GetAgentQueue)
+	}
+
+	// --- 修复限制 (护栏) 相关的 API 路由组 ---
+	guardrailGroup := router.Group("/api/v1/guardrails")
+	{
+		guardrailGroup.GET("", ListGuardrails)
+		guardrailGroup.PUT("/:kb_id", SetKBGuardrail)
+		guardrailGroup.DELETE("/:kb_id", DeleteKBGuardrail)
+	}
+
+	// --- 事件与告警相关的 API 路由组 ---
+	incidentGroup := router.Group("/api/v1/incidents")
+	{
+		incidentGroup.GET("", ListIncidents)
+		incidentGroup.GET("/:id", GetIncident)
+		incidentGroup.POST("/:id/acknowledge", AcknowledgeIncident)
+		incidentGroup.POST("/:id/resolve", ResolveIncident)
+		incidentGroup.POST("/:id/reopen", ReopenIncident)
+		incidentGroup.PUT("/:id/assignee", UpdateIncidentAssignee)
+		incidentGroup.POST("/:id/notes", AddIncidentNote)
+	}
+	alertGroup := router.Group("/api/v1/alerts")
+	{
+		alertGroup.GET("", ListAlerts)
+		alertGroup.POST("", CreateAlert)
+	}
+
+	// --- 知识库效果统计 API 路由组 ---
+	analyticsGroup := router.Group("/api/v1/analytics")
+	{
+		analyticsGroup.GET("/kb", ListKBStats)
+		analyticsGroup.GET("/kb/:kb_id", GetKBStats)
+	}
+
+	// --- 工作流离线模拟 API 路由组 ---
+	simulationGroup := router.Group("/api/v1/simulations")
+	{
+		simulationGroup.POST("", SimulateWorkflow)
 	}
 
 	// --- 内部测试用的 API 路由组 ---
@@ -1,9 +1,24 @@
 package api
 
+import (
+	"time"
+
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/core/engine"
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/model"
+)
+
 // TriggerKBRequest 定义了手动触发知识库工作流的请求体结构
 type TriggerKBRequest struct {
 	AgentID string `json:"agent_id" binding:"required"` // agent_id 是必需的
 	KBID    string `json:"kb_id" binding:"required"`    // kb_id 是必需的
+	// Priority 可选 "low", "normal", "high", "urgent"，默认 normal
+	Priority string `json:"priority"`
+	// Preempt 为 true 时，取消该 Agent 上排队中、优先级更低的任务
+	Preempt bool `json:"preempt"`
+	// NotBefore 可选，诊断任务在该时间之前不会分发给 Agent
+	NotBefore *time.Time `json:"not_before"`
+	// TTL 可选，诊断任务的有效期 (例如 "15m")，过期前未被领取则工作流失败，默认使用 task_queue.default_ttl
+	TTL string `json:"ttl"`
 }
 
 // RegisterAgentRequest 定义了 Agent 注册的请求体结构
@@ -11,6 +26,9 @@ type RegisterAgentRequest struct {
 	Hostname  string `json:"hostname" binding:"required"`
 	IPAddress string `json:"ip_address" binding:"required"`
 	OS        string `json:"os" binding:"required"`
+	Group     string `json:"group"` // 可选，Agent 所属分组
+	// Capabilities 是 Agent 支持的任务执行方式 (内置的 "command"、"script" 等以及插件)，旧版本 Agent 不上报
+	Capabilities []string `json:"capabilities"`
 }
 
 // RegisterAgentResponse 定义了 Agent 注册的响应体结构
@@ -19,7 +37,136 @@ type RegisterAgentResponse struct {
 	Message string `json:"message"`
 }
 
+// PostTaskOutputRequest 定义了 Agent 上报任务执行过程中输出的请求体结构
+type PostTaskOutputRequest struct {
+	AgentID string                   `json:"agent_id" binding:"required"`
+	Chunks  []engine.TaskOutputChunk `json:"chunks" binding:"required"`
+}
+
+// CompleteFileUploadRequest 定义了 Agent 完成 file_pull 任务文件上传的请求体结构
+type CompleteFileUploadRequest struct {
+	AgentID string `json:"agent_id" binding:"required"`
+	Name    string `json:"name"`
+	Path    string `json:"path"`
+	Size    int64  `json:"size"`
+	SHA256  string `json:"sha256" binding:"required"`
+}
+
 // HeartbeatRequest 定义了 Agent 心跳的请求体结构
 type HeartbeatRequest struct {
 	AgentID string `json:"agent_id" binding:"required"`
+	// Capacity 是 Agent 当前的任务执行容量，旧版本 Agent 不上报
+	Capacity *AgentCapacity `json:"capacity"`
+}
+
+// AgentCapacity 是 Agent 上报的任务执行容量，与 Agent 端的 client.Capacity 一致
+type AgentCapacity struct {
+	MaxConcurrency int `json:"max_concurrency"`
+	QueueSize      int `json:"queue_size"`
+	Running        int `json:"running"`
+	Queued         int `json:"queued"`
+	// Available 是 Agent 还能接收的任务数，为 0 时不再向它下发任务
+	Available int `json:"available"`
+}
+
+// ScheduleRequest 定义了创建或更新知识库计划的请求体结构
+type ScheduleRequest struct {
+	Name          string              `json:"name" binding:"required"`
+	CronExpr      string              `json:"cron_expr" binding:"required"` // 标准 5 段 cron 表达式，例如 "0 2 * * *"
+	KBID          string              `json:"kb_id" binding:"required"`
+	AgentSelector model.AgentSelector `json:"agent_selector"`
+	Priority      string              `json:"priority"` // "low", "normal", "high", "urgent"，默认 normal
+	Enabled       *bool               `json:"enabled"`  // 不传时默认启用
+}
+
+// ScriptRequest 定义了创建或更新脚本的请求体结构
+type ScriptRequest struct {
+	Name        string `json:"name" binding:"required"`
+	Description string `json:"description"`
+	Interpreter string `json:"interpreter"` // "sh" (默认)、"bash"、"python3" 或 "direct" (按 shebang 直接执行)
+	Body        string `json:"body" binding:"required"`
+}
+
+// UpdateAgentGroupRequest 定义了修改 Agent 分组的请求体结构
+type UpdateAgentGroupRequest struct {
+	Group string `json:"group"` // 传空字符串表示移出分组
+}
+
+// UpdateAgentCapabilitiesRequest 定义了 Agent 上报支持的任务执行方式的请求体结构
+type UpdateAgentCapabilitiesRequest struct {
+	Capabilities []string `json:"capabilities" binding:"required"`
+}
+
+// MaintenanceWindowRequest 定义了创建或更新维护窗口的请求体结构
+type MaintenanceWindowRequest struct {
+	Name       string `json:"name" binding:"required"`
+	Mode       string `json:"mode" binding:"required"`   // "allow" 或 "block"
+	Action     string `json:"action" binding:"required"` // "defer", "hold" 或 "reject"
+	Timezone   string `json:"timezone"`                  // 例如 "Asia/Shanghai"，为空时使用 UTC
+	Weekdays   []int  `json:"weekdays"`                  // 0 = 周日，为空表示每天
+	StartTime  string `json:"start_time" binding:"required"`
+	EndTime    string `json:"end_time" binding:"required"`
+	AgentGroup string `json:"agent_group"`
+	KBID       string `json:"kb_id"`
+	Enabled    *bool  `json:"enabled"` // 不传时默认启用
+}
+
+// WorkflowActionRequest 定义了对工作流执行人工操作 (放行、终止) 的请求体结构
+type WorkflowActionRequest struct {
+	Reason string `json:"reason"`
+}
+
+// AgentMaintenanceRequest 定义了让 Agent 进入维护模式的请求体结构
+// duration 和 until 二选一，都不传时默认维护 1 小时
+type AgentMaintenanceRequest struct {
+	Reason   string     `json:"reason" binding:"required"`
+	Duration string     `json:"duration"` // 例如 "2h", "30m"
+	Until    *time.Time `json:"until"`    // RFC3339 格式的到期时间
+	SetBy    string     `json:"set_by"`
+}
+
+// SimulationRequest 定义了离线模拟工作流的请求体结构
+// 知识库条目: 优先使用 kb_item，其次按 kb_id 查询，都为空时使用 workflow_id 对应工作流的知识库条目
+// 任务结果: 优先使用 results，为空时回放 workflow_id 对应工作流的历史结果
+type SimulationRequest struct {
+	KBID       string                    `json:"kb_id"`
+	KBItem     *engine.KnowledgeBaseItem `json:"kb_item"` // 尚未保存的知识库条目，用于测试修改
+	WorkflowID string                    `json:"workflow_id"`
+	Results    []engine.TaskResult       `json:"results"`
+}
+
+// AlertRequest 定义了上报告警的请求体结构
+type AlertRequest struct {
+	Type       string `json:"type" binding:"required"` // 告警类型，例如 "dns_failure"
+	Source     string `json:"source"`                  // 告警来源，例如 "prometheus"
+	Severity   string `json:"severity"`                // "info", "warning", "critical"，默认 warning
+	AgentID    string `json:"agent_id"`
+	AgentGroup string `json:"agent_group"` // 为空时取 agent_id 对应 Agent 的分组
+	KBID       string `json:"kb_id"`       // 告警对应的知识库条目，用于和工作流聚合到同一事件
+	Message    string `json:"message"`
+}
+
+// IncidentActionRequest 定义了确认、解决、重新打开事件的请求体结构
+type IncidentActionRequest struct {
+	Assignee string `json:"assignee"` // 确认时可以同时指定处理人
+	Author   string `json:"author"`
+	Note     string `json:"note"` // 可选，会作为一条备注记录下来
+}
+
+// IncidentAssigneeRequest 定义了修改事件处理人的请求体结构
+type IncidentAssigneeRequest struct {
+	Assignee string `json:"assignee"` // 传空字符串表示取消指派
+}
+
+// IncidentNoteRequest 定义了添加事件备注的请求体结构
+type IncidentNoteRequest struct {
+	Author  string `json:"author"`
+	Content string `json:"content" binding:"required"`
+}
+
+// GuardrailRequest 定义了设置知识库条目修复限制的请求体结构，0 表示不限制
+type GuardrailRequest struct {
+	MaxConcurrent   int `json:"max_concurrent"`
+	MaxPerHour      int `json:"max_per_hour"`
+	MaxGroupPercent int `json:"max_group_percent"` // 1-100
 }
@@ -0,0 +1,69 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/config"
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/logger"
+)
+
+// ErrNotFound 表示 key 对应的内容不存在
+var ErrNotFound = errors.New("blob not found")
+
+// Store 是文件内容的存储，按 key 读写，文件的元数据 (名称、来源等) 由调用方保存在数据库中
+// 实现必须可以被多个 goroutine 并发使用
+type Store interface {
+	// Put 写入 key 对应的内容，已存在时覆盖；写入过程中失败不会留下不完整的内容
+	Put(ctx context.Context, key string, r io.Reader) error
+	// GetRange 读取 key 对应内容中从 offset 开始的 length 个字节，length < 0 表示读到结尾
+	GetRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error)
+	// Size 返回 key 对应内容的字节数
+	Size(ctx context.Context, key string) (int64, error)
+	// Delete 删除 key 对应的内容，不存在时不报错
+	Delete(ctx context.Context, key string) error
+}
+
+// S 是全局的文件存储
+var S Store
+
+// Init 按配置初始化全局的文件存储
+func Init() {
+	backend := config.C.Blob.Backend
+	switch backend {
+	case "", "local":
+		dir := config.C.Blob.LocalDir
+		if dir == "" {
+			dir = "./data/blobs"
+		}
+		store, err := NewLocalStore(dir)
+		if err != nil {
+			logger.L.Fatalw("Failed to initialize local blob store", "dir", dir, "error", err)
+		}
+		S = store
+		logger.L.Infow("Blob store initialized", "backend", "local", "dir", dir)
+	default:
+		logger.L.Fatalw("Unsupported blob store backend", "backend", backend)
+	}
+}
+
+// validKey 检查 key 是否合法: 由字母、数字、'-'、'_'、'.' 组成的若干段，以 '/' 分隔，不允许 ".." 等相对路径
+func validKey(key string) error {
+	if key == "" {
+		return errors.New("empty blob key")
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." {
+			return fmt.Errorf("invalid blob key %q", key)
+		}
+		for _, r := range part {
+			if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.') {
+				return fmt.Errorf("invalid blob key %q", key)
+			}
+		}
+	}
+	return nil
+}
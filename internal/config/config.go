@@ -5,11 +5,18 @@ import "github.com/spf13/viper"
 
 // Config 是整个应用程序的配置结构体
 type Config struct {
-	Server   ServerConfig   `mapstructure:"server"`
-	Database DatabaseConfig `mapstructure:"database"`
-	Kafka    KafkaConfig    `mapstructure:"kafka"`
-	Logger   LoggerConfig   `mapstructure:"logger"`
-	Agent    AgentConfig    `mapstructure:"agent"`
+	Server     ServerConfig     `mapstructure:"server"`
+	Database   DatabaseConfig   `mapstructure:"database"`
+	Kafka      KafkaConfig      `mapstructure:"kafka"`
+	Logger     LoggerConfig     `mapstructure:"logger"`
+	Agent      AgentConfig      `mapstructure:"agent"`
+	Workflow   WorkflowConfig   `mapstructure:"workflow"`
+	Report     ReportConfig     `mapstructure:"report"`
+	Analytics  AnalyticsConfig  `mapstructure:"analytics"`
+	Incident   IncidentConfig   `mapstructure:"incident"`
+	Guardrails GuardrailsConfig `mapstructure:"guardrails"`
+	TaskQueue  TaskQueueConfig  `mapstructure:"task_queue"`
+	Blob       BlobConfig       `mapstructure:"blob"`
 }
 
 // ServerConfig 对应 server 部分的配置
@@ -64,6 +71,58 @@ type AgentConfig struct {
 	OfflineCheckCron string `mapstructure:"offline_check_cron"`
 }
 
+// WorkflowConfig 对应 workflow 部分的配置
+type WorkflowConfig struct {
+	DeferredCheckCron string `mapstructure:"deferred_check_cron"` // 检查推迟到期工作流的频率
+}
+
+// ReportConfig 对应 report 部分的配置
+type ReportConfig struct {
+	TemplateDir  string `mapstructure:"template_dir"`  // 自定义模板目录，存在 workflow.md.tmpl / workflow.html.tmpl 时覆盖内置模板
+	ExcerptBytes int    `mapstructure:"excerpt_bytes"` // 报告中每个步骤输出摘录的最大字节数
+}
+
+// AnalyticsConfig 对应 analytics 部分的配置
+type AnalyticsConfig struct {
+	Window               string  `mapstructure:"window"`                 // 默认统计最近多长时间内的工作流，例如 "720h"
+	SuccessRateThreshold float64 `mapstructure:"success_rate_threshold"` // 修复成功率低于该值的知识库条目会被标记
+	MinSamples           int     `mapstructure:"min_samples"`            // 修复次数少于该值时不做标记，避免样本太少误报
+	CheckCron            string  `mapstructure:"check_cron"`             // 定期检查并告警低成功率知识库条目的频率
+}
+
+// IncidentConfig 对应 incident 部分的配置
+type IncidentConfig struct {
+	GroupWindow string `mapstructure:"group_window"` // 同一事件两次事件之间的最大间隔，超过后新建事件
+}
+
+// GuardrailsConfig 对应 guardrails 部分的配置，是整个集群的修复爆炸半径限制，0 表示不限制
+type GuardrailsConfig struct {
+	MaxConcurrentRemediations int `mapstructure:"max_concurrent_remediations" json:"max_concurrent_remediations"` // 同时处于修复中的工作流数上限
+	MaxRemediationsPerHour    int `mapstructure:"max_remediations_per_hour" json:"max_remediations_per_hour"`     // 最近一小时内开始修复的次数上限
+	MaxGroupPercent           int `mapstructure:"max_group_percent" json:"max_group_percent"`                     // 同一 Agent 分组中同时修复的 Agent 占比上限 (1-100)
+}
+
+// TaskQueueConfig 对应 task_queue 部分的配置
+type TaskQueueConfig struct {
+	// Backend 为 "memory" (默认) 时任务队列保存在进程内，只支持单副本部署；
+	// 为 "postgres" 时队列保存在 Postgres 中，多个后端副本通过 LISTEN/NOTIFY 协同分发任务
+	Backend       string `mapstructure:"backend"`
+	MaxDepth      int    `mapstructure:"max_depth"`      // 每个 Agent 任务队列的最大任务数
+	MaxBatch      int    `mapstructure:"max_batch"`      // Agent 一次拉取最多能拿到的任务数 (Agent 通过 max 参数请求，不超过该值)
+	RetryInterval string `mapstructure:"retry_interval"` // 队列已满时，工作流推迟多久后重新提交任务
+	IdleTimeout   string `mapstructure:"idle_timeout"`   // 空的 Agent 队列超过多久没有提交或拉取任务后被清理
+	CleanupCron   string `mapstructure:"cleanup_cron"`   // 清理不活跃队列的频率
+	DefaultTTL    string `mapstructure:"default_ttl"`    // 任务的默认有效期，超过后未分发的任务过期，"0" 表示不过期
+	ExpiryCron    string `mapstructure:"expiry_cron"`    // 清理队列中过期任务的频率
+}
+
+// BlobConfig 对应 blob 部分的配置，用于保存文件传输任务的文件
+type BlobConfig struct {
+	Backend     string `mapstructure:"backend"`       // 目前只支持 "local" (默认)
+	LocalDir    string `mapstructure:"local_dir"`     // local 后端保存文件的目录，多副本部署时需要使用共享存储
+	MaxFileSize int64  `mapstructure:"max_file_size"` // 上传或从 Agent 收集的单个文件的最大字节数
+}
+
 // C 是一个全局变量，用于存储加载后的配置
 // 外部包可以通过 config.C 访问配置
 var C *Config
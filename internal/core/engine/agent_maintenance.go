@@ -0,0 +1,115 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/logger"
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/model"
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/store"
+	"gorm.io/gorm"
+)
+
+// ErrAgentInMaintenance 表示目标 Agent 处于维护模式，针对它的自动化被暂停
+var ErrAgentInMaintenance = errors.New("agent is in maintenance mode")
+
+// checkAgentMaintenance 检查 Agent 是否处于维护模式
+// 所有启动工作流的入口 (手动触发、计划任务、告警触发) 都经过 StartKBWorkflow，因此只需在这里检查一次
+func checkAgentMaintenance(agentID string) error {
+	var agent model.Agent
+	if err := store.DB.Where("uuid = ?", agentID).First(&agent).Error; err != nil {
+		return err
+	}
+	if agent.InMaintenance(time.Now()) {
+		return fmt.Errorf("%w until %s: %s", ErrAgentInMaintenance, agent.MaintenanceUntil.Format(time.RFC3339), agent.MaintenanceReason)
+	}
+	return nil
+}
+
+// SetAgentMaintenance 让 Agent 进入维护模式，直到 until 为止
+// 队列中还没有分发的任务会被移出，等待这些任务的工作流推迟到维护结束后重新下发；已经分发给 Agent 的任务不受影响
+func SetAgentMaintenance(agentID string, until time.Time, reason, setBy string) error {
+	updateData := map[string]interface{}{
+		"maintenance_until":  until,
+		"maintenance_reason": reason,
+		"maintenance_by":     setBy,
+	}
+	result := store.DB.Model(&model.Agent{}).Where("uuid = ?", agentID).UpdateColumns(updateData)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+
+	logger.L.Infow("Agent entered maintenance mode", "agent_id", agentID, "until", until, "reason", reason, "set_by", setBy)
+	holdQueuedTasks(agentID, until, reason)
+	return nil
+}
+
+// holdQueuedTasks 把 Agent 队列中的任务移出，并把仍在等待这些任务的工作流推迟到 until
+func holdQueuedTasks(agentID string, until time.Time, reason string) {
+	for _, task := range TM.RemoveQueued(agentID) {
+		recordTaskCancelled(task.ID, "held by agent maintenance mode: "+reason)
+		workflow := waitingWorkflow(task)
+		if workflow == nil {
+			continue
+		}
+		status := workflow.Status
+		if deferForMaintenance(workflow, status, until, reason) {
+			logger.L.Infow("Workflow deferred because its queued task was held by maintenance mode", "workflow_id", workflow.ID, "task_id", task.ID, "status", status)
+		}
+	}
+}
+
+// deferIfAgentInMaintenance 在 Agent 处于维护模式时把工作流推迟到维护结束并返回 true
+// 恢复诊断和回滚步骤前调用；修复步骤由 checkRemediationAllowed 检查维护模式
+func deferIfAgentInMaintenance(workflow *model.Workflow, resumeStatus string) bool {
+	var agent model.Agent
+	if err := store.DB.Where("uuid = ?", workflow.AgentID).First(&agent).Error; err != nil {
+		logger.L.Errorw("Failed to check agent maintenance mode", "workflow_id", workflow.ID, "agent_id", workflow.AgentID, "error", err)
+		return false
+	}
+	if !agent.InMaintenance(time.Now()) {
+		return false
+	}
+	deferForMaintenance(workflow, resumeStatus, *agent.MaintenanceUntil, agent.MaintenanceReason)
+	return true
+}
+
+// deferForMaintenance 把工作流推迟到 Agent 维护结束，到期 (或提前结束维护) 后由调度器重新提交 resumeStatus 对应步骤的任务
+func deferForMaintenance(workflow *model.Workflow, resumeStatus string, until time.Time, reason string) bool {
+	msg := fmt.Sprintf("%s deferred until %s: agent is in maintenance mode: %s", resumeStatus, until.Format(time.RFC3339), reason)
+	return transitionWorkflow(workflow, StatusDeferred, msg, map[string]interface{}{
+		"resume_status":  resumeStatus,
+		"deferred_until": until,
+	})
+}
+
+// ClearAgentMaintenance 提前结束 Agent 的维护模式
+// 因维护模式而推迟的工作流会被标记为立即到期，由调度器在下一轮重新评估并恢复
+func ClearAgentMaintenance(agentID string) error {
+	updateData := map[string]interface{}{
+		"maintenance_until":  nil,
+		"maintenance_reason": "",
+		"maintenance_by":     "",
+	}
+	result := store.DB.Model(&model.Agent{}).Where("uuid = ?", agentID).UpdateColumns(updateData)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+
+	err := store.DB.Model(&model.Workflow{}).
+		Where("agent_id = ? AND status = ? AND deferred_until > ?", agentID, StatusDeferred, time.Now()).
+		Update("deferred_until", time.Now()).Error
+	if err != nil {
+		logger.L.Errorw("Failed to expedite deferred workflows after maintenance", "agent_id", agentID, "error", err)
+	}
+
+	logger.L.Infow("Agent left maintenance mode", "agent_id", agentID)
+	return nil
+}
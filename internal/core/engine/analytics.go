@@ -0,0 +1,220 @@
+package engine
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/config"
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/store"
+)
+
+const (
+	defaultAnalyticsWindow      = 30 * 24 * time.Hour
+	defaultSuccessRateThreshold = 0.8
+	defaultMinSamples           = 5
+)
+
+// KBStats 是单个知识库条目在统计时间范围内的效果指标
+// 比率在分母为 0 时为 nil，表示没有样本而不是 0%
+type KBStats struct {
+	KBID      string `json:"kb_id"`
+	Triggered int64  `json:"triggered"` // 触发的工作流数
+	Completed int64  `json:"completed"`
+	Failed    int64  `json:"failed"`
+
+	DiagnosticRuns    int64    `json:"diagnostic_runs"` // 有结果的诊断任务数
+	DiagnosticHits    int64    `json:"diagnostic_hits"` // 诊断命中 (诊断任务成功) 的次数
+	DiagnosticHitRate *float64 `json:"diagnostic_hit_rate"`
+
+	RemediationRuns        int64    `json:"remediation_runs"`
+	RemediationSucceeded   int64    `json:"remediation_succeeded"`
+	RemediationSuccessRate *float64 `json:"remediation_success_rate"`
+
+	Rollbacks    int64    `json:"rollbacks"` // 执行过回滚的工作流数
+	RollbackRate *float64 `json:"rollback_rate"`
+
+	// MTTRSeconds 是修复成功的工作流从触发到完成的平均耗时 (mean time to remediate)
+	MTTRSeconds *float64 `json:"mttr_seconds"`
+
+	LastTriggeredAt *time.Time `json:"last_triggered_at"`
+
+	Flagged    bool   `json:"flagged"` // 修复成功率低于阈值
+	FlagReason string `json:"flag_reason,omitempty"`
+}
+
+// KBStatsQuery 是统计查询的参数
+type KBStatsQuery struct {
+	Since time.Time // 只统计在此之后触发的工作流
+	KBID  string    // 为空表示统计所有知识库条目
+}
+
+// DefaultAnalyticsSince 根据配置的 analytics.window 返回默认的统计起点
+func DefaultAnalyticsSince() time.Time {
+	window := defaultAnalyticsWindow
+	if config.C.Analytics.Window != "" {
+		if d, err := time.ParseDuration(config.C.Analytics.Window); err == nil && d > 0 {
+			window = d
+		}
+	}
+	return time.Now().Add(-window)
+}
+
+// ComputeKBStats 从工作流、任务记录中汇总每个知识库条目的效果指标，按触发次数从多到少排序
+func ComputeKBStats(query KBStatsQuery) ([]KBStats, error) {
+	statsByKB := make(map[string]*KBStats)
+	get := func(kbID string) *KBStats {
+		stats, ok := statsByKB[kbID]
+		if !ok {
+			stats = &KBStats{KBID: kbID}
+			statsByKB[kbID] = stats
+		}
+		return stats
+	}
+	kbFilter := ""
+	args := []interface{}{query.Since}
+	if query.KBID != "" {
+		kbFilter = " AND w.kb_id = ?"
+		args = append(args, query.KBID)
+	}
+
+	// 1. 工作流维度: 触发次数和最终结果
+	var workflowRows []struct {
+		KBID            string
+		Triggered       int64
+		Completed       int64
+		Failed          int64
+		LastTriggeredAt time.Time
+	}
+	err := store.DB.Raw(`
+		SELECT w.kb_id AS kb_id,
+			COUNT(*) AS triggered,
+			COUNT(*) FILTER (WHERE w.status = ?) AS completed,
+			COUNT(*) FILTER (WHERE w.status = ?) AS failed,
+			MAX(w.created_at) AS last_triggered_at
+		FROM workflows w
+		WHERE w.created_at >= ?`+kbFilter+`
+		GROUP BY w.kb_id`, append([]interface{}{StatusCompleted, StatusFailed}, args...)...).
+		Scan(&workflowRows).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range workflowRows {
+		stats := get(row.KBID)
+		stats.Triggered = row.Triggered
+		stats.Completed = row.Completed
+		stats.Failed = row.Failed
+		lastTriggeredAt := row.LastTriggeredAt
+		stats.LastTriggeredAt = &lastTriggeredAt
+	}
+
+	// 2. 任务维度: 诊断命中、修复成功、回滚
+	var taskRows []struct {
+		KBID                 string
+		DiagnosticRuns       int64
+		DiagnosticHits       int64
+		RemediationRuns      int64
+		RemediationSucceeded int64
+		Rollbacks            int64
+	}
+	err = store.DB.Raw(`
+		SELECT w.kb_id AS kb_id,
+			COUNT(*) FILTER (WHERE t.type = 'diagnostic' AND t.status IN ?) AS diagnostic_runs,
+			COUNT(*) FILTER (WHERE t.type = 'diagnostic' AND t.status = ?) AS diagnostic_hits,
+			COUNT(*) FILTER (WHERE t.type = 'remediation' AND t.status IN ?) AS remediation_runs,
+			COUNT(*) FILTER (WHERE t.type = 'remediation' AND t.status = ?) AS remediation_succeeded,
+			COUNT(DISTINCT t.workflow_id) FILTER (WHERE t.type = 'rollback') AS rollbacks
+		FROM workflow_tasks t
+		JOIN workflows w ON w.id = t.workflow_id
+		WHERE w.created_at >= ?`+kbFilter+`
+		GROUP BY w.kb_id`, append([]interface{}{
+		finishedTaskStatuses, TaskStatusSucceeded, finishedTaskStatuses, TaskStatusSucceeded,
+	}, args...)...).
+		Scan(&taskRows).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range taskRows {
+		stats := get(row.KBID)
+		stats.DiagnosticRuns = row.DiagnosticRuns
+		stats.DiagnosticHits = row.DiagnosticHits
+		stats.RemediationRuns = row.RemediationRuns
+		stats.RemediationSucceeded = row.RemediationSucceeded
+		stats.Rollbacks = row.Rollbacks
+	}
+
+	// 3. MTTR: 只统计修复任务成功、最终完成的工作流
+	var mttrRows []struct {
+		KBID        string
+		MTTRSeconds float64
+	}
+	err = store.DB.Raw(`
+		SELECT w.kb_id AS kb_id,
+			AVG(EXTRACT(EPOCH FROM (w.finished_at - w.created_at))) AS mttr_seconds
+		FROM workflows w
+		WHERE w.status = ? AND w.finished_at IS NOT NULL
+			AND EXISTS (SELECT 1 FROM workflow_tasks t WHERE t.workflow_id = w.id AND t.type = 'remediation' AND t.status = ?)
+			AND w.created_at >= ?`+kbFilter+`
+		GROUP BY w.kb_id`, append([]interface{}{StatusCompleted, TaskStatusSucceeded}, args...)...).
+		Scan(&mttrRows).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range mttrRows {
+		mttr := row.MTTRSeconds
+		get(row.KBID).MTTRSeconds = &mttr
+	}
+
+	threshold, minSamples := successRateThreshold()
+	result := make([]KBStats, 0, len(statsByKB))
+	for _, stats := range statsByKB {
+		stats.DiagnosticHitRate = ratio(stats.DiagnosticHits, stats.DiagnosticRuns)
+		stats.RemediationSuccessRate = ratio(stats.RemediationSucceeded, stats.RemediationRuns)
+		stats.RollbackRate = ratio(stats.Rollbacks, stats.RemediationRuns)
+		flagKBStats(stats, threshold, minSamples)
+		result = append(result, *stats)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Triggered != result[j].Triggered {
+			return result[i].Triggered > result[j].Triggered
+		}
+		return result[i].KBID < result[j].KBID
+	})
+	return result, nil
+}
+
+// finishedTaskStatuses 是已经有执行结果的任务状态，超时的任务也被执行过
+var finishedTaskStatuses = []string{TaskStatusSucceeded, TaskStatusFailed, TaskStatusTimedOut}
+
+// flagKBStats 在修复样本足够且成功率低于阈值时标记知识库条目
+func flagKBStats(stats *KBStats, threshold float64, minSamples int) {
+	if stats.RemediationSuccessRate == nil || stats.RemediationRuns < int64(minSamples) {
+		return
+	}
+	if *stats.RemediationSuccessRate < threshold {
+		stats.Flagged = true
+		stats.FlagReason = fmt.Sprintf("remediation success rate %.0f%% is below threshold %.0f%% (%d runs)",
+			*stats.RemediationSuccessRate*100, threshold*100, stats.RemediationRuns)
+	}
+}
+
+// successRateThreshold 返回配置的成功率阈值和最小样本数，未配置时使用默认值
+func successRateThreshold() (float64, int) {
+	threshold := config.C.Analytics.SuccessRateThreshold
+	if threshold <= 0 {
+		threshold = defaultSuccessRateThreshold
+	}
+	minSamples := config.C.Analytics.MinSamples
+	if minSamples <= 0 {
+		minSamples = defaultMinSamples
+	}
+	return threshold, minSamples
+}
+
+func ratio(numerator, denominator int64) *float64 {
+	if denominator == 0 {
+		return nil
+	}
+	r := float64(numerator) / float64(denominator)
+	return &r
+}
@@ -0,0 +1,60 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/model"
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/store"
+)
+
+// ErrKindUnsupported 表示任务的执行方式不在 Agent 上报的能力中
+var ErrKindUnsupported = errors.New("agent does not support this task kind")
+
+// kindPattern 是合法的执行方式名称，与 Agent 插件文件名的限制一致
+var kindPattern = regexp.MustCompile(`^[a-z][a-z0-9_.\-]{0,63}$`)
+
+// FormatCapabilities 校验 Agent 上报的执行方式，去重排序后转换为 model.Agent.Capabilities 的格式
+func FormatCapabilities(kinds []string) (string, error) {
+	normalized := make([]string, 0, len(kinds))
+	for _, kind := range kinds {
+		if !kindPattern.MatchString(kind) {
+			return "", fmt.Errorf("invalid task kind %q", kind)
+		}
+		normalized = append(normalized, kind)
+	}
+	slices.Sort(normalized)
+	return strings.Join(slices.Compact(normalized), ","), nil
+}
+
+// AgentCapabilities 返回 Agent 上报的执行方式，旧版本 Agent 返回 nil
+func AgentCapabilities(agent *model.Agent) []string {
+	if agent.Capabilities == "" {
+		return nil
+	}
+	return strings.Split(agent.Capabilities, ",")
+}
+
+// checkAgentCapability 确认任务的目标 Agent 支持任务的执行方式，避免任务被下发到无法执行它的 Agent
+// Agent 不存在或没有上报能力 (旧版本) 时不做检查，由 Agent 自己拒绝不支持的任务
+func checkAgentCapability(task *Task) error {
+	var agent model.Agent
+	if err := store.DB.Where("uuid = ?", task.AgentID).First(&agent).Error; err != nil {
+		return nil
+	}
+	capabilities := AgentCapabilities(&agent)
+	if capabilities == nil {
+		return nil
+	}
+	kind := task.Kind
+	if kind == "" {
+		kind = TaskKindCommand
+	}
+	if !slices.Contains(capabilities, kind) {
+		return fmt.Errorf("%w: %s (agent supports %s)", ErrKindUnsupported, kind, agent.Capabilities)
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package engine
+
+import (
+	"sync"
+
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/config"
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/store"
+	"gorm.io/gorm"
+)
+
+// Clustered 判断后端是否按多副本方式部署 (task_queue.backend = postgres)
+// 多副本部署时，进程内的互斥锁不足以保护跨副本共享的状态，需要额外使用 Postgres advisory lock
+func Clustered() bool {
+	return config.C.TaskQueue.Backend == TaskQueueBackendPostgres
+}
+
+// withClusterLock 持有本地互斥锁执行 fn；多副本部署时同时持有以 name 命名的 Postgres advisory lock，
+// 保证所有副本上同一时刻只有一个 fn 在执行
+// 返回 error 表示没能获取跨副本的锁，此时 fn 没有被执行
+func withClusterLock(mu *sync.Mutex, name string, fn func()) error {
+	mu.Lock()
+	defer mu.Unlock()
+	if !Clustered() {
+		fn()
+		return nil
+	}
+
+	// session 级别的 advisory lock 必须在同一个连接上加锁和解锁
+	return store.DB.Connection(func(conn *gorm.DB) error {
+		if err := conn.Exec("SELECT pg_advisory_lock(hashtext(?))", name).Error; err != nil {
+			return err
+		}
+		defer conn.Exec("SELECT pg_advisory_unlock(hashtext(?))", name)
+		fn()
+		return nil
+	})
+}
@@ -0,0 +1,90 @@
+package engine
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// conditionPattern 匹配 "<路径> <运算符> <值>"，例如 "used_percent > 90"、"active_state != \"active\""
+var conditionPattern = regexp.MustCompile(`^\s*([A-Za-z0-9_.\-]+)\s*(==|!=|>=|<=|>|<)\s*(.+?)\s*$`)
+
+// evalCondition 用内置动作返回的结构化结果 (JSON) 判断条件是否成立
+// 路径以 '.' 分隔，数字表示数组下标，数组的 "length" 是数组长度，例如 "partitions.0.used_percent"、"processes.length"
+// 值按 JSON 解析 (数字、带引号的字符串、true、false、null)，无法解析时作为字符串比较
+// > >= < <= 只能比较数字，路径不存在时返回错误
+func evalCondition(cond string, data json.RawMessage) (bool, error) {
+	m := conditionPattern.FindStringSubmatch(cond)
+	if m == nil {
+		return false, fmt.Errorf("invalid condition %q, expected \"<path> <op> <value>\"", cond)
+	}
+	path, op, literal := m[1], m[2], m[3]
+
+	if len(data) == 0 {
+		return false, errors.New("task returned no structured data")
+	}
+	var root interface{}
+	if err := json.Unmarshal(data, &root); err != nil {
+		return false, fmt.Errorf("task returned invalid structured data: %w", err)
+	}
+	actual, err := lookupPath(root, path)
+	if err != nil {
+		return false, err
+	}
+	var expected interface{}
+	if err := json.Unmarshal([]byte(literal), &expected); err != nil {
+		expected = literal
+	}
+
+	switch op {
+	case "==":
+		return reflect.DeepEqual(actual, expected), nil
+	case "!=":
+		return !reflect.DeepEqual(actual, expected), nil
+	}
+	a, ok1 := actual.(float64)
+	b, ok2 := expected.(float64)
+	if !ok1 || !ok2 {
+		return false, fmt.Errorf("%s requires numbers, got %v and %v", op, actual, expected)
+	}
+	switch op {
+	case ">":
+		return a > b, nil
+	case ">=":
+		return a >= b, nil
+	case "<":
+		return a < b, nil
+	default:
+		return a <= b, nil
+	}
+}
+
+func lookupPath(value interface{}, path string) (interface{}, error) {
+	for _, key := range strings.Split(path, ".") {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			next, ok := v[key]
+			if !ok {
+				return nil, fmt.Errorf("path %q not found in task data", path)
+			}
+			value = next
+		case []interface{}:
+			if key == "length" {
+				value = float64(len(v))
+				continue
+			}
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil, fmt.Errorf("path %q not found in task data", path)
+			}
+			value = v[i]
+		default:
+			return nil, fmt.Errorf("path %q not found in task data", path)
+		}
+	}
+	return value, nil
+}
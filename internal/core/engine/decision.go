@@ -0,0 +1,210 @@
+package engine
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// decision 是工作流在某个状态下收到任务结果后的下一步
+type decision struct {
+	Status string // 下一个状态
+	Reason string
+	// Task 是进入下一个状态时需要下发的任务 (只填写 Type 和 Command)，不需要下发任务时为空
+	Task *Task
+}
+
+// decideNext 根据当前状态、任务结果和知识库条目决定工作流的下一步
+// 这是一个纯函数，不访问数据库、不写日志，真实执行和离线模拟共用同一套分析逻辑
+// 维护窗口等外部条件不在这里判断，由调用方在下发任务前检查
+func decideNext(status string, result *TaskResult, kbItem *KnowledgeBaseItem) decision {
+	// 过期的任务没有被执行过: 修复任务过期时没有需要撤销的变更，不进入回滚
+	if result.Status == TaskResultExpired {
+		switch status {
+		case StatusDiagnosing:
+			return decision{Status: StatusFailed, Reason: "diagnostic task expired before it was delivered"}
+		case StatusRemediating:
+			return decision{Status: StatusFailed, Reason: "remediation task expired before it was delivered, nothing to roll back"}
+		case StatusRollingBack:
+			return decision{Status: StatusFailed, Reason: "remediation step failed, rollback task expired before it was delivered"}
+		}
+	}
+
+	// 被拒绝的任务同样没有被执行过
+	if result.Status == TaskResultRejected {
+		switch status {
+		case StatusDiagnosing:
+			return decision{Status: StatusFailed, Reason: "diagnostic task rejected by agent: " + result.Error}
+		case StatusRemediating:
+			return decision{Status: StatusFailed, Reason: "remediation task rejected by agent, nothing to roll back: " + result.Error}
+		case StatusRollingBack:
+			return decision{Status: StatusFailed, Reason: "remediation step failed, rollback task rejected by agent: " + result.Error}
+		}
+	}
+
+	switch status {
+	case StatusDiagnosing:
+		// 分析逻辑: 先判断 success，再判断诊断步骤的 "remediate_if" 条件 (如果有)
+		if !result.Success {
+			return decision{Status: StatusFailed, Reason: "diagnostic step " + failureOutcome(result)}
+		}
+		outcome := "diagnostic step succeeded"
+		// 诊断步骤的 "remediate_if" 条件基于结构化结果判断是否需要修复，不满足时工作流直接完成
+		if cond := diagnosticCondition(kbItem); cond != "" {
+			matched, err := evalCondition(cond, result.Data)
+			if err != nil {
+				return decision{Status: StatusFailed, Reason: "cannot evaluate remediate_if condition: " + err.Error()}
+			}
+			if !matched {
+				return decision{Status: StatusCompleted, Reason: "diagnostic condition " + strconv.Quote(cond) + " not met, no remediation needed"}
+			}
+			outcome = "diagnostic condition " + strconv.Quote(cond) + " met"
+		}
+		if !hasStep(kbItem.Remediation) {
+			return decision{Status: StatusCompleted, Reason: outcome + ", no remediation step"}
+		}
+		return decision{
+			Status: StatusRemediating,
+			Reason: outcome,
+			Task:   stepTask("remediation", kbItem.Remediation),
+		}
+	case StatusRemediating:
+		if result.Success {
+			return decision{Status: StatusCompleted, Reason: "remediation step succeeded"}
+		}
+		// 超时的修复任务可能已经做了部分变更，和失败一样需要回滚
+		if !hasStep(kbItem.Rollback) {
+			return decision{Status: StatusFailed, Reason: "remediation step " + failureOutcome(result)}
+		}
+		return decision{
+			Status: StatusRollingBack,
+			Reason: "remediation step " + failureOutcome(result) + ", rolling back",
+			Task:   stepTask("rollback", kbItem.Rollback),
+		}
+	case StatusRollingBack:
+		if !result.Success {
+			return decision{Status: StatusFailed, Reason: "remediation step failed, rollback step " + failureOutcome(result)}
+		}
+		return decision{Status: StatusFailed, Reason: "remediation step failed, rolled back"}
+	default:
+		return decision{Status: StatusFailed, Reason: "received task result in unexpected status " + status}
+	}
+}
+
+// firstStep 决定工作流启动后的第一步
+func firstStep(kbItem *KnowledgeBaseItem) decision {
+	if len(kbItem.Diagnostics) == 0 {
+		return decision{Status: StatusFailed, Reason: "KB item has no diagnostic steps"}
+	}
+	return decision{
+		Status: StatusDiagnosing,
+		Reason: "diagnostic task submitted",
+		// 假设诊断步骤的格式是 {"command": "...", "ttl": "...", "timeout": "..."}，或用 "script" 代替 "command"
+		Task: stepTask("diagnostic", kbItem.Diagnostics[0]),
+	}
+}
+
+// stepTask 根据知识库步骤构造任务，步骤中可选的 "ttl" 指定任务的有效期 (例如 "10m")，
+// 可选的 "timeout" 指定 Agent 执行任务的超时时间 (例如 "5s"、"30m"，不足一秒按一秒计算)
+// ttl 或 timeout 无法解析时忽略，分别使用 task_queue.default_ttl 和 Agent 的默认超时
+// 执行参数 "env.<NAME>"、"workdir"、"stdin"、"interpreter" 和 "run_as" 原样传给 Agent，由 Agent 校验
+// 步骤中指定 "action" 时任务是内置动作任务，参数以 "arg.<name>" 指定
+// 步骤中指定 "kind" 时任务由 Agent 上对应的执行插件执行，插件收到完整的任务 (包括 "command" 和 "arg.<name>")
+// 步骤中指定 "script" 时任务是脚本任务，脚本在提交任务时按名称加载 (见 resolveTaskScript)
+// 指定 "file_push" (文件 ID，配合 "path"、"mode"、"owner"、"group") 或 "file_pull" (Agent 上的路径，可选 "max_size") 时是文件传输任务
+func stepTask(taskType string, step map[string]string) *Task {
+	task := &Task{
+		Type:        taskType,
+		Kind:        TaskKindCommand,
+		Command:     step["command"],
+		WorkDir:     step["workdir"],
+		Stdin:       step["stdin"],
+		Interpreter: step["interpreter"],
+		RunAs:       step["run_as"],
+	}
+	switch {
+	case step["script"] != "":
+		task.Kind = TaskKindScript
+		task.ScriptName = step["script"]
+	case step["file_push"] != "":
+		task.Kind = TaskKindFilePush
+		task.File = &FileTransfer{
+			FileID: step["file_push"],
+			Path:   step["path"],
+			Mode:   step["mode"],
+			Owner:  step["owner"],
+			Group:  step["group"],
+		}
+	case step["file_pull"] != "":
+		task.Kind = TaskKindFilePull
+		task.File = &FileTransfer{Path: step["file_pull"]}
+		if n, err := strconv.ParseInt(step["max_size"], 10, 64); err == nil && n > 0 {
+			task.File.MaxSize = n
+		}
+	case step["action"] != "":
+		task.Kind = TaskKindAction
+		task.Action = step["action"]
+	case step["kind"] != "":
+		task.Kind = step["kind"]
+	}
+	for key, value := range step {
+		if name, ok := strings.CutPrefix(key, "env."); ok && name != "" {
+			if task.Env == nil {
+				task.Env = make(map[string]string)
+			}
+			task.Env[name] = value
+		}
+		if name, ok := strings.CutPrefix(key, "arg."); ok && name != "" {
+			if task.Args == nil {
+				task.Args = make(map[string]string)
+			}
+			task.Args[name] = value
+		}
+	}
+	if d, err := time.ParseDuration(step["ttl"]); err == nil && d > 0 {
+		task.ttl = d
+	}
+	if d, err := time.ParseDuration(step["timeout"]); err == nil && d > 0 {
+		task.Timeout = int((d + time.Second - 1) / time.Second)
+	}
+	return task
+}
+
+// hasStep 判断知识库中的步骤是否存在，步骤需要指定 "command"、"script"、"file_push"、"file_pull"、"action" 或 "kind"
+func hasStep(step map[string]string) bool {
+	return step["command"] != "" || step["script"] != "" || step["file_push"] != "" || step["file_pull"] != "" ||
+		step["action"] != "" || step["kind"] != ""
+}
+
+// diagnosticCondition 返回诊断步骤的 "remediate_if" 条件，没有时返回空字符串
+func diagnosticCondition(kbItem *KnowledgeBaseItem) string {
+	if len(kbItem.Diagnostics) == 0 {
+		return ""
+	}
+	return strings.TrimSpace(kbItem.Diagnostics[0]["remediate_if"])
+}
+
+// DescribeStep 返回知识库步骤的简短描述，用于报告等展示
+func DescribeStep(step map[string]string) string {
+	switch {
+	case step["script"] != "":
+		return "script: " + step["script"]
+	case step["file_push"] != "":
+		return "file_push: " + step["file_push"] + " -> " + step["path"]
+	case step["file_pull"] != "":
+		return "file_pull: " + step["file_pull"]
+	case step["action"] != "":
+		return "action: " + step["action"]
+	case step["kind"] != "":
+		return step["kind"] + ": " + step["command"]
+	}
+	return step["command"]
+}
+
+// failureOutcome 描述任务失败的方式，用于状态流转的原因
+func failureOutcome(result *TaskResult) string {
+	if result.Status == TaskResultTimedOut {
+		return "timed out"
+	}
+	return "failed"
+}
@@ -0,0 +1,166 @@
+package engine
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/config"
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/logger"
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/model"
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/store"
+)
+
+// blockRemediationIfNeeded 在下发修复任务前检查维护窗口
+// 如果修复被阻止，会按处理方式把工作流流转到 deferred / on_hold / failed，并返回 true
+func blockRemediationIfNeeded(workflow *model.Workflow) bool {
+	block, err := checkRemediationAllowed(workflow)
+	if err != nil {
+		// 读不到维护窗口时无法判断现在是否在窗口内，按 hold 处理，由运维人员确认后放行
+		logger.L.Errorw("Failed to evaluate maintenance windows", "workflow_id", workflow.ID, "error", err)
+		block = &remediationBlock{Action: BlockActionHold, Reason: "failed to evaluate maintenance windows: " + err.Error()}
+	}
+	if block == nil {
+		return false
+	}
+
+	applyRemediationBlock(workflow, block)
+	return true
+}
+
+// applyRemediationBlock 根据阻止的处理方式流转工作流
+func applyRemediationBlock(workflow *model.Workflow, block *remediationBlock) {
+	logger.L.Warnw("Remediation blocked", "workflow_id", workflow.ID, "action", block.Action, "reason", block.Reason)
+
+	switch block.Action {
+	case BlockActionReject:
+		transitionWorkflow(workflow, StatusFailed, "remediation rejected: "+block.Reason, nil)
+	case BlockActionHold:
+		transitionWorkflow(workflow, StatusOnHold, "remediation on hold: "+block.Reason, map[string]interface{}{
+			"resume_status":  StatusRemediating,
+			"deferred_until": nil,
+		})
+	default:
+		reason := fmt.Sprintf("remediation deferred until %s: %s", block.ResumeAt.Format(time.RFC3339), block.Reason)
+		transitionWorkflow(workflow, StatusDeferred, reason, map[string]interface{}{
+			"resume_status":  StatusRemediating,
+			"deferred_until": block.ResumeAt,
+		})
+	}
+}
+
+// ResumeWorkflow 恢复一个被推迟或挂起的工作流，继续执行被阻止的步骤
+// override 为 true 表示由运维人员手动放行，此时不再检查维护窗口和修复限制
+func ResumeWorkflow(workflow *model.Workflow, reason string, override bool) error {
+	if workflow.Status != StatusDeferred && workflow.Status != StatusOnHold {
+		return fmt.Errorf("%w: workflow is %s, only deferred or on_hold workflows can be resumed", ErrInvalidTransition, workflow.Status)
+	}
+
+	kbItem, err := getKBItemFromES(workflow.KBID)
+	if err != nil {
+		logger.L.Errorw("Cannot find KB item to resume workflow", "workflow_id", workflow.ID, "kb_id", workflow.KBID, "error", err)
+		return TransitionWorkflow(workflow, StatusFailed, "KB item not found: "+err.Error(), nil)
+	}
+
+	switch workflow.ResumeStatus {
+	case StatusRemediating:
+		if override {
+			if !startRemediation(workflow, kbItem, reason) {
+				return ErrWorkflowConflict
+			}
+			return nil
+		}
+		if blockRemediationIfNeeded(workflow) {
+			return nil
+		}
+		return startGuardedRemediation(workflow, kbItem, reason)
+	case StatusDiagnosing:
+		// 诊断任务提交时队列已满，或排队时 Agent 进入了维护模式
+		if !override && deferIfAgentInMaintenance(workflow, StatusDiagnosing) {
+			return nil
+		}
+		first := firstStep(kbItem)
+		if first.Task == nil {
+			return TransitionWorkflow(workflow, first.Status, first.Reason, nil)
+		}
+		return resumeWithTask(workflow, decision{Status: StatusDiagnosing, Reason: reason, Task: first.Task})
+	case StatusRollingBack:
+		// 回滚任务提交时队列已满，或排队时 Agent 进入了维护模式
+		if !override && deferIfAgentInMaintenance(workflow, StatusRollingBack) {
+			return nil
+		}
+		if !hasStep(kbItem.Rollback) {
+			return TransitionWorkflow(workflow, StatusFailed, "remediation step failed, rollback step no longer exists", nil)
+		}
+		return resumeWithTask(workflow, decision{
+			Status: StatusRollingBack,
+			Reason: reason,
+			Task:   stepTask("rollback", kbItem.Rollback),
+		})
+	default:
+		return fmt.Errorf("%w: unknown resume status %q", ErrInvalidTransition, workflow.ResumeStatus)
+	}
+}
+
+// resumeWithTask 恢复工作流并重新下发任务
+func resumeWithTask(workflow *model.Workflow, next decision) error {
+	if !submitFollowUpTask(workflow, next) {
+		return ErrWorkflowConflict
+	}
+	return nil
+}
+
+// deferForBackpressure 在 Agent 任务队列已满时推迟工作流，到期后由调度器重新提交当前步骤的任务
+func deferForBackpressure(workflow *model.Workflow, cause error) {
+	retryAt := time.Now().Add(queueRetryInterval())
+	reason := fmt.Sprintf("%s deferred until %s: %v", workflow.Status, retryAt.Format(time.RFC3339), cause)
+	logger.L.Warnw("Workflow deferred by task queue backpressure", "workflow_id", workflow.ID, "agent_id", workflow.AgentID, "status", workflow.Status, "retry_at", retryAt)
+	transitionWorkflow(workflow, StatusDeferred, reason, map[string]interface{}{
+		"resume_status":  workflow.Status,
+		"deferred_until": retryAt,
+	})
+}
+
+// queueRetryInterval 返回配置的队列重试间隔
+func queueRetryInterval() time.Duration {
+	if config.C.TaskQueue.RetryInterval != "" {
+		if d, err := time.ParseDuration(config.C.TaskQueue.RetryInterval); err == nil && d > 0 {
+			return d
+		}
+	}
+	return time.Minute
+}
+
+// ResumeDueWorkflows 恢复所有推迟时间已到期的工作流，由调度器周期性调用
+func ResumeDueWorkflows() {
+	var workflows []model.Workflow
+	err := store.DB.Where("status = ? AND deferred_until <= ?", StatusDeferred, time.Now()).Find(&workflows).Error
+	if err != nil {
+		logger.L.Errorw("Failed to query deferred workflows", "error", err)
+		return
+	}
+
+	for i := range workflows {
+		workflow := &workflows[i]
+		logger.L.Infow("Resuming deferred workflow", "workflow_id", workflow.ID, "resume_status", workflow.ResumeStatus)
+		if err := ResumeWorkflow(workflow, "deferral expired", false); err != nil {
+			logger.L.Errorw("Failed to resume deferred workflow", "workflow_id", workflow.ID, "error", err)
+		}
+	}
+}
+
+// AbortWorkflow 由运维人员手动终止一个尚未结束的工作流
+func AbortWorkflow(workflow *model.Workflow, reason string) error {
+	if IsTerminalStatus(workflow.Status) {
+		return fmt.Errorf("%w: workflow is already %s", ErrInvalidTransition, workflow.Status)
+	}
+	if err := TransitionWorkflow(workflow, StatusFailed, "aborted by operator: "+reason, map[string]interface{}{
+		"deferred_until": nil,
+	}); err != nil {
+		return err
+	}
+	// 终止当前任务，避免已经没有意义的修复继续在 Agent 上执行
+	if workflow.CurrentTaskID != "" {
+		cancelTask(workflow.AgentID, workflow.CurrentTaskID, "workflow aborted by operator: "+reason)
+	}
+	return nil
+}
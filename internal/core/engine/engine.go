@@ -10,63 +10,86 @@ import (
 	"time"
 )
 
+// WorkflowOptions 是启动工作流时的可选参数
+type WorkflowOptions struct {
+	Priority Priority
+	// Preempt 为 true 时，会取消同一 Agent 上所有排队中、优先级更低的任务
+	Preempt bool
+	// NotBefore 不为空时，第一个任务在该时间之前不会分发给 Agent
+	NotBefore *time.Time
+	// TTL 大于 0 时覆盖第一个任务的有效期 (从 NotBefore 或提交时开始计算)
+	TTL time.Duration
+}
+
 // StartKBWorkflow 是启动知识库工作流的入口
-func StartKBWorkflow(agentID, kbID string) (string, error) {
-	logger.L.Infow("Starting KB workflow", "agent_id", agentID, "kb_id", kbID)
+func StartKBWorkflow(agentID, kbID string, opts WorkflowOptions) (string, error) {
+	logger.L.Infow("Starting KB workflow", "agent_id", agentID, "kb_id", kbID, "priority", opts.Priority, "preempt", opts.Preempt)
+
+	// 处于维护模式的 Agent 不启动任何自动化
+	if err := checkAgentMaintenance(agentID); err != nil {
+		logger.L.Warnw("Skipping KB workflow", "agent_id", agentID, "kb_id", kbID, "reason", err)
+		return "", err
+	}
 
 	// 1. 创建并存储工作流状态到数据库 (PostgreSQL)
 	workflow := &model.Workflow{
 		ID:        uuid.NewString(), // 生成工作流唯一ID
 		KBID:      kbID,
 		AgentID:   agentID,
-		Status:    "pending",
+		Priority:  int(opts.Priority.clamp()),
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 	}
-	if err := store.DB.Create(workflow).Error; err != nil {
+	if err := createWorkflow(workflow); err != nil {
 		logger.L.Errorw("Failed to create workflow record", "error", err)
 		return "", err
 	}
+	attachWorkflowToIncident(workflow)
+
 	// 2. 从 Elasticsearch 中获取知识库条目
 	// 假设你有一个函数来获取KB条目
 	kbItem, err := getKBItemFromES(kbID)
 	if err != nil {
 		logger.L.Errorw("Failed to get KB item from Elasticsearch", "kb_id", kbID, "error", err)
+		transitionWorkflow(workflow, StatusFailed, "KB item not found: "+err.Error(), nil)
 		return "", err
 	}
-	if len(kbItem.Diagnostics) == 0 {
-		return "", errors.New("KB item has no diagnostic steps")
+	first := firstStep(kbItem)
+	if first.Task == nil {
+		transitionWorkflow(workflow, first.Status, first.Reason, nil)
+		return "", errors.New(first.Reason)
 	}
 
 	// 3. 提交第一个诊断任务
-	firstDiagnosticStep := kbItem.Diagnostics[0]
-	task := &Task{
-		ID:         uuid.NewString(),
-		AgentID:    agentID,
-		WorkflowID: workflow.ID,
-		Type:       "diagnostic",
-		Command:    firstDiagnosticStep["command"], // 假设诊断步骤的格式是 {"command": "..."}
-		CreatedAt:  time.Now(),
+	if opts.TTL > 0 {
+		first.Task.ttl = opts.TTL
 	}
+	task := newTask(workflow, first.Task, opts.NotBefore)
 
 	// 4. 更新工作流状态为 "diagnosing"
-	updateData := map[string]interface{}{
-		"status":          "diagnosing",
-		"current_task_id": task.ID,
-	}
-	if err := store.DB.Model(&model.Workflow{}).Where("id = ?", workflow.ID).Updates(updateData).Error; err != nil {
+	if err := TransitionWorkflow(workflow, first.Status, first.Reason, map[string]interface{}{"current_task_id": task.ID}); err != nil {
 		logger.L.Errorw("Failed to update workflow status to diagnosing", "error", err)
 		return "", err
 	}
 
-	TM.SubmitTask(task)
+	if opts.Preempt {
+		preemptLowerPriority(workflow)
+	}
+	// 队列已满时工作流已被推迟，稍后自动重新提交，不视为启动失败
+	if err := submitTask(workflow, task); err != nil && !errors.Is(err, ErrQueueFull) {
+		return workflow.ID, err
+	}
 
 	return workflow.ID, nil
 }
 
 // HandleTaskResult 是处理 Agent 返回结果的入口
 func HandleTaskResult(result *TaskResult) {
-	logger.L.Infow("Handling task result", "task_id", result.TaskID, "success", result.Success)
+	logger.L.Infow("Handling task result", "task_id", result.TaskID, "success", result.Success, "status", result.Status)
+	// 任务已经结束 (取消、过期、重复上报) 或不属于这个 Agent 时，结果也不能推动工作流
+	if !recordTaskResult(result) {
+		return
+	}
 
 	// 1. 根据 result.TaskID 找到对应的工作流 (workflow)
 	// 我们假设一个 Agent 的一个任务只属于一个工作流
@@ -76,6 +99,11 @@ func HandleTaskResult(result *TaskResult) {
 		logger.L.Errorw("Cannot find workflow for this task result", "task_id", result.TaskID, "agent_id", result.AgentID, "error", dbResult.Error)
 		return
 	}
+	// 已经结束的工作流不再处理迟到或重复的结果
+	if IsTerminalStatus(workflow.Status) {
+		logger.L.Warnw("Ignoring task result for a finished workflow", "workflow_id", workflow.ID, "status", workflow.Status, "task_id", result.TaskID)
+		return
+	}
 
 	// 2. 从ES中再次获取KB条目
 
@@ -83,21 +111,28 @@ func HandleTaskResult(result *TaskResult) {
 	if err != nil {
 		logger.L.Errorw("Cannot find KB item for task result", "kb_id", workflow.KBID, "task_id", result.TaskID, "error", err)
 		// 更新工作流状态为 "failed"
-		updateWorkflowStatus(workflow.ID, "failed")
+		transitionWorkflow(&workflow, StatusFailed, "KB item not found: "+err.Error(), nil)
 		return
 	}
 
 	// 3. 执行分析逻辑 (analysis_logic)
 	switch workflow.Status {
-	case "diagnosing":
+	case StatusDiagnosing:
 		handleDiagnosingResult(result, &workflow, kbItem)
-	case "remediating":
+	case StatusRemediating:
 		handleRemediatingResult(result, &workflow, kbItem)
+	case StatusRollingBack:
+		handleRollingBackResult(result, &workflow, kbItem)
 	default:
 		logger.L.Warnw("Received task result for a workflow in an unexpected state", "workflow_id", workflow.ID, "status", workflow.Status)
 	}
 }
 
+// GetKBItem 从知识库中获取条目，供报告等引擎外部的模块使用
+func GetKBItem(kbID string) (*KnowledgeBaseItem, error) {
+	return getKBItemFromES(kbID)
+}
+
 // getKBItemFromES 是一个示例函数，用于从ES获取KB条目
 func getKBItemFromES(kbID string) (*KnowledgeBaseItem, error) {
 	// 实际项目中，索引名应该来自配置
@@ -123,61 +158,129 @@ func getKBItemFromES(kbID string) (*KnowledgeBaseItem, error) {
 
 // handleDiagnosingResult 处理诊断任务的结果
 func handleDiagnosingResult(result *TaskResult, workflow *model.Workflow, kbItem *KnowledgeBaseItem) {
-	// 分析逻辑 (MVP: 仅判断 success)
-	if result.Success {
+	next := decideNext(StatusDiagnosing, result, kbItem)
+	switch next.Status {
+	case StatusRemediating:
 		logger.L.Info("Diagnostic step succeeded. Proceeding to remediation.")
-
-		if kbItem.Remediation == nil || kbItem.Remediation["command"] == "" {
-			logger.L.Info("No remediation step. Workflow completed.", "workflow_id", workflow.ID)
-			updateWorkflowStatus(workflow.ID, "completed")
+		// 检查维护窗口，被阻止时按窗口配置推迟、挂起或直接失败
+		if blockRemediationIfNeeded(workflow) {
 			return
 		}
-
-		// 构造并提交修复任务
-		remediationTask := &Task{
-			ID:         uuid.NewString(),
-			AgentID:    workflow.AgentID,
-			WorkflowID: workflow.ID,
-			Type:       "remediation",
-			Command:    kbItem.Remediation["command"],
-			CreatedAt:  time.Now(),
-		}
-		// 更新工作流状态为 "remediating"
-		updateData := map[string]interface{}{
-			"status":          "remediating",
-			"current_task_id": remediationTask.ID,
-		}
-		if err := store.DB.Model(workflow).Updates(updateData).Error; err != nil {
-			logger.L.Errorw("Failed to update workflow to remediating", "error", err)
-			return
-		}
-
-		TM.SubmitTask(remediationTask)
-
-	} else {
-		logger.L.Errorw("Diagnostic step failed", "workflow_id", workflow.ID, "output", result.Output)
-		// 更新工作流状态为 "failed"
-		updateWorkflowStatus(workflow.ID, "failed")
+		// 检查集群和知识库条目的修复限制，超出时挂起并通知运维人员
+		startGuardedRemediation(workflow, kbItem, next.Reason)
+	case StatusCompleted:
+		logger.L.Infow("No remediation step. Workflow completed.", "workflow_id", workflow.ID)
+		transitionWorkflow(workflow, StatusCompleted, next.Reason, nil)
+	default:
+		logger.L.Errorw("Diagnostic step failed", "workflow_id", workflow.ID, "output", result.CombinedOutput())
+		transitionWorkflow(workflow, next.Status, next.Reason, nil)
 	}
 }
 
+// startRemediation 构造修复任务，将工作流流转到 "remediating" 后下发任务
+func startRemediation(workflow *model.Workflow, kbItem *KnowledgeBaseItem, reason string) bool {
+	return submitFollowUpTask(workflow, decision{
+		Status: StatusRemediating,
+		Reason: reason,
+		Task:   stepTask("remediation", kbItem.Remediation),
+	})
+}
+
 // handleRemediatingResult 处理修复任务的结果
 func handleRemediatingResult(result *TaskResult, workflow *model.Workflow, kbItem *KnowledgeBaseItem) {
+	next := decideNext(StatusRemediating, result, kbItem)
+	switch next.Status {
+	case StatusCompleted:
+		logger.L.Infow("Remediation step succeeded. Workflow completed.", "workflow_id", workflow.ID)
+		transitionWorkflow(workflow, next.Status, next.Reason, nil)
+	case StatusRollingBack:
+		logger.L.Warnw("Remediation step failed. Rolling back.", "workflow_id", workflow.ID, "output", result.CombinedOutput())
+		submitFollowUpTask(workflow, next)
+	default:
+		logger.L.Errorw("Remediation step failed", "workflow_id", workflow.ID, "output", result.CombinedOutput())
+		transitionWorkflow(workflow, next.Status, next.Reason, nil)
+	}
+}
+
+// handleRollingBackResult 处理回滚任务的结果，工作流都会以失败结束
+func handleRollingBackResult(result *TaskResult, workflow *model.Workflow, kbItem *KnowledgeBaseItem) {
+	next := decideNext(StatusRollingBack, result, kbItem)
 	if result.Success {
-		logger.L.Info("Remediation step succeeded. Workflow completed.", "workflow_id", workflow.ID)
-		// 更新工作流状态为 "completed"
-		updateWorkflowStatus(workflow.ID, "completed")
+		logger.L.Infow("Rollback step succeeded", "workflow_id", workflow.ID)
 	} else {
-		// 更新工作流状态为 "failed"
-		logger.L.Errorw("Remediation step failed", "workflow_id", workflow.ID, "output", result.Output)
-		updateWorkflowStatus(workflow.ID, "failed")
+		logger.L.Errorw("Rollback step failed", "workflow_id", workflow.ID, "output", result.CombinedOutput())
+	}
+	transitionWorkflow(workflow, next.Status, next.Reason, nil)
+}
+
+// submitFollowUpTask 按照 decision 流转工作流，并下发 decision 中的任务
+// 只有流转成功才下发任务，避免并发的结果处理重复下发任务
+// 返回 false 表示流转失败；任务因队列已满未能提交时工作流会被推迟，仍返回 true
+func submitFollowUpTask(workflow *model.Workflow, next decision) bool {
+	task := newTask(workflow, next.Task, nil)
+	extra := map[string]interface{}{
+		"current_task_id": task.ID,
+		"resume_status":   "",
+		"deferred_until":  nil,
+	}
+	if !transitionWorkflow(workflow, next.Status, next.Reason, extra) {
+		return false
+	}
+
+	if err := submitTask(workflow, task); err != nil {
+		logger.L.Errorw("Failed to submit task", "workflow_id", workflow.ID, "task_id", task.ID, "error", err)
+	}
+	return true
+}
+
+// newTask 为工作流构造一个待下发的任务，step 只需要填写 Type 和 Command
+// 任务的有效期取自知识库步骤的 "ttl"，未配置时使用 task_queue.default_ttl，从 notBefore (为空时为当前时间) 开始计算
+func newTask(workflow *model.Workflow, step *Task, notBefore *time.Time) *Task {
+	now := time.Now()
+	task := &Task{
+		ID:         uuid.NewString(),
+		AgentID:    workflow.AgentID,
+		WorkflowID: workflow.ID,
+		Type:       step.Type,
+		Kind:       step.Kind,
+		Command:    step.Command,
+		Priority:   Priority(workflow.Priority),
+		CreatedAt:  now,
+		NotBefore:  notBefore,
+		Timeout:    step.Timeout,
+
+		Env:         step.Env,
+		WorkDir:     step.WorkDir,
+		Stdin:       step.Stdin,
+		Interpreter: step.Interpreter,
+		RunAs:       step.RunAs,
+		ScriptName:  step.ScriptName,
+		File:        step.File,
+		Action:      step.Action,
+		Args:        step.Args,
+	}
+
+	ttl := step.ttl
+	if ttl <= 0 {
+		ttl = defaultTaskTTL()
+	}
+	if ttl > 0 {
+		start := now
+		if notBefore != nil && notBefore.After(now) {
+			start = *notBefore
+		}
+		expiresAt := start.Add(ttl)
+		task.ExpiresAt = &expiresAt
 	}
+	return task
 }
 
-// updateWorkflowStatus 是一个辅助函数，用于更新工作流状态
-func updateWorkflowStatus(workflowID, status string) {
-	updateData := map[string]interface{}{"status": status}
-	if err := store.DB.Model(&model.Workflow{}).Where("id = ?", workflowID).Updates(updateData).Error; err != nil {
-		logger.L.Errorw("Failed to update workflow status", "workflow_id", workflowID, "status", status, "error", err)
+// transitionWorkflow 是 TransitionWorkflow 的辅助封装，负责记录失败日志
+// 返回值表示流转是否成功，调用方据此决定是否继续后续动作 (如下发任务)
+func transitionWorkflow(workflow *model.Workflow, status, reason string, extra map[string]interface{}) bool {
+	if err := TransitionWorkflow(workflow, status, reason, extra); err != nil {
+		logger.L.Errorw("Failed to update workflow status", "workflow_id", workflow.ID, "from", workflow.Status, "status", status, "error", err)
+		return false
 	}
+	return true
 }
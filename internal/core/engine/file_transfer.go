@@ -0,0 +1,290 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/blob"
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/config"
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/logger"
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/model"
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/store"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultMaxFileSize = 100 * 1024 * 1024
+	// MaxFileChunkSize 是文件传输中单个分块的最大字节数
+	MaxFileChunkSize = 4 * 1024 * 1024
+)
+
+// 文件来源，见 model.File.Source
+const (
+	FileSourceUpload = "upload"
+	FileSourceAgent  = "agent"
+)
+
+var (
+	ErrFileNotFound     = errors.New("file not found")
+	ErrFileTooLarge     = errors.New("file exceeds the size limit")
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+	ErrInvalidChunk     = errors.New("invalid file chunk")
+	// ErrInvalidFileTask 表示请求的任务不是该 Agent 正在执行的文件传输任务
+	ErrInvalidFileTask = errors.New("not an active file transfer task of this agent")
+)
+
+// MaxFileSize 返回上传或从 Agent 收集的单个文件的最大字节数
+func MaxFileSize() int64 {
+	if config.C.Blob.MaxFileSize > 0 {
+		return config.C.Blob.MaxFileSize
+	}
+	return defaultMaxFileSize
+}
+
+func fileBlobKey(fileID string) string {
+	return "files/" + fileID
+}
+
+func uploadChunkKey(taskID string, offset int64) string {
+	return fmt.Sprintf("uploads/%s/%020d", taskID, offset)
+}
+
+// SaveFile 把 r 中的内容保存为一个新文件，计算大小和 SHA-256，超过 MaxFileSize 时返回 ErrFileTooLarge
+// meta 中的 Name、Source、AgentID、TaskID 和 Path 原样保存
+func SaveFile(ctx context.Context, meta model.File, r io.Reader) (*model.File, error) {
+	meta.ID = uuid.NewString()
+	key := fileBlobKey(meta.ID)
+
+	hasher := sha256.New()
+	counter := &countingReader{r: io.LimitReader(r, MaxFileSize()+1)}
+	if err := blob.S.Put(ctx, key, io.TeeReader(counter, hasher)); err != nil {
+		return nil, err
+	}
+	if counter.n > MaxFileSize() {
+		deleteBlob(key)
+		return nil, ErrFileTooLarge
+	}
+
+	meta.Size = counter.n
+	meta.SHA256 = hex.EncodeToString(hasher.Sum(nil))
+	meta.CreatedAt = time.Now()
+	if err := store.DB.Create(&meta).Error; err != nil {
+		deleteBlob(key)
+		return nil, err
+	}
+	return &meta, nil
+}
+
+// OpenFile 读取文件的全部内容
+func OpenFile(ctx context.Context, file *model.File) (io.ReadCloser, error) {
+	return blob.S.GetRange(ctx, fileBlobKey(file.ID), 0, -1)
+}
+
+// DeleteFile 删除文件及其内容
+func DeleteFile(ctx context.Context, file *model.File) error {
+	if err := blob.S.Delete(ctx, fileBlobKey(file.ID)); err != nil {
+		return err
+	}
+	return store.DB.Delete(file).Error
+}
+
+// resolveTaskFile 在提交任务时补全文件传输任务的参数: file_push 填写文件的大小和 SHA-256，file_pull 限制最大字节数
+func resolveTaskFile(task *Task) error {
+	switch task.Kind {
+	case TaskKindFilePush:
+		var file model.File
+		if err := store.DB.Where("id = ?", task.File.FileID).First(&file).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("%w: %s", ErrFileNotFound, task.File.FileID)
+			}
+			return err
+		}
+		task.File.SHA256 = file.SHA256
+		task.File.Size = file.Size
+	case TaskKindFilePull:
+		if task.File.MaxSize <= 0 || task.File.MaxSize > MaxFileSize() {
+			task.File.MaxSize = MaxFileSize()
+		}
+	}
+	return nil
+}
+
+// ReadFileChunk 返回 file_push 任务要下发的文件中从 offset 开始、最多 length 字节的分块，以及分块的 SHA-256
+func ReadFileChunk(ctx context.Context, agentID, taskID string, offset, length int64) ([]byte, string, error) {
+	record, err := activeFileTask(agentID, taskID, TaskKindFilePush)
+	if err != nil {
+		return nil, "", err
+	}
+	if offset < 0 || length <= 0 || length > MaxFileChunkSize {
+		return nil, "", fmt.Errorf("%w: offset=%d length=%d", ErrInvalidChunk, offset, length)
+	}
+
+	r, err := blob.S.GetRange(ctx, fileBlobKey(record.FileID), offset, length)
+	if err != nil {
+		if errors.Is(err, blob.ErrNotFound) {
+			return nil, "", fmt.Errorf("%w: %s", ErrFileNotFound, record.FileID)
+		}
+		return nil, "", err
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, "", err
+	}
+	sum := sha256.Sum256(data)
+	return data, hex.EncodeToString(sum[:]), nil
+}
+
+// ReceiveFileChunk 保存 file_pull 任务上传的一个分块，分块的 SHA-256 必须与 checksum 一致
+// 同一偏移量的分块重复上传时覆盖，Agent 可以安全地重试
+func ReceiveFileChunk(ctx context.Context, agentID, taskID string, offset int64, data []byte, checksum string) error {
+	if _, err := activeFileTask(agentID, taskID, TaskKindFilePull); err != nil {
+		return err
+	}
+	if offset < 0 || len(data) == 0 || len(data) > MaxFileChunkSize {
+		return fmt.Errorf("%w: offset=%d length=%d", ErrInvalidChunk, offset, len(data))
+	}
+	if offset+int64(len(data)) > MaxFileSize() {
+		return ErrFileTooLarge
+	}
+	sum := sha256.Sum256(data)
+	if hex.EncodeToString(sum[:]) != checksum {
+		return fmt.Errorf("%w: chunk at offset %d", ErrChecksumMismatch, offset)
+	}
+	return blob.S.Put(ctx, uploadChunkKey(taskID, offset), bytes.NewReader(data))
+}
+
+// CompleteFileUpload 按偏移量顺序拼接 file_pull 任务上传的分块，校验总大小和 SHA-256 后保存为文件
+// 重复调用 (例如 Agent 没有收到上一次的响应) 时返回已经保存的文件
+func CompleteFileUpload(ctx context.Context, agentID, taskID, name, path string, size int64, checksum string) (*model.File, error) {
+	record, err := activeFileTask(agentID, taskID, TaskKindFilePull)
+	if err != nil {
+		return nil, err
+	}
+	if record.FileID != "" {
+		var file model.File
+		if err := store.DB.Where("id = ?", record.FileID).First(&file).Error; err == nil {
+			return &file, nil
+		}
+	}
+	if size < 0 || size > MaxFileSize() {
+		return nil, ErrFileTooLarge
+	}
+
+	chunks := &chunkReader{ctx: ctx, taskID: taskID, size: size}
+	defer chunks.cleanup()
+	file, err := SaveFile(ctx, model.File{
+		Name:    name,
+		Source:  FileSourceAgent,
+		AgentID: agentID,
+		TaskID:  taskID,
+		Path:    path,
+	}, chunks)
+	if err != nil {
+		return nil, err
+	}
+	if file.Size != size || file.SHA256 != checksum {
+		logger.L.Warnw("Uploaded file does not match the declared checksum", "task_id", taskID, "size", file.Size, "declared_size", size)
+		if err := DeleteFile(ctx, file); err != nil {
+			logger.L.Errorw("Failed to delete mismatched file", "file_id", file.ID, "error", err)
+		}
+		return nil, fmt.Errorf("%w: file", ErrChecksumMismatch)
+	}
+
+	if err := store.DB.Model(&model.WorkflowTask{}).Where("id = ?", taskID).Update("file_id", file.ID).Error; err != nil {
+		logger.L.Errorw("Failed to record pulled file", "task_id", taskID, "file_id", file.ID, "error", err)
+	}
+	return file, nil
+}
+
+// activeFileTask 检查任务是该 Agent 已领取、尚未结束的指定类型的文件传输任务
+func activeFileTask(agentID, taskID, kind string) (*model.WorkflowTask, error) {
+	var record model.WorkflowTask
+	if err := store.DB.Where("id = ?", taskID).First(&record).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidFileTask
+		}
+		return nil, err
+	}
+	if record.AgentID != agentID || record.Kind != kind || record.Status != TaskStatusDispatched {
+		return nil, ErrInvalidFileTask
+	}
+	return &record, nil
+}
+
+func deleteBlob(key string) {
+	if err := blob.S.Delete(context.Background(), key); err != nil {
+		logger.L.Errorw("Failed to delete blob", "key", key, "error", err)
+	}
+}
+
+// chunkReader 按偏移量顺序读取上传的分块，读到 size 字节为止，缺少分块时返回错误
+type chunkReader struct {
+	ctx    context.Context
+	taskID string
+	size   int64
+
+	offset  int64
+	current io.ReadCloser
+	keys    []string
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	for {
+		if r.current == nil {
+			if r.offset >= r.size {
+				return 0, io.EOF
+			}
+			key := uploadChunkKey(r.taskID, r.offset)
+			rc, err := blob.S.GetRange(r.ctx, key, 0, -1)
+			if err != nil {
+				if errors.Is(err, blob.ErrNotFound) {
+					return 0, fmt.Errorf("%w: missing chunk at offset %d", ErrInvalidChunk, r.offset)
+				}
+				return 0, err
+			}
+			r.current = rc
+			r.keys = append(r.keys, key)
+		}
+
+		n, err := r.current.Read(p)
+		r.offset += int64(n)
+		if err == io.EOF {
+			r.current.Close()
+			r.current = nil
+			if n > 0 {
+				return n, nil
+			}
+			continue
+		}
+		return n, err
+	}
+}
+
+// cleanup 删除已经读取过的分块
+func (r *chunkReader) cleanup() {
+	if r.current != nil {
+		r.current.Close()
+	}
+	for _, key := range r.keys {
+		deleteBlob(key)
+	}
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,192 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/config"
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/logger"
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/model"
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/store"
+	"gorm.io/gorm"
+)
+
+// guardrailMu 串行化护栏检查和修复的开始，避免并发的结果处理同时通过检查而超出限制
+// 多副本部署时还会同时持有跨副本的锁，见 withClusterLock
+var guardrailMu sync.Mutex
+
+// guardrailLimits 是一组修复限制，字段为 0 表示不限制
+type guardrailLimits struct {
+	Scope           string // "fleet" 或 "KB <kb_id>"，用于拼接原因
+	KBID            string // 为空表示全局限制
+	MaxConcurrent   int
+	MaxPerHour      int
+	MaxGroupPercent int
+}
+
+// ValidateKBGuardrail 校验知识库条目的护栏配置
+func ValidateKBGuardrail(guardrail *model.KBGuardrail) error {
+	if guardrail.MaxConcurrent < 0 || guardrail.MaxPerHour < 0 || guardrail.MaxGroupPercent < 0 {
+		return errors.New("limits must not be negative")
+	}
+	if guardrail.MaxGroupPercent > 100 {
+		return errors.New("max_group_percent must be between 0 and 100")
+	}
+	return nil
+}
+
+// startGuardedRemediation 在护栏允许的情况下开始修复，超出限制时挂起工作流并通知运维人员
+// 返回 ErrWorkflowConflict 表示工作流已被其他协程修改
+func startGuardedRemediation(workflow *model.Workflow, kbItem *KnowledgeBaseItem, reason string) error {
+	var result error
+	err := withClusterLock(&guardrailMu, "guardrails", func() {
+		result = startGuardedRemediationLocked(workflow, kbItem, reason)
+	})
+	if err != nil {
+		// 与无法评估限制时一样，拿不到锁就挂起等待人工确认
+		logger.L.Errorw("Failed to acquire guardrail lock", "workflow_id", workflow.ID, "error", err)
+		holdForGuardrail(workflow, "failed to acquire guardrail lock: "+err.Error())
+		return nil
+	}
+	return result
+}
+
+func startGuardedRemediationLocked(workflow *model.Workflow, kbItem *KnowledgeBaseItem, reason string) error {
+	violation, err := checkGuardrails(workflow)
+	if err != nil {
+		// 统计失败时不知道是否已经超出限制，当作触发护栏处理，同样会产生告警
+		logger.L.Errorw("Failed to evaluate guardrails", "workflow_id", workflow.ID, "error", err)
+		violation = "failed to evaluate guardrails: " + err.Error()
+	}
+	if violation != "" {
+		holdForGuardrail(workflow, violation)
+		return nil
+	}
+
+	if !startRemediation(workflow, kbItem, reason) {
+		return ErrWorkflowConflict
+	}
+	return nil
+}
+
+// checkGuardrails 依次检查全局限制和知识库条目的限制，返回第一个被触发的限制的描述
+func checkGuardrails(workflow *model.Workflow) (string, error) {
+	global := config.C.Guardrails
+	limits := []guardrailLimits{{
+		Scope:           "fleet",
+		MaxConcurrent:   global.MaxConcurrentRemediations,
+		MaxPerHour:      global.MaxRemediationsPerHour,
+		MaxGroupPercent: global.MaxGroupPercent,
+	}}
+
+	var kbGuardrail model.KBGuardrail
+	err := store.DB.Where("kb_id = ?", workflow.KBID).First(&kbGuardrail).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", err
+	}
+	if err == nil {
+		limits = append(limits, guardrailLimits{
+			Scope:           "KB " + workflow.KBID,
+			KBID:            workflow.KBID,
+			MaxConcurrent:   kbGuardrail.MaxConcurrent,
+			MaxPerHour:      kbGuardrail.MaxPerHour,
+			MaxGroupPercent: kbGuardrail.MaxGroupPercent,
+		})
+	}
+
+	for _, l := range limits {
+		violation, err := checkGuardrailLimits(workflow, l)
+		if err != nil || violation != "" {
+			return violation, err
+		}
+	}
+	return "", nil
+}
+
+func checkGuardrailLimits(workflow *model.Workflow, limits guardrailLimits) (string, error) {
+	if limits.MaxConcurrent > 0 {
+		var running int64
+		if err := remediatingWorkflows(limits.KBID).Count(&running).Error; err != nil {
+			return "", err
+		}
+		if running >= int64(limits.MaxConcurrent) {
+			return fmt.Sprintf("%s limit of %d concurrent remediations reached", limits.Scope, limits.MaxConcurrent), nil
+		}
+	}
+
+	if limits.MaxPerHour > 0 {
+		query := store.DB.Table("workflow_transitions").
+			Joins("JOIN workflows ON workflows.id = workflow_transitions.workflow_id").
+			Where("workflow_transitions.to_status = ? AND workflow_transitions.created_at >= ?", StatusRemediating, time.Now().Add(-time.Hour))
+		if limits.KBID != "" {
+			query = query.Where("workflows.kb_id = ?", limits.KBID)
+		}
+		var started int64
+		if err := query.Count(&started).Error; err != nil {
+			return "", err
+		}
+		if started >= int64(limits.MaxPerHour) {
+			return fmt.Sprintf("%s limit of %d remediations per hour reached", limits.Scope, limits.MaxPerHour), nil
+		}
+	}
+
+	if limits.MaxGroupPercent > 0 {
+		group := agentGroup(workflow.AgentID)
+		var groupSize int64
+		if err := store.DB.Model(&model.Agent{}).Where("agent_group = ?", group).Count(&groupSize).Error; err != nil {
+			return "", err
+		}
+		var busy int64
+		err := remediatingWorkflows(limits.KBID).
+			Where("agent_id IN (?)", store.DB.Model(&model.Agent{}).Select("uuid").Where("agent_group = ?", group)).
+			Distinct("agent_id").
+			Count(&busy).Error
+		if err != nil {
+			return "", err
+		}
+		// 加上本次要修复的 Agent 后的占比
+		if groupSize > 0 && (busy+1)*100 > int64(limits.MaxGroupPercent)*groupSize {
+			groupName := group
+			if groupName == "" {
+				groupName = "ungrouped agents"
+			}
+			return fmt.Sprintf("%s limit of %d%% of group %q reached (%d of %d agents remediating or rolling back)",
+				limits.Scope, limits.MaxGroupPercent, groupName, busy, groupSize), nil
+		}
+	}
+	return "", nil
+}
+
+// remediatingWorkflows 返回查询正在改动主机的工作流 (修复中和回滚中) 的语句，kbID 为空时不限知识库条目
+// 回滚同样在主机上执行变更，必须计入并发和分组占比限制
+func remediatingWorkflows(kbID string) *gorm.DB {
+	query := store.DB.Model(&model.Workflow{}).Where("status IN ?", []string{StatusRemediating, StatusRollingBack})
+	if kbID != "" {
+		query = query.Where("kb_id = ?", kbID)
+	}
+	return query
+}
+
+// holdForGuardrail 挂起超出护栏限制的工作流，并发出告警通知运维人员
+// 挂起的工作流需要运维人员确认后通过 release 接口放行
+func holdForGuardrail(workflow *model.Workflow, violation string) {
+	logger.L.Warnw("Remediation stopped by guardrail", "workflow_id", workflow.ID, "kb_id", workflow.KBID, "agent_id", workflow.AgentID, "reason", violation)
+	transitionWorkflow(workflow, StatusOnHold, "remediation on hold: guardrail: "+violation, map[string]interface{}{
+		"resume_status":  StatusRemediating,
+		"deferred_until": nil,
+	})
+
+	alert := &model.Alert{
+		Type:     "guardrail_tripped",
+		Source:   "pioneer",
+		Severity: SeverityCritical,
+		AgentID:  workflow.AgentID,
+		KBID:     workflow.KBID,
+		Message:  fmt.Sprintf("remediation of workflow %s was put on hold: %s", workflow.ID, violation),
+	}
+	if err := RecordAlert(alert); err != nil {
+		logger.L.Errorw("Failed to record guardrail alert", "workflow_id", workflow.ID, "error", err)
+	}
+}
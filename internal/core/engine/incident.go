@@ -0,0 +1,261 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/config"
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/logger"
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/model"
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/store"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// 事件状态
+const (
+	IncidentOpen         = "open"
+	IncidentAcknowledged = "acknowledged"
+	IncidentResolved     = "resolved"
+)
+
+// 告警级别
+const (
+	SeverityInfo     = "info"
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+)
+
+const defaultIncidentGroupWindow = 15 * time.Minute
+
+var (
+	// ErrInvalidIncidentTransition 表示请求的事件状态变更不合法
+	ErrInvalidIncidentTransition = errors.New("invalid incident status transition")
+	// ErrInvalidSeverity 表示告警级别不合法
+	ErrInvalidSeverity = errors.New("invalid severity, must be one of info, warning, critical")
+)
+
+// incidentTransitions 定义了事件合法的状态变更，已解决的事件可以重新打开
+var incidentTransitions = map[string][]string{
+	IncidentOpen:         {IncidentAcknowledged, IncidentResolved},
+	IncidentAcknowledged: {IncidentResolved, IncidentOpen},
+	IncidentResolved:     {IncidentOpen},
+}
+
+var severityRank = map[string]int{SeverityInfo: 0, SeverityWarning: 1, SeverityCritical: 2}
+
+// incidentMu 串行化事件的查找和创建，避免同一时刻的多个工作流各自创建一个事件
+// 多副本部署时还会同时持有跨副本的锁，见 withClusterLock
+var incidentMu sync.Mutex
+
+// incidentEvent 是一个需要聚合到事件中的工作流或告警
+type incidentEvent struct {
+	KBID       string
+	AlertType  string
+	AgentGroup string
+	Severity   string
+	At         time.Time
+	IsAlert    bool
+}
+
+// groupKey 返回事件的聚合键
+// 有知识库条目时按知识库条目聚合 (同一条目的工作流和告警属于同一问题)，否则按告警类型聚合
+func (e incidentEvent) groupKey() string {
+	if e.KBID != "" {
+		return "kb:" + e.KBID + "|group:" + e.AgentGroup
+	}
+	return "alert:" + e.AlertType + "|group:" + e.AgentGroup
+}
+
+func (e incidentEvent) title() string {
+	group := e.AgentGroup
+	if group == "" {
+		group = "ungrouped agents"
+	}
+	if e.KBID != "" {
+		return fmt.Sprintf("KB %s triggered on %s", e.KBID, group)
+	}
+	return fmt.Sprintf("%s alerts on %s", e.AlertType, group)
+}
+
+// attachWorkflowToIncident 把新启动的工作流聚合到事件中
+// 事件只用于展示和协作，聚合失败不影响工作流的执行
+func attachWorkflowToIncident(workflow *model.Workflow) {
+	event := incidentEvent{
+		KBID:       workflow.KBID,
+		AgentGroup: agentGroup(workflow.AgentID),
+		Severity:   SeverityWarning,
+		At:         workflow.CreatedAt,
+	}
+	incident, err := recordIncidentEvent(event)
+	if err != nil {
+		logger.L.Errorw("Failed to group workflow into incident", "workflow_id", workflow.ID, "error", err)
+		return
+	}
+
+	if err := store.DB.Model(&model.Workflow{}).Where("id = ?", workflow.ID).UpdateColumn("incident_id", incident.ID).Error; err != nil {
+		logger.L.Errorw("Failed to link workflow to incident", "workflow_id", workflow.ID, "incident_id", incident.ID, "error", err)
+		return
+	}
+	workflow.IncidentID = incident.ID
+}
+
+// RecordAlert 保存一条告警并把它聚合到事件中
+func RecordAlert(alert *model.Alert) error {
+	if alert.Severity == "" {
+		alert.Severity = SeverityWarning
+	}
+	if _, ok := severityRank[alert.Severity]; !ok {
+		return fmt.Errorf("%w: %q", ErrInvalidSeverity, alert.Severity)
+	}
+	if alert.AgentGroup == "" && alert.AgentID != "" {
+		alert.AgentGroup = agentGroup(alert.AgentID)
+	}
+	if alert.CreatedAt.IsZero() {
+		alert.CreatedAt = time.Now()
+	}
+
+	incident, err := recordIncidentEvent(incidentEvent{
+		KBID:       alert.KBID,
+		AlertType:  alert.Type,
+		AgentGroup: alert.AgentGroup,
+		Severity:   alert.Severity,
+		At:         alert.CreatedAt,
+		IsAlert:    true,
+	})
+	if err != nil {
+		return err
+	}
+	alert.IncidentID = incident.ID
+	return store.DB.Create(alert).Error
+}
+
+// recordIncidentEvent 找到聚合键相同、未解决且在时间窗口内有过事件的事件，找不到时新建一个
+func recordIncidentEvent(event incidentEvent) (*model.Incident, error) {
+	var incident *model.Incident
+	var err error
+	if lockErr := withClusterLock(&incidentMu, "incidents", func() {
+		incident, err = recordIncidentEventLocked(event)
+	}); lockErr != nil {
+		return nil, lockErr
+	}
+	return incident, err
+}
+
+func recordIncidentEventLocked(event incidentEvent) (*model.Incident, error) {
+	var incident model.Incident
+	err := store.DB.
+		Where("group_key = ? AND status <> ? AND last_event_at >= ?", event.groupKey(), IncidentResolved, event.At.Add(-incidentGroupWindow())).
+		Order("last_event_at desc").
+		First(&incident).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		incident = model.Incident{
+			ID:           uuid.NewString(),
+			Title:        event.title(),
+			GroupKey:     event.groupKey(),
+			KBID:         event.KBID,
+			AlertType:    event.AlertType,
+			AgentGroup:   event.AgentGroup,
+			Status:       IncidentOpen,
+			Severity:     event.Severity,
+			FirstEventAt: event.At,
+			LastEventAt:  event.At,
+		}
+		countIncidentEvent(&incident, event)
+		if err := store.DB.Create(&incident).Error; err != nil {
+			return nil, err
+		}
+		logger.L.Infow("Incident opened", "incident_id", incident.ID, "title", incident.Title)
+		return &incident, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if severityRank[event.Severity] > severityRank[incident.Severity] {
+		incident.Severity = event.Severity
+	}
+	if event.At.After(incident.LastEventAt) {
+		incident.LastEventAt = event.At
+	}
+	countIncidentEvent(&incident, event)
+	updateData := map[string]interface{}{
+		"severity":       incident.Severity,
+		"last_event_at":  incident.LastEventAt,
+		"workflow_count": incident.WorkflowCount,
+		"alert_count":    incident.AlertCount,
+	}
+	if err := store.DB.Model(&incident).Updates(updateData).Error; err != nil {
+		return nil, err
+	}
+	return &incident, nil
+}
+
+func countIncidentEvent(incident *model.Incident, event incidentEvent) {
+	if event.IsAlert {
+		incident.AlertCount++
+	} else {
+		incident.WorkflowCount++
+	}
+}
+
+// ChangeIncidentStatus 变更事件状态，确认时可以同时指定处理人
+func ChangeIncidentStatus(incident *model.Incident, to, assignee string) error {
+	allowed := false
+	for _, next := range incidentTransitions[incident.Status] {
+		if next == to {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return fmt.Errorf("%w: %s -> %s", ErrInvalidIncidentTransition, incident.Status, to)
+	}
+
+	now := time.Now()
+	updateData := map[string]interface{}{"status": to}
+	switch to {
+	case IncidentAcknowledged:
+		updateData["acknowledged_at"] = now
+	case IncidentResolved:
+		updateData["resolved_at"] = now
+	case IncidentOpen:
+		updateData["acknowledged_at"] = nil
+		updateData["resolved_at"] = nil
+	}
+	if assignee != "" {
+		updateData["assignee"] = assignee
+	}
+
+	// 以当前状态为条件更新，避免两个人同时操作时互相覆盖
+	result := store.DB.Model(&model.Incident{}).Where("id = ? AND status = ?", incident.ID, incident.Status).Updates(updateData)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("%w: incident was modified concurrently", ErrInvalidIncidentTransition)
+	}
+	logger.L.Infow("Incident status changed", "incident_id", incident.ID, "from", incident.Status, "to", to, "assignee", assignee)
+	return store.DB.Where("id = ?", incident.ID).First(incident).Error
+}
+
+// incidentGroupWindow 返回配置的聚合时间窗口
+func incidentGroupWindow() time.Duration {
+	if config.C.Incident.GroupWindow != "" {
+		if d, err := time.ParseDuration(config.C.Incident.GroupWindow); err == nil && d > 0 {
+			return d
+		}
+	}
+	return defaultIncidentGroupWindow
+}
+
+// agentGroup 查询 Agent 所属的分组，查询失败时视为未分组
+func agentGroup(agentID string) string {
+	var agent model.Agent
+	if err := store.DB.Select("agent_group").Where("uuid = ?", agentID).First(&agent).Error; err != nil {
+		return ""
+	}
+	return agent.Group
+}
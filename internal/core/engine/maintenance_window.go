@@ -0,0 +1,267 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/model"
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/store"
+)
+
+// 维护窗口模式
+const (
+	WindowModeAllow = "allow" // 修复只能在窗口内执行
+	WindowModeBlock = "block" // 窗口内禁止修复
+)
+
+// 修复被阻止时的处理方式，按严格程度从低到高排列
+const (
+	BlockActionDefer  = "defer"  // 推迟到下一个允许的时间点自动恢复
+	BlockActionHold   = "hold"   // 挂起，等待人工放行
+	BlockActionReject = "reject" // 直接让工作流失败
+)
+
+// maxDeferralSearch 是寻找下一个允许时间点的最大搜索范围
+// 所有窗口都按周循环，超过一周仍找不到说明窗口配置本身就不允许修复
+const maxDeferralSearch = 8 * 24 * time.Hour
+
+// remediationBlock 描述一次被阻止的修复以及对应的处理方式
+type remediationBlock struct {
+	Action   string
+	Reason   string
+	ResumeAt time.Time // 仅当 Action 为 defer 时有效
+}
+
+// ValidateMaintenanceWindow 校验维护窗口的配置是否合法
+func ValidateMaintenanceWindow(window *model.MaintenanceWindow) error {
+	if window.Mode != WindowModeAllow && window.Mode != WindowModeBlock {
+		return fmt.Errorf("mode must be %q or %q", WindowModeAllow, WindowModeBlock)
+	}
+	if blockActionRank(window.Action) < 0 {
+		return fmt.Errorf("action must be one of %q, %q, %q", BlockActionDefer, BlockActionHold, BlockActionReject)
+	}
+	if _, err := time.LoadLocation(window.Timezone); err != nil {
+		return fmt.Errorf("invalid timezone: %w", err)
+	}
+	if _, err := parseClock(window.StartTime); err != nil {
+		return fmt.Errorf("invalid start_time: %w", err)
+	}
+	if _, err := parseClock(window.EndTime); err != nil {
+		return fmt.Errorf("invalid end_time: %w", err)
+	}
+	if window.StartTime == window.EndTime {
+		return errors.New("start_time and end_time must differ")
+	}
+	for _, day := range window.Weekdays {
+		if day < 0 || day > 6 {
+			return fmt.Errorf("invalid weekday %d, must be 0 (Sunday) to 6", day)
+		}
+	}
+	return nil
+}
+
+// checkRemediationAllowed 检查工作流此刻是否允许执行修复步骤 (Agent 维护模式和维护窗口)
+// 返回 nil 表示允许，否则返回阻止的原因和处理方式
+func checkRemediationAllowed(workflow *model.Workflow) (*remediationBlock, error) {
+	var agent model.Agent
+	if err := store.DB.Where("uuid = ?", workflow.AgentID).First(&agent).Error; err != nil {
+		return nil, err
+	}
+
+	// Agent 处于维护模式时，修复推迟到维护结束之后
+	if agent.InMaintenance(time.Now()) {
+		return &remediationBlock{
+			Action:   BlockActionDefer,
+			Reason:   "agent is in maintenance mode: " + agent.MaintenanceReason,
+			ResumeAt: *agent.MaintenanceUntil,
+		}, nil
+	}
+
+	windows, err := applicableWindows(agent.Group, workflow.KBID)
+	if err != nil {
+		return nil, err
+	}
+	return evaluateWindows(windows, time.Now()), nil
+}
+
+// applicableWindows 查询所有对指定分组和知识库条目生效的维护窗口
+func applicableWindows(agentGroup, kbID string) ([]model.MaintenanceWindow, error) {
+	var windows []model.MaintenanceWindow
+	err := store.DB.
+		Where("enabled = ?", true).
+		Where("agent_group = '' OR agent_group = ?", agentGroup).
+		Where("kb_id = '' OR kb_id = ?", kbID).
+		Find(&windows).Error
+	return windows, err
+}
+
+// evaluateWindows 根据一组维护窗口判断 now 时刻是否允许修复
+// 规则:
+//   - 任意一个 block 窗口包含 now，则被阻止
+//   - 存在 allow 窗口但没有任何一个包含 now，则被阻止
+//
+// 多个窗口同时阻止时，采用最严格的处理方式 (reject > hold > defer)
+func evaluateWindows(windows []model.MaintenanceWindow, now time.Time) *remediationBlock {
+	blocking, reasons := blockingWindows(windows, now)
+	if len(blocking) == 0 {
+		return nil
+	}
+
+	block := &remediationBlock{Action: BlockActionDefer}
+	for _, window := range blocking {
+		if blockActionRank(window.Action) > blockActionRank(block.Action) {
+			block.Action = window.Action
+		}
+	}
+	block.Reason = strings.Join(reasons, "; ")
+
+	if block.Action == BlockActionDefer {
+		resumeAt, ok := nextAllowedTime(windows, now)
+		if !ok {
+			// 一周之内都找不到允许的时间点，只能交给人工处理
+			block.Action = BlockActionHold
+			block.Reason += "; no allowed time found within the next week"
+			return block
+		}
+		block.ResumeAt = resumeAt
+	}
+	return block
+}
+
+// blockingWindows 返回在 t 时刻阻止修复的窗口及原因
+func blockingWindows(windows []model.MaintenanceWindow, t time.Time) ([]model.MaintenanceWindow, []string) {
+	var blocking, allowWindows []model.MaintenanceWindow
+	var reasons []string
+	insideAllow := false
+
+	for _, window := range windows {
+		switch window.Mode {
+		case WindowModeBlock:
+			if windowContains(window, t) {
+				blocking = append(blocking, window)
+				reasons = append(reasons, fmt.Sprintf("blocked by maintenance window %q (%s)", window.Name, describeWindow(window)))
+			}
+		case WindowModeAllow:
+			allowWindows = append(allowWindows, window)
+			if windowContains(window, t) {
+				insideAllow = true
+			}
+		}
+	}
+
+	if len(allowWindows) > 0 && !insideAllow {
+		blocking = append(blocking, allowWindows...)
+		names := make([]string, 0, len(allowWindows))
+		for _, window := range allowWindows {
+			names = append(names, fmt.Sprintf("%q (%s)", window.Name, describeWindow(window)))
+		}
+		reasons = append(reasons, "outside allowed maintenance windows "+strings.Join(names, ", "))
+	}
+	return blocking, reasons
+}
+
+// nextAllowedTime 以分钟为步长向后搜索第一个允许修复的时间点
+func nextAllowedTime(windows []model.MaintenanceWindow, now time.Time) (time.Time, bool) {
+	t := now.Truncate(time.Minute).Add(time.Minute)
+	for limit := now.Add(maxDeferralSearch); t.Before(limit); t = t.Add(time.Minute) {
+		if blocking, _ := blockingWindows(windows, t); len(blocking) == 0 {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// windowContains 判断 t 是否落在窗口内
+// 跨越午夜的窗口 (例如 22:00-06:00) 以开始时间所在的那一天来匹配星期
+func windowContains(window model.MaintenanceWindow, t time.Time) bool {
+	loc, err := loadLocation(window.Timezone)
+	if err != nil {
+		return false
+	}
+	start, err := parseClock(window.StartTime)
+	if err != nil {
+		return false
+	}
+	end, err := parseClock(window.EndTime)
+	if err != nil {
+		return false
+	}
+
+	local := t.In(loc)
+	// 检查今天开始的窗口和昨天开始、跨越到今天的窗口
+	for _, offset := range []int{0, -1} {
+		day := local.AddDate(0, 0, offset)
+		if !weekdayMatches(window.Weekdays, day.Weekday()) {
+			continue
+		}
+		midnight := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, loc)
+		windowStart := midnight.Add(start)
+		windowEnd := midnight.Add(end)
+		if end <= start {
+			windowEnd = windowEnd.Add(24 * time.Hour)
+		}
+		if !local.Before(windowStart) && local.Before(windowEnd) {
+			return true
+		}
+	}
+	return false
+}
+
+func weekdayMatches(weekdays []int, day time.Weekday) bool {
+	if len(weekdays) == 0 {
+		return true
+	}
+	for _, d := range weekdays {
+		if time.Weekday(d) == day {
+			return true
+		}
+	}
+	return false
+}
+
+// locationCache 缓存已加载的时区，nextAllowedTime 会对同一个时区做上万次判断
+var locationCache sync.Map
+
+func loadLocation(name string) (*time.Location, error) {
+	if loc, ok := locationCache.Load(name); ok {
+		return loc.(*time.Location), nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, err
+	}
+	locationCache.Store(name, loc)
+	return loc, nil
+}
+
+// parseClock 把 "HH:MM" 解析为距离零点的时长
+func parseClock(clock string) (time.Duration, error) {
+	t, err := time.Parse("15:04", clock)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+func describeWindow(window model.MaintenanceWindow) string {
+	timezone := window.Timezone
+	if timezone == "" {
+		timezone = "UTC"
+	}
+	return fmt.Sprintf("%s %s-%s %s", window.Mode, window.StartTime, window.EndTime, timezone)
+}
+
+func blockActionRank(action string) int {
+	switch action {
+	case BlockActionDefer:
+		return 0
+	case BlockActionHold:
+		return 1
+	case BlockActionReject:
+		return 2
+	}
+	return -1
+}
@@ -1,25 +1,113 @@
 package engine
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 // Task 代表一个需要 Agent 执行的具体指令
 type Task struct {
 	ID         string    `json:"ID"`      // 任务的唯一ID
 	AgentID    string    `json:"AgentID"` // 目标 Agent
 	WorkflowID string    `json:"WorkflowID"`
-	Type       string    `json:"Type"`      // 任务类型, e.g., "diagnostic", "remediation"
-	Command    string    `json:"Command"`   // 要执行的命令
-	CreatedAt  time.Time `json:"CreatedAt"` // 创建时间
+	Type       string    `json:"Type"`           // 任务类型, e.g., "diagnostic", "remediation", "rollback"
+	Kind       string    `json:"Kind,omitempty"` // 执行方式，见 TaskKindCommand 等，为空等同于 "command"
+	Command    string    `json:"Command"`        // 要执行的命令
+	Priority   Priority  `json:"Priority"`       // 优先级，继承自所属的工作流
+	CreatedAt  time.Time `json:"CreatedAt"`      // 创建时间
+	// NotBefore 之前任务不会分发给 Agent，为空表示立即可分发
+	NotBefore *time.Time `json:"NotBefore,omitempty"`
+	// ExpiresAt 之后任务不再分发，而是以 expired 结果回报给工作流，为空表示永不过期
+	ExpiresAt *time.Time `json:"ExpiresAt,omitempty"`
+	// Timeout 是 Agent 执行任务的超时时间 (秒)，取自知识库步骤的 "timeout"，0 表示使用 Agent 的默认值
+	Timeout int `json:"Timeout,omitempty"`
+
+	// 以下是可选的执行参数，取自知识库步骤，Agent 无法满足时拒绝执行任务 (见 TaskResultRejected)
+	Env         map[string]string `json:"Env,omitempty"`         // 追加的环境变量，步骤中以 "env.<NAME>" 指定
+	WorkDir     string            `json:"WorkDir,omitempty"`     // 工作目录，为空时使用 Agent 的工作目录
+	Stdin       string            `json:"Stdin,omitempty"`       // 写入命令标准输入的内容
+	Interpreter string            `json:"Interpreter,omitempty"` // "sh" (默认)、"bash"、"python3" 或 "direct" (不经过 shell 直接执行)
+	RunAs       string            `json:"RunAs,omitempty"`       // 以该用户身份执行，需要 Agent 以 root 运行
+
+	// 脚本任务 (Kind 为 "script") 的脚本名称、内容和内容的 SHA-256，Agent 校验通过后才执行
+	// 知识库步骤只引用脚本名称，内容在提交任务时从服务端加载
+	ScriptName   string `json:"ScriptName,omitempty"`
+	Script       string `json:"Script,omitempty"`
+	ScriptSHA256 string `json:"ScriptSHA256,omitempty"`
+
+	// File 是文件传输任务 (Kind 为 "file_push" 或 "file_pull") 的参数
+	File *FileTransfer `json:"File,omitempty"`
+
+	// 内置动作任务 (Kind 为 "action") 的动作名称 (例如 "disk.usage") 和参数，步骤中参数以 "arg.<name>" 指定
+	// 由 Agent 插件执行的任务 (Kind 为插件名称) 同样通过 Args 接收参数
+	Action string            `json:"Action,omitempty"`
+	Args   map[string]string `json:"Args,omitempty"`
+
+	ttl time.Duration // 知识库步骤中配置的有效期，只在构造任务时使用
 }
 
+// 任务的执行方式
+const (
+	TaskKindCommand  = "command"   // 执行 Command
+	TaskKindScript   = "script"    // 把脚本写入临时文件后执行
+	TaskKindFilePush = "file_push" // 把服务端的文件分块下发到 Agent
+	TaskKindFilePull = "file_pull" // 把 Agent 上的文件分块收集到服务端
+	TaskKindAction   = "action"    // 执行 Agent 内置的动作，结果以结构化数据 (TaskResult.Data) 返回
+	// 其他执行方式由 Agent 上的执行插件处理，Agent 在注册时上报自己支持的执行方式
+)
+
+// FileTransfer 是文件传输任务的参数，文件内容不随任务下发，Agent 通过文件传输接口分块传输并逐块校验
+type FileTransfer struct {
+	FileID string `json:"FileID,omitempty"` // file_push: 要下发的文件
+	SHA256 string `json:"SHA256,omitempty"` // file_push: 文件内容的 SHA-256，提交任务时填写
+	Size   int64  `json:"Size,omitempty"`   // file_push: 文件字节数，提交任务时填写
+	Path   string `json:"Path"`             // file_push: Agent 上的目标路径；file_pull: 要收集的文件
+	Mode   string `json:"Mode,omitempty"`   // file_push: 八进制权限，例如 "0644"，默认 0644
+	Owner  string `json:"Owner,omitempty"`  // file_push: 文件所有者和所属组，需要 Agent 以 root 运行
+	Group  string `json:"Group,omitempty"`
+	// MaxSize 是 file_pull 允许收集的最大字节数，不超过 blob.max_file_size
+	MaxSize int64 `json:"MaxSize,omitempty"`
+}
+
+// TaskResultExpired 表示任务在分发给 Agent 之前已过期，由调度方而不是 Agent 上报
+const TaskResultExpired = "expired"
+
+// TaskResultTimedOut 表示 Agent 执行任务超时，命令 (连同它的进程组) 已被终止
+const TaskResultTimedOut = "timed_out"
+
+// TaskResultRejected 表示 Agent 无法满足任务的执行参数 (例如用户不存在)，任务没有被执行，原因在 Error 中
+const TaskResultRejected = "rejected"
+
 // TaskResult 代表 Agent 执行任务后返回的结果
 type TaskResult struct {
 	TaskID   string `json:"task_id"`
 	AgentID  string `json:"agent_id"`
 	Success  bool   `json:"success"`
-	Output   string `json:"output"`
+	Output   string `json:"output"` // 旧版本 Agent 上报的 stdout 和 stderr 合并输出，新版本 Agent 分别上报 Stdout 和 Stderr
 	Error    string `json:"error"`
 	ExitCode int    `json:"exit_code"`
+	// Stdout 和 Stderr 由 Agent 截断到配置的大小，超出时保留开头和结尾，中间插入截断标记
+	// StdoutBytes 和 StderrBytes 是截断前的原始字节数
+	Stdout      string `json:"stdout"`
+	Stderr      string `json:"stderr"`
+	StdoutBytes int64  `json:"stdout_bytes"`
+	StderrBytes int64  `json:"stderr_bytes"`
+	// Status 为空表示 Agent 正常执行后的结果，"timed_out" 表示执行超时，"rejected" 表示 Agent 拒绝执行，
+	// "expired" 表示任务未被执行就已过期
+	Status string `json:"status,omitempty"`
+	// Data 是内置动作任务返回的结构化结果 (JSON)，诊断步骤的 "remediate_if" 条件基于它判断
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+// CombinedOutput 返回任务的全部输出，用于日志等只关心输出内容的地方
+func (r *TaskResult) CombinedOutput() string {
+	if r.Output != "" || (r.Stdout == "" && r.Stderr == "") {
+		return r.Output
+	}
+	if r.Stderr == "" {
+		return r.Stdout
+	}
+	return r.Stdout + "\n[stderr]\n" + r.Stderr
 }
 
 // Workflow 代表一个完整的自动化工作流实例
@@ -38,4 +126,6 @@ type KnowledgeBaseItem struct {
 	Diagnostics   []map[string]string `json:"diagnostics"`
 	AnalysisLogic string              `json:"analysis_logic"`
 	Remediation   map[string]string   `json:"remediation"`
+	// Rollback 是可选的回滚步骤，修复失败后执行，用于撤销修复造成的部分变更
+	Rollback map[string]string `json:"rollback"`
 }
@@ -0,0 +1,84 @@
+package engine
+
+import (
+	"fmt"
+
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/logger"
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/model"
+)
+
+// Priority 表示工作流及其任务的优先级，数值越大越优先
+// 零值为 normal，这样历史数据和未指定优先级的调用方都会得到普通优先级
+type Priority int
+
+const (
+	PriorityLow    Priority = -1 // 例行清理等低价值的任务
+	PriorityNormal Priority = 0
+	PriorityHigh   Priority = 1
+	PriorityUrgent Priority = 2 // 紧急修复
+
+	minPriority   = PriorityLow
+	maxPriority   = PriorityUrgent
+	numPriorities = int(maxPriority-minPriority) + 1
+)
+
+var priorityNames = map[Priority]string{
+	PriorityLow:    "low",
+	PriorityNormal: "normal",
+	PriorityHigh:   "high",
+	PriorityUrgent: "urgent",
+}
+
+// ParsePriority 把 "low" / "normal" / "high" / "urgent" 解析为优先级，空字符串表示 normal
+func ParsePriority(name string) (Priority, error) {
+	if name == "" {
+		return PriorityNormal, nil
+	}
+	for p, n := range priorityNames {
+		if n == name {
+			return p, nil
+		}
+	}
+	return PriorityNormal, fmt.Errorf("unknown priority %q, must be one of low, normal, high, urgent", name)
+}
+
+func (p Priority) String() string {
+	if name, ok := priorityNames[p.clamp()]; ok {
+		return name
+	}
+	return "normal"
+}
+
+// clamp 把越界的优先级收敛到合法范围内
+func (p Priority) clamp() Priority {
+	if p < minPriority {
+		return minPriority
+	}
+	if p > maxPriority {
+		return maxPriority
+	}
+	return p
+}
+
+// level 返回优先级在队列数组中的下标 (0 为最低优先级)
+func (p Priority) level() int {
+	return int(p.clamp() - minPriority)
+}
+
+// preemptLowerPriority 取消同一 Agent 上所有排队中、优先级低于 workflow 的任务
+// 被抢占任务所属的工作流会流转到 cancelled，已经下发给 Agent 的任务不受影响
+func preemptLowerPriority(workflow *model.Workflow) {
+	preempted := TM.PreemptLowerPriority(workflow.AgentID, Priority(workflow.Priority))
+	for _, task := range preempted {
+		reason := fmt.Sprintf("queued task preempted by %s priority workflow %s", Priority(workflow.Priority), workflow.ID)
+		recordTaskCancelled(task.ID, reason)
+
+		victim := waitingWorkflow(task)
+		if victim == nil {
+			continue
+		}
+		if transitionWorkflow(victim, StatusCancelled, reason, nil) {
+			logger.L.Infow("Workflow preempted", "workflow_id", victim.ID, "task_id", task.ID, "by_workflow_id", workflow.ID)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package engine
+
+import (
+	"time"
+
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/config"
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/logger"
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/model"
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/store"
+)
+
+const defaultQueueIdleTimeout = 30 * time.Minute
+
+// EvictIdleQueues 清理已注销 Agent 的任务队列和不活跃的空队列，由调度器周期性调用
+// 已注册 Agent 的队列中还有任务时不会被清理，即使 Agent 暂时离线: 任务在过期前仍然有效，过期后由 ExpireQueuedTasks 回报给工作流
+// 已注销 Agent 的队列中尚未分发的任务不会被静默丢弃: 任务记录标记为取消，所属工作流流转到 failed 并记录原因
+func EvictIdleQueues() {
+	idleTimeout := defaultQueueIdleTimeout
+	if config.C.TaskQueue.IdleTimeout != "" {
+		if d, err := time.ParseDuration(config.C.TaskQueue.IdleTimeout); err == nil && d > 0 {
+			idleTimeout = d
+		}
+	}
+
+	var agentIDs []string
+	keep := func(string) bool { return true }
+	if err := store.DB.Model(&model.Agent{}).Pluck("uuid", &agentIDs).Error; err != nil {
+		// 无法确认 Agent 是否仍然注册时，只清理不活跃的空队列
+		logger.L.Errorw("Failed to load registered agents for queue cleanup", "error", err)
+	} else {
+		registered := make(map[string]bool, len(agentIDs))
+		for _, agentID := range agentIDs {
+			registered[agentID] = true
+		}
+		keep = func(agentID string) bool { return registered[agentID] }
+	}
+
+	pending := TM.EvictQueues(idleTimeout, keep)
+	// 只有已注销 Agent 的队列被清理时会带有任务
+	for _, tasks := range pending {
+		for _, task := range tasks {
+			failEvictedTask(task, "agent task queue evicted because the agent is no longer registered")
+		}
+	}
+}
+
+// failEvictedTask 取消一个随队列一起被清理的任务，并让所属工作流失败
+func failEvictedTask(task *Task, reason string) {
+	recordTaskCancelled(task.ID, reason)
+
+	workflow := waitingWorkflow(task)
+	if workflow == nil {
+		return
+	}
+	if transitionWorkflow(workflow, StatusFailed, reason, nil) {
+		logger.L.Warnw("Workflow failed because its queued task was evicted", "workflow_id", workflow.ID, "task_id", task.ID, "reason", reason)
+	}
+}
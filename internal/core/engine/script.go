@@ -0,0 +1,42 @@
+package engine
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/model"
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/store"
+	"gorm.io/gorm"
+)
+
+// ErrScriptNotFound 表示知识库步骤引用的脚本不存在
+var ErrScriptNotFound = errors.New("script not found")
+
+// ScriptSHA256 计算脚本内容的 SHA-256 (十六进制)，Agent 用它校验收到的脚本
+func ScriptSHA256(body string) string {
+	sum := sha256.Sum256([]byte(body))
+	return hex.EncodeToString(sum[:])
+}
+
+// resolveTaskScript 为脚本任务加载脚本内容，脚本声明的解释器优先于步骤中的 "interpreter"
+// 在提交任务时加载，而不是在构造任务时，使 decideNext 保持为纯函数
+func resolveTaskScript(task *Task) error {
+	if task.Kind != TaskKindScript {
+		return nil
+	}
+	var script model.Script
+	if err := store.DB.Where("name = ?", task.ScriptName).First(&script).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("%w: %s", ErrScriptNotFound, task.ScriptName)
+		}
+		return err
+	}
+	task.Script = script.Body
+	task.ScriptSHA256 = script.SHA256
+	if script.Interpreter != "" {
+		task.Interpreter = script.Interpreter
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package engine
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/model"
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/store"
+)
+
+// maxSimulationSteps 防止知识库逻辑出现循环时模拟无法结束
+const maxSimulationSteps = 100
+
+// SimulatedTask 是模拟过程中引擎会下发给 Agent 的任务
+type SimulatedTask struct {
+	ID      string `json:"id"`
+	Type    string `json:"type"`
+	Command string `json:"command"`
+}
+
+// SimulationStep 是模拟过程中的一次状态流转
+type SimulationStep struct {
+	FromStatus string         `json:"from_status"`
+	ToStatus   string         `json:"to_status"`
+	Reason     string         `json:"reason"`
+	Result     *TaskResult    `json:"result,omitempty"` // 触发这次流转的任务结果，启动时为空
+	Task       *SimulatedTask `json:"task,omitempty"`   // 流转后下发的任务
+}
+
+// SimulationResult 是一次离线模拟的结果
+type SimulationResult struct {
+	Path          []string         `json:"path"`     // 依次经过的状态
+	Steps         []SimulationStep `json:"steps"`    // 每次流转的细节
+	Commands      []string         `json:"commands"` // 依次下发的命令
+	FinalStatus   string           `json:"final_status"`
+	Finished      bool             `json:"finished"`       // 是否到达终止状态
+	UnusedResults int              `json:"unused_results"` // 工作流结束后剩余未使用的结果数
+	Message       string           `json:"message"`
+}
+
+// Simulate 在不连接 Agent、不写数据库的情况下，用给定的任务结果依次驱动状态机
+// results 按下发顺序对应每个任务的执行结果，结果用完但工作流未结束时模拟停在当前状态
+// 模拟不检查维护窗口、Agent 维护模式等运行时条件，只验证知识库本身的分支逻辑
+func Simulate(kbItem *KnowledgeBaseItem, results []TaskResult) (*SimulationResult, error) {
+	if kbItem == nil {
+		return nil, errors.New("KB item is required")
+	}
+
+	sim := &SimulationResult{Path: []string{StatusPending}}
+	status := StatusPending
+	taskSeq := 0
+
+	// advance 记录一次流转，并在需要时下发任务
+	advance := func(next decision, result *TaskResult) error {
+		if !CanTransition(status, next.Status) {
+			return fmt.Errorf("%w: %s -> %s", ErrInvalidTransition, status, next.Status)
+		}
+		step := SimulationStep{FromStatus: status, ToStatus: next.Status, Reason: next.Reason, Result: result}
+		if next.Task != nil {
+			taskSeq++
+			step.Task = &SimulatedTask{
+				ID:      fmt.Sprintf("sim-task-%d", taskSeq),
+				Type:    next.Task.Type,
+				Command: next.Task.Command,
+			}
+			sim.Commands = append(sim.Commands, next.Task.Command)
+		}
+		sim.Steps = append(sim.Steps, step)
+		sim.Path = append(sim.Path, next.Status)
+		status = next.Status
+		return nil
+	}
+
+	if err := advance(firstStep(kbItem), nil); err != nil {
+		return nil, err
+	}
+
+	used := 0
+	for !IsTerminalStatus(status) {
+		if used >= len(results) {
+			sim.Message = fmt.Sprintf("no more results, workflow is waiting in %s", status)
+			break
+		}
+		if len(sim.Steps) >= maxSimulationSteps {
+			sim.Message = fmt.Sprintf("simulation stopped after %d steps", maxSimulationSteps)
+			break
+		}
+
+		result := results[used]
+		used++
+		// 让结果和任务对应起来，方便阅读模拟输出
+		if last := sim.Steps[len(sim.Steps)-1].Task; last != nil && result.TaskID == "" {
+			result.TaskID = last.ID
+		}
+		if err := advance(decideNext(status, &result, kbItem), &result); err != nil {
+			return nil, err
+		}
+	}
+
+	sim.FinalStatus = status
+	sim.Finished = IsTerminalStatus(status)
+	sim.UnusedResults = len(results) - used
+	if sim.Finished && sim.Message == "" {
+		sim.Message = "workflow finished with status " + status
+	}
+	return sim, nil
+}
+
+// RecordedResults 按下发顺序返回一个历史工作流中已有结果的任务，用于回放
+func RecordedResults(workflowID string) ([]TaskResult, error) {
+	var tasks []model.WorkflowTask
+	err := store.DB.Where("workflow_id = ? AND status IN ?", workflowID, finishedTaskStatuses).
+		Order("created_at asc").
+		Find(&tasks).Error
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]TaskResult, 0, len(tasks))
+	for _, task := range tasks {
+		result := TaskResult{
+			TaskID:   task.ID,
+			AgentID:  task.AgentID,
+			Success:  task.Status == TaskStatusSucceeded,
+			Output:   task.Output,
+			Stdout:   task.Stdout,
+			Stderr:   task.Stderr,
+			Error:    task.Error,
+			ExitCode: task.ExitCode,
+		}
+		if task.Status == TaskStatusTimedOut {
+			result.Status = TaskResultTimedOut
+		}
+		if task.Data != "" {
+			result.Data = json.RawMessage(task.Data)
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
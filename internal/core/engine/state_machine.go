@@ -0,0 +1,157 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/model"
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/store"
+	"gorm.io/gorm"
+)
+
+// 工作流状态 (详见 docs/arch/工作流状态流转.md)
+const (
+	StatusPending     = "pending"      // 待处理
+	StatusDiagnosing  = "diagnosing"   // 诊断中
+	StatusRemediating = "remediating"  // 修复中
+	StatusRollingBack = "rolling_back" // 回滚中: 修复失败后执行知识库中的回滚步骤
+	StatusDeferred    = "deferred"     // 已推迟: 修复被维护窗口阻止，到期后自动恢复
+	StatusOnHold      = "on_hold"      // 已挂起: 修复被阻止，等待人工放行
+	StatusCompleted   = "completed"    // 已完成
+	StatusFailed      = "failed"       // 已失败
+	StatusCancelled   = "cancelled"    // 已取消: 排队中的任务被更高优先级的工作流抢占
+)
+
+var (
+	// ErrInvalidTransition 表示请求的状态流转不在状态机定义之内
+	ErrInvalidTransition = errors.New("invalid workflow status transition")
+	// ErrWorkflowConflict 表示工作流在读取之后已被其他协程修改 (乐观锁冲突)
+	ErrWorkflowConflict = errors.New("workflow was modified concurrently")
+)
+
+// workflowTransitions 定义了所有合法的状态流转, key 为源状态
+// 终止状态 (completed, failed, cancelled) 不允许再流转到任何状态
+var workflowTransitions = map[string][]string{
+	StatusPending: {StatusDiagnosing, StatusFailed},
+	// 任务提交时 Agent 队列已满，工作流会被推迟 (deferred)，到期后重新提交
+	StatusDiagnosing:  {StatusRemediating, StatusCompleted, StatusFailed, StatusDeferred, StatusOnHold, StatusCancelled},
+	StatusRemediating: {StatusCompleted, StatusFailed, StatusCancelled, StatusRollingBack, StatusDeferred},
+	// 回滚无论成功与否，工作流都以失败结束
+	StatusRollingBack: {StatusFailed, StatusCancelled, StatusDeferred},
+	// 推迟到期后如果仍被阻止，允许再次推迟
+	StatusDeferred: {StatusDiagnosing, StatusRemediating, StatusRollingBack, StatusDeferred, StatusOnHold, StatusFailed},
+	StatusOnHold:   {StatusRemediating, StatusFailed},
+}
+
+// CanTransition 判断工作流能否从 from 流转到 to
+func CanTransition(from, to string) bool {
+	for _, next := range workflowTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// IsTerminalStatus 判断一个状态是否为终止状态
+func IsTerminalStatus(status string) bool {
+	return status == StatusCompleted || status == StatusFailed || status == StatusCancelled
+}
+
+// createWorkflow 持久化一个新的工作流 (初始状态为 pending)，并记录第一条流转
+func createWorkflow(workflow *model.Workflow) error {
+	workflow.Status = StatusPending
+	workflow.StatusReason = "workflow created"
+	workflow.Version = 0
+
+	return store.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(workflow).Error; err != nil {
+			return err
+		}
+		return tx.Create(&model.WorkflowTransition{
+			WorkflowID: workflow.ID,
+			ToStatus:   StatusPending,
+			Reason:     workflow.StatusReason,
+			Version:    workflow.Version,
+		}).Error
+	})
+}
+
+// TransitionWorkflow 将工作流流转到新状态
+// 1. 校验流转是否合法
+// 2. 基于 version 列做乐观锁更新，如果工作流已被其他协程修改则返回 ErrWorkflowConflict
+// 3. 在同一个事务中写入 workflow_transitions 记录
+// extra 用于在同一次更新中顺带修改其他列 (例如 current_task_id)
+// 成功后会同步更新传入的 workflow 对象
+func TransitionWorkflow(workflow *model.Workflow, to, reason string, extra map[string]interface{}) error {
+	from := workflow.Status
+	if !CanTransition(from, to) {
+		return fmt.Errorf("%w: %s -> %s", ErrInvalidTransition, from, to)
+	}
+
+	now := time.Now()
+	updateData := map[string]interface{}{
+		"status":        to,
+		"status_reason": reason,
+		"version":       gorm.Expr("version + 1"),
+		"updated_at":    now,
+	}
+	if IsTerminalStatus(to) {
+		updateData["finished_at"] = now
+	}
+	for k, v := range extra {
+		updateData[k] = v
+	}
+
+	err := store.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&model.Workflow{}).
+			Where("id = ? AND version = ?", workflow.ID, workflow.Version).
+			Updates(updateData)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrWorkflowConflict
+		}
+		return tx.Create(&model.WorkflowTransition{
+			WorkflowID: workflow.ID,
+			FromStatus: from,
+			ToStatus:   to,
+			Reason:     reason,
+			Version:    workflow.Version + 1,
+			CreatedAt:  now,
+		}).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	// 同步内存中的对象，方便调用方继续使用
+	workflow.Status = to
+	workflow.StatusReason = reason
+	workflow.Version++
+	workflow.UpdatedAt = now
+	if IsTerminalStatus(to) {
+		workflow.FinishedAt = &now
+	}
+	applyWorkflowExtra(workflow, extra)
+	return nil
+}
+
+// applyWorkflowExtra 把随流转一起更新的列同步到内存对象上
+func applyWorkflowExtra(workflow *model.Workflow, extra map[string]interface{}) {
+	if taskID, ok := extra["current_task_id"].(string); ok {
+		workflow.CurrentTaskID = taskID
+	}
+	if resumeStatus, ok := extra["resume_status"].(string); ok {
+		workflow.ResumeStatus = resumeStatus
+	}
+	if deferredUntil, ok := extra["deferred_until"]; ok {
+		if t, isTime := deferredUntil.(time.Time); isTime {
+			workflow.DeferredUntil = &t
+		} else {
+			workflow.DeferredUntil = nil
+		}
+	}
+}
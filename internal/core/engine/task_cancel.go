@@ -0,0 +1,89 @@
+package engine
+
+import (
+	"sync"
+
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/logger"
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/model"
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/store"
+)
+
+// CancelOutcome 说明 Dispatcher.CancelTask 如何处理了一个任务
+type CancelOutcome int
+
+const (
+	CancelRemoved      CancelOutcome = iota // 任务还在排队，已移出队列
+	CancelDelivered                         // 任务已分发，取消通知已推送给连接在本副本上的 Agent
+	CancelBroadcast                         // 任务已分发，Agent 不在本副本上，取消通知已广播给其他副本 (无法确认是否送达)
+	CancelNotDelivered                      // 任务已分发，但 Agent 没有通过持久连接在线，取消通知没有送达
+)
+
+// TaskCanceler 把取消通知推送给正在执行任务的 Agent
+type TaskCanceler func(taskID, reason string)
+
+var (
+	// taskCancelers 记录本副本上通过持久连接在线的 Agent，key 为 agent_id
+	taskCancelers   = make(map[string]*TaskCanceler)
+	taskCancelersMu sync.Mutex
+)
+
+// RegisterTaskCanceler 登记一个 Agent 的持久连接，返回的函数用于在连接断开时注销
+// 同一个 Agent 重复连接时，以最后一个连接为准
+func RegisterTaskCanceler(agentID string, canceler TaskCanceler) (unregister func()) {
+	taskCancelersMu.Lock()
+	defer taskCancelersMu.Unlock()
+	entry := &canceler
+	taskCancelers[agentID] = entry
+	return func() {
+		taskCancelersMu.Lock()
+		defer taskCancelersMu.Unlock()
+		if taskCancelers[agentID] == entry {
+			delete(taskCancelers, agentID)
+		}
+	}
+}
+
+// deliverCancel 把取消通知推送给连接在本副本上的 Agent，Agent 不在本副本上时返回 false
+func deliverCancel(agentID, taskID, reason string) bool {
+	taskCancelersMu.Lock()
+	entry := taskCancelers[agentID]
+	taskCancelersMu.Unlock()
+	if entry == nil {
+		return false
+	}
+	(*entry)(taskID, reason)
+	return true
+}
+
+// cancelTask 取消一个任务: 还在排队的任务直接移出队列；已经分发的任务通知正在执行它的 Agent 终止执行
+// 只有通过持久连接在线的 Agent 能收到取消通知，使用长轮询的 Agent 会把任务执行完，结果被忽略
+func cancelTask(agentID, taskID, reason string) {
+	switch TM.CancelTask(agentID, taskID, reason) {
+	case CancelRemoved:
+		recordTaskCancelled(taskID, reason)
+		logger.L.Infow("Queued task cancelled", "agent_id", agentID, "task_id", taskID, "reason", reason)
+	case CancelDelivered:
+		logger.L.Infow("Cancellation sent to agent", "agent_id", agentID, "task_id", taskID, "reason", reason)
+	case CancelBroadcast:
+		logger.L.Infow("Cancellation broadcast to the replica connected to the agent", "agent_id", agentID, "task_id", taskID, "reason", reason)
+	default:
+		logger.L.Warnw("Agent is not connected, cancellation not delivered; its late result will be ignored", "agent_id", agentID, "task_id", taskID, "reason", reason)
+	}
+}
+
+// RequeueTask 把已经分发、但 Agent 没有确认收到的任务放回队列 (例如持久连接在发送过程中断开)
+// 放回失败时与队列被清理一样处理: 任务记录标记为取消，所属工作流失败
+func RequeueTask(task *Task) {
+	updateData := map[string]interface{}{
+		"status":        TaskStatusQueued,
+		"dispatched_at": nil,
+	}
+	if err := store.DB.Model(&model.WorkflowTask{}).Where("id = ?", task.ID).Updates(updateData).Error; err != nil {
+		logger.L.Errorw("Failed to record requeued task", "task_id", task.ID, "error", err)
+	}
+	if err := TM.SubmitTask(task); err != nil {
+		failEvictedTask(task, "task could not be requeued after the agent connection was lost: "+err.Error())
+		return
+	}
+	logger.L.Infow("Unacknowledged task requeued", "agent_id", task.AgentID, "task_id", task.ID)
+}
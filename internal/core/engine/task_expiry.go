@@ -0,0 +1,44 @@
+package engine
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/config"
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/logger"
+)
+
+const defaultTaskTTLDuration = time.Hour
+
+// defaultTaskTTL 返回配置的任务默认有效期，配置为 "0" 时任务不会过期
+func defaultTaskTTL() time.Duration {
+	if config.C.TaskQueue.DefaultTTL == "" {
+		return defaultTaskTTLDuration
+	}
+	d, err := time.ParseDuration(config.C.TaskQueue.DefaultTTL)
+	if err != nil || d < 0 {
+		return defaultTaskTTLDuration
+	}
+	return d
+}
+
+// ExpireQueuedTasks 移出所有队列中已过期的任务并回报给所属工作流，由调度器周期性调用
+// Agent 拉取任务时也会跳过过期任务，这里保证 Agent 长时间离线时工作流同样能及时结束
+func ExpireQueuedTasks() {
+	expireTasks(TM.RemoveExpired(time.Now()))
+}
+
+// expireTasks 把过期的任务以 expired 结果回报给所属工作流，由工作流决定下一步
+func expireTasks(tasks []*Task) {
+	for _, task := range tasks {
+		logger.L.Warnw("Task expired before it was delivered", "agent_id", task.AgentID, "task_id", task.ID, "workflow_id", task.WorkflowID, "expires_at", task.ExpiresAt)
+		HandleTaskResult(&TaskResult{
+			TaskID:   task.ID,
+			AgentID:  task.AgentID,
+			Success:  false,
+			Error:    fmt.Sprintf("task expired at %s before it was delivered to the agent", task.ExpiresAt.Format(time.RFC3339)),
+			ExitCode: -1,
+			Status:   TaskResultExpired,
+		})
+	}
+}
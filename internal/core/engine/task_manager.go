@@ -1,63 +1,541 @@
 package engine
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/config"
 	"github.com/GenJi77JYXC/intelligent-pioneer/internal/logger"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
-// TaskManager 负责管理和分发所有 Agent 的任务
+// defaultMaxQueueDepth 是未配置 task_queue.max_depth 时每个 Agent 队列的最大任务数
+const defaultMaxQueueDepth = 50
+
+// defaultMaxBatch 是未配置 task_queue.max_batch 时一次拉取最多返回的任务数
+const defaultMaxBatch = 10
+
+var (
+	// ErrQueueFull 表示 Agent 的任务队列已满，任务没有被提交
+	ErrQueueFull = errors.New("agent task queue is full")
+	// errQueueEvicted 表示队列在提交过程中被清理，调用方应重新获取队列
+	errQueueEvicted = errors.New("agent task queue was evicted")
+)
+
+// 任务队列后端
+const (
+	TaskQueueBackendMemory   = "memory"   // 进程内队列，只支持单副本部署
+	TaskQueueBackendPostgres = "postgres" // Postgres 队列，支持多副本部署
+)
+
+// Dispatcher 负责管理和分发所有 Agent 的任务
+// 进程内的实现是 TaskManager；多副本部署时使用基于 Postgres 的实现，任意副本提交的任务都能分发给连接到其他副本的 Agent
+type Dispatcher interface {
+	// SubmitTask 向指定的 Agent 提交一个新任务，不会阻塞，队列已满时返回 ErrQueueFull
+	SubmitTask(task *Task) error
+	// GetTasksForAgent 为指定的 Agent 获取最多 limit 个可分发的任务 (支持长轮询)
+	// ctx 结束 (例如请求方断开) 时立即返回，不再领取任务
+	GetTasksForAgent(ctx context.Context, agentID string, limit int, timeout time.Duration) []*Task
+	// MaxBatch 返回单次拉取任务数的上限
+	MaxBatch() int
+	// PreemptLowerPriority 移出 Agent 队列中优先级低于 priority 的任务并返回
+	PreemptLowerPriority(agentID string, priority Priority) []*Task
+	// CancelTask 把还在排队的任务移出队列；任务已经分发时通知执行它的 Agent 终止执行，返回值说明任务被如何处理
+	CancelTask(agentID, taskID, reason string) CancelOutcome
+	// RemoveQueued 移出 Agent 队列中所有尚未分发的任务，按分发顺序返回
+	RemoveQueued(agentID string) []*Task
+	// RemoveExpired 移出所有队列中已过期的任务并返回
+	RemoveExpired(now time.Time) []*Task
+	// EvictQueues 清理不再需要的 Agent 队列，并返回被清理队列中尚未分发的任务
+	// 只清理已注销 Agent 的队列和空闲的空队列，已注册 Agent 排队中的任务由过期机制处理
+	EvictQueues(idleTimeout time.Duration, keep func(agentID string) bool) map[string][]*Task
+	// QueueStats 返回所有 Agent 任务队列的统计信息，按 agent_id 排序
+	QueueStats() []QueueStats
+	// AgentQueueStats 返回单个 Agent 任务队列的统计信息，队列不存在时返回 false
+	AgentQueueStats(agentID string) (QueueStats, bool)
+	// QueuedTasks 按分发顺序返回 Agent 队列中的任务 (不会移出队列)
+	QueuedTasks(agentID string) []*Task
+}
+
+// TaskManager 是进程内的 Dispatcher 实现
 type TaskManager struct {
-	// key: agent_id, value: 一个用于传递任务的 channel
-	agentTaskQueues map[string]chan *Task
+	// key: agent_id, value: 该 Agent 按优先级划分的任务队列
+	agentTaskQueues map[string]*agentQueue
 	mu              sync.RWMutex // 用于保护 agentTaskQueues 的并发访问
+	maxDepth        int          // 每个 Agent 队列的最大任务数
+	maxBatch        int          // 一次拉取最多返回的任务数
+}
+
+// agentQueue 是单个 Agent 的任务队列，每个优先级一个切片
+// 同一优先级内保持 FIFO，分发时总是先取高优先级的任务
+// 提交任务从不阻塞: 队列满时直接返回 ErrQueueFull
+type agentQueue struct {
+	mu     sync.Mutex
+	levels [numPriorities][]*Task
+	notify chan struct{} // 有新任务时唤醒等待中的长轮询，缓冲为 1
+
+	lastActive time.Time // 最近一次提交或拉取任务的时间，受 TaskManager.mu 保护
+	waiters    int       // 正在长轮询的请求数，受 TaskManager.mu 保护
+	evicted    bool      // 已被清理，受 mu 保护
+
+	// 统计数据
+	submitted  atomic.Int64
+	dispatched atomic.Int64
+	rejected   atomic.Int64
+	expired    atomic.Int64
+}
+
+// QueueStats 是单个 Agent 任务队列的统计信息
+type QueueStats struct {
+	AgentID         string         `json:"agent_id"`
+	Depth           int            `json:"depth"`
+	MaxDepth        int            `json:"max_depth"`
+	DepthByPriority map[string]int `json:"depth_by_priority"`
+	OldestTaskAge   string         `json:"oldest_task_age"` // 最早入队的任务已等待的时间，队列为空时为空
+	Submitted       int64          `json:"submitted"`       // 累计提交成功的任务数
+	Dispatched      int64          `json:"dispatched"`      // 累计分发给 Agent 的任务数
+	Rejected        int64          `json:"rejected"`        // 累计因队列已满被拒绝的任务数
+	Expired         int64          `json:"expired"`         // 累计在分发前过期的任务数
+	LastActiveAt    time.Time      `json:"last_active_at"`  // 最近一次提交或拉取任务的时间
+	Waiters         int            `json:"waiters"`         // 正在长轮询的请求数
 }
 
 // TM 是一个全局的任务管理器实例
-var TM *TaskManager
+var TM Dispatcher
 
-// InitTaskManager 初始化全局的任务管理器
+// InitTaskManager 根据 task_queue.backend 初始化全局的任务管理器
 func InitTaskManager() {
-	TM = &TaskManager{
-		agentTaskQueues: make(map[string]chan *Task),
+	maxDepth := config.C.TaskQueue.MaxDepth
+	if maxDepth <= 0 {
+		maxDepth = defaultMaxQueueDepth
+	}
+	maxBatch := config.C.TaskQueue.MaxBatch
+	if maxBatch <= 0 {
+		maxBatch = defaultMaxBatch
 	}
-	logger.L.Info("✅ Task Manager initialized successfully!")
+	backend := config.C.TaskQueue.Backend
+	switch backend {
+	case "", TaskQueueBackendMemory:
+		backend = TaskQueueBackendMemory
+		TM = &TaskManager{
+			agentTaskQueues: make(map[string]*agentQueue),
+			maxDepth:        maxDepth,
+			maxBatch:        maxBatch,
+		}
+	case TaskQueueBackendPostgres:
+		TM = newPGTaskManager(maxDepth, maxBatch)
+	default:
+		logger.L.Fatalw("Unknown task queue backend", "backend", backend)
+	}
+	logger.L.Infow("✅ Task Manager initialized successfully!", "backend", backend, "max_queue_depth", maxDepth, "max_batch", maxBatch)
 }
 
-// getOrCreateAgentQueue 获取或创建一个 Agent 的任务通道
-func (tm *TaskManager) getOrCreateAgentQueue(agentID string) chan *Task {
+// acquireAgentQueue 获取或创建一个 Agent 的任务队列，并记录一次活动
+// waiting 为 true 表示调用方会在队列上长轮询，用完后必须调用 releaseAgentQueue
+// 调用方需要保证 agentID 对应一个已注册的 Agent，队列不会为未知的 agent_id 创建
+func (tm *TaskManager) acquireAgentQueue(agentID string, waiting bool) *agentQueue {
 	tm.mu.Lock()
 	defer tm.mu.Unlock()
 
 	queue, exists := tm.agentTaskQueues[agentID]
 	if !exists {
-		// 创建一个带缓冲的 channel, 防止任务发送阻塞
-		queue = make(chan *Task, 10)
+		queue = &agentQueue{notify: make(chan struct{}, 1)}
 		tm.agentTaskQueues[agentID] = queue
 		logger.L.Infow("Created new task queue for agent", "agent_id", agentID)
 	}
+	queue.lastActive = time.Now()
+	if waiting {
+		queue.waiters++
+	}
 	return queue
 }
 
+// releaseAgentQueue 结束一次长轮询
+func (tm *TaskManager) releaseAgentQueue(queue *agentQueue) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	queue.waiters--
+	queue.lastActive = time.Now()
+}
+
 // SubmitTask 向指定的 Agent 提交一个新任务
-func (tm *TaskManager) SubmitTask(task *Task) {
-	queue := tm.getOrCreateAgentQueue(task.AgentID)
-	logger.L.Infow("Submitting new task to queue", "agent_id", task.AgentID, "task_id", task.ID, "command", task.Command)
-	queue <- task
+// 该方法不会阻塞，队列已满时返回 ErrQueueFull，由调用方决定稍后重试还是放弃
+func (tm *TaskManager) SubmitTask(task *Task) error {
+	queue := tm.acquireAgentQueue(task.AgentID, false)
+	err := queue.push(task, tm.maxDepth)
+	if errors.Is(err, errQueueEvicted) {
+		// 队列恰好在获取之后被清理，重新创建一个
+		queue = tm.acquireAgentQueue(task.AgentID, false)
+		err = queue.push(task, tm.maxDepth)
+	}
+	if errors.Is(err, ErrQueueFull) {
+		queue.rejected.Add(1)
+		logger.L.Warnw("Task queue is full, task rejected", "agent_id", task.AgentID, "task_id", task.ID, "max_depth", tm.maxDepth)
+		return err
+	}
+	if err != nil {
+		logger.L.Errorw("Failed to submit task to queue", "agent_id", task.AgentID, "task_id", task.ID, "error", err)
+		return err
+	}
+	queue.submitted.Add(1)
+	logger.L.Infow("Submitting new task to queue", "agent_id", task.AgentID, "task_id", task.ID, "priority", task.Priority, "command", task.Command)
+	return nil
 }
 
-// GetTaskForAgent 为指定的 Agent 获取一个任务 (支持长轮询)
-func (tm *TaskManager) GetTaskForAgent(agentID string, timeout time.Duration) *Task {
-	queue := tm.getOrCreateAgentQueue(agentID)
+// MaxBatch 返回服务端允许的单次拉取任务数上限
+func (tm *TaskManager) MaxBatch() int {
+	return tm.maxBatch
+}
 
-	select {
-	case task := <-queue:
-		logger.L.Infow("Dispatched task to agent", "agent_id", agentID, "task_id", task.ID)
-		return task
-	case <-time.After(timeout):
-		// 超时，没有任务
+// GetTasksForAgent 为指定的 Agent 获取最多 limit 个可分发的任务 (支持长轮询)
+// 队列中没有可分发的任务时等待到第一个任务到达或超时；拿到第一个任务后不再等待，
+// 立即带上队列中其余已就绪的任务一起返回。limit 会被限制在 [1, MaxBatch()] 之间
+// 任务按优先级从高到低返回，只返回已到 NotBefore 的任务；遇到的过期任务不会分发，而是回报给所属工作流
+// ctx 结束后不再领取任务，避免已经断开的请求方拿走任务
+func (tm *TaskManager) GetTasksForAgent(ctx context.Context, agentID string, limit int, timeout time.Duration) []*Task {
+	limit = min(max(limit, 1), tm.maxBatch)
+
+	queue := tm.acquireAgentQueue(agentID, true)
+	defer tm.releaseAgentQueue(queue)
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	// readyTimer 在最早的延迟任务到期时唤醒，整个长轮询共用一个定时器
+	readyTimer := time.NewTimer(timeout)
+	readyTimer.Stop()
+	defer readyTimer.Stop()
+	for {
+		if ctx.Err() != nil {
+			return nil
+		}
+		var tasks []*Task
+		var wake time.Time
+		for len(tasks) < limit {
+			task, expired, next := queue.poll(time.Now())
+			if len(expired) > 0 {
+				queue.expired.Add(int64(len(expired)))
+				expireTasks(expired)
+			}
+			if task == nil {
+				wake = next
+				break
+			}
+			queue.dispatched.Add(1)
+			logger.L.Infow("Dispatched task to agent", "agent_id", agentID, "task_id", task.ID, "priority", task.Priority)
+			recordTaskDispatched(task)
+			tasks = append(tasks, task)
+		}
+		if len(tasks) > 0 {
+			return tasks
+		}
+
+		// 没有可分发的任务时，等待新任务、延迟任务到期或超时
+		var ready <-chan time.Time
+		if !wake.IsZero() {
+			readyTimer.Reset(time.Until(wake))
+			ready = readyTimer.C
+		}
+		select {
+		case <-queue.notify:
+		case <-ready:
+		case <-ctx.Done():
+			return nil
+		case <-timer.C:
+			// 超时，没有任务
+			return nil
+		}
+	}
+}
+
+// CancelTask 把还在排队的任务移出队列；任务已经分发时通知执行它的 Agent 终止执行
+// 单副本部署时所有持久连接都在本进程内，Agent 不在线时返回 CancelNotDelivered
+func (tm *TaskManager) CancelTask(agentID, taskID, reason string) CancelOutcome {
+	tm.mu.RLock()
+	queue, exists := tm.agentTaskQueues[agentID]
+	tm.mu.RUnlock()
+	if exists && queue.remove(taskID) {
+		return CancelRemoved
+	}
+	if deliverCancel(agentID, taskID, reason) {
+		return CancelDelivered
+	}
+	return CancelNotDelivered
+}
+
+// RemoveQueued 移出 Agent 队列中所有尚未分发的任务，按分发顺序返回；不会创建队列
+func (tm *TaskManager) RemoveQueued(agentID string) []*Task {
+	tm.mu.RLock()
+	queue, exists := tm.agentTaskQueues[agentID]
+	tm.mu.RUnlock()
+	if !exists {
+		return nil
+	}
+
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+	tasks := make([]*Task, 0, queue.lenLocked())
+	for level := len(queue.levels) - 1; level >= 0; level-- {
+		tasks = append(tasks, queue.levels[level]...)
+		queue.levels[level] = nil
+	}
+	return tasks
+}
+
+// RemoveExpired 移出所有 Agent 队列中已过期的任务并返回，调用方负责回报给所属工作流
+func (tm *TaskManager) RemoveExpired(now time.Time) []*Task {
+	tm.mu.RLock()
+	queues := make([]*agentQueue, 0, len(tm.agentTaskQueues))
+	for _, queue := range tm.agentTaskQueues {
+		queues = append(queues, queue)
+	}
+	tm.mu.RUnlock()
+
+	var expired []*Task
+	for _, queue := range queues {
+		queue.mu.Lock()
+		removed := queue.removeExpiredLocked(now)
+		queue.mu.Unlock()
+		queue.expired.Add(int64(len(removed)))
+		expired = append(expired, removed...)
+	}
+	return expired
+}
+
+// PreemptLowerPriority 把指定 Agent 队列中优先级低于 priority 的任务全部移出，并返回这些任务
+// 调用方负责处理被抢占任务所属的工作流；只查找已有的队列，不会创建队列或刷新活动时间
+func (tm *TaskManager) PreemptLowerPriority(agentID string, priority Priority) []*Task {
+	tm.mu.RLock()
+	queue, exists := tm.agentTaskQueues[agentID]
+	tm.mu.RUnlock()
+	if !exists {
 		return nil
 	}
+
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+	var preempted []*Task
+	for level := 0; level < priority.level(); level++ {
+		preempted = append(preempted, queue.levels[level]...)
+		queue.levels[level] = nil
+	}
+	return preempted
+}
+
+// QueueStats 返回所有 Agent 任务队列的统计信息，按 agent_id 排序
+func (tm *TaskManager) QueueStats() []QueueStats {
+	tm.mu.RLock()
+	agentIDs := make([]string, 0, len(tm.agentTaskQueues))
+	for agentID := range tm.agentTaskQueues {
+		agentIDs = append(agentIDs, agentID)
+	}
+	tm.mu.RUnlock()
+	sort.Strings(agentIDs)
+
+	stats := make([]QueueStats, 0, len(agentIDs))
+	for _, agentID := range agentIDs {
+		if s, ok := tm.AgentQueueStats(agentID); ok {
+			stats = append(stats, s)
+		}
+	}
+	return stats
+}
+
+// AgentQueueStats 返回单个 Agent 任务队列的统计信息，队列不存在时返回 false
+func (tm *TaskManager) AgentQueueStats(agentID string) (QueueStats, bool) {
+	tm.mu.RLock()
+	queue, exists := tm.agentTaskQueues[agentID]
+	var lastActive time.Time
+	var waiters int
+	if exists {
+		lastActive, waiters = queue.lastActive, queue.waiters
+	}
+	tm.mu.RUnlock()
+	if !exists {
+		return QueueStats{}, false
+	}
+
+	stats := QueueStats{
+		LastActiveAt:    lastActive,
+		Waiters:         waiters,
+		AgentID:         agentID,
+		MaxDepth:        tm.maxDepth,
+		DepthByPriority: make(map[string]int, numPriorities),
+		Submitted:       queue.submitted.Load(),
+		Dispatched:      queue.dispatched.Load(),
+		Rejected:        queue.rejected.Load(),
+		Expired:         queue.expired.Load(),
+	}
+	var oldest time.Time
+	for _, task := range queue.snapshot() {
+		stats.Depth++
+		stats.DepthByPriority[task.Priority.String()]++
+		if oldest.IsZero() || task.CreatedAt.Before(oldest) {
+			oldest = task.CreatedAt
+		}
+	}
+	if !oldest.IsZero() {
+		stats.OldestTaskAge = time.Since(oldest).Round(time.Second).String()
+	}
+	return stats, true
+}
+
+// QueuedTasks 按分发顺序返回 Agent 队列中的任务 (不会移出队列)
+func (tm *TaskManager) QueuedTasks(agentID string) []*Task {
+	tm.mu.RLock()
+	queue, exists := tm.agentTaskQueues[agentID]
+	tm.mu.RUnlock()
+	if !exists {
+		return nil
+	}
+	return queue.snapshot()
+}
+
+// push 把任务追加到对应优先级的队尾，队列已满时返回 ErrQueueFull
+func (q *agentQueue) push(task *Task, maxDepth int) error {
+	q.mu.Lock()
+	if q.evicted {
+		q.mu.Unlock()
+		return errQueueEvicted
+	}
+	if q.lenLocked() >= maxDepth {
+		q.mu.Unlock()
+		return fmt.Errorf("%w (max depth %d)", ErrQueueFull, maxDepth)
+	}
+	level := task.Priority.level()
+	q.levels[level] = append(q.levels[level], task)
+	q.mu.Unlock()
+
+	// 非阻塞地唤醒一个等待者，已有未消费的通知时无需重复发送
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// poll 按优先级从高到低取出一个可分发的任务，没有时 task 为 nil
+// 已过期的任务会被移出并通过 expired 返回；还没到 NotBefore 的任务留在队列中，
+// wake 是其中最早的 NotBefore，没有这样的任务时为零值
+func (q *agentQueue) poll(now time.Time) (task *Task, expired []*Task, wake time.Time) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	expired = q.removeExpiredLocked(now)
+	for level := len(q.levels) - 1; level >= 0; level-- {
+		tasks := q.levels[level]
+		for i, candidate := range tasks {
+			if candidate.NotBefore != nil && now.Before(*candidate.NotBefore) {
+				if wake.IsZero() || candidate.NotBefore.Before(wake) {
+					wake = *candidate.NotBefore
+				}
+				continue
+			}
+			copy(tasks[i:], tasks[i+1:])
+			tasks[len(tasks)-1] = nil // 避免底层数组继续引用已分发的任务
+			q.levels[level] = tasks[:len(tasks)-1]
+			return candidate, expired, time.Time{}
+		}
+	}
+	return nil, expired, wake
+}
+
+// remove 把指定的任务移出队列，任务不在队列中时返回 false
+func (q *agentQueue) remove(taskID string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for level, tasks := range q.levels {
+		for i, task := range tasks {
+			if task.ID != taskID {
+				continue
+			}
+			copy(tasks[i:], tasks[i+1:])
+			tasks[len(tasks)-1] = nil
+			q.levels[level] = tasks[:len(tasks)-1]
+			return true
+		}
+	}
+	return false
+}
+
+// removeExpiredLocked 移出并返回已过期的任务，调用方需持有 q.mu
+func (q *agentQueue) removeExpiredLocked(now time.Time) []*Task {
+	var expired []*Task
+	for level, tasks := range q.levels {
+		kept := tasks[:0]
+		for _, task := range tasks {
+			if task.ExpiresAt != nil && !now.Before(*task.ExpiresAt) {
+				expired = append(expired, task)
+				continue
+			}
+			kept = append(kept, task)
+		}
+		for i := len(kept); i < len(tasks); i++ {
+			tasks[i] = nil
+		}
+		q.levels[level] = kept
+	}
+	return expired
 }
 
-// TODO: 添加一个清理不活跃 Agent 队列的逻辑 (用于生产环境)
+// snapshot 按分发顺序 (高优先级在前) 返回队列中任务的副本
+func (q *agentQueue) snapshot() []*Task {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	tasks := make([]*Task, 0, q.lenLocked())
+	for level := len(q.levels) - 1; level >= 0; level-- {
+		tasks = append(tasks, q.levels[level]...)
+	}
+	return tasks
+}
+
+func (q *agentQueue) lenLocked() int {
+	n := 0
+	for _, level := range q.levels {
+		n += len(level)
+	}
+	return n
+}
+
+// EvictQueues 清理不再需要的 Agent 队列，并返回被清理队列中尚未分发的任务
+// keep 返回 false 的 Agent (例如已注销) 的队列会被立即清理；
+// 其余队列只在为空、没有长轮询、且超过 idleTimeout 没有任何活动时清理，
+// 已注册 Agent 短暂离线期间排队的任务留在队列中，到期后由过期机制回报给工作流
+func (tm *TaskManager) EvictQueues(idleTimeout time.Duration, keep func(agentID string) bool) map[string][]*Task {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	pending := make(map[string][]*Task)
+	now := time.Now()
+	for agentID, queue := range tm.agentTaskQueues {
+		if queue.waiters > 0 {
+			continue
+		}
+		registered := keep(agentID)
+		if registered && now.Sub(queue.lastActive) < idleTimeout {
+			continue
+		}
+
+		// 在队列锁内判断并标记，避免判断之后又有任务提交到即将被清理的队列
+		queue.mu.Lock()
+		if registered && queue.lenLocked() > 0 {
+			queue.mu.Unlock()
+			continue
+		}
+		queue.evicted = true
+		tasks := make([]*Task, 0, queue.lenLocked())
+		for level := len(queue.levels) - 1; level >= 0; level-- {
+			tasks = append(tasks, queue.levels[level]...)
+			queue.levels[level] = nil
+		}
+		queue.mu.Unlock()
+		delete(tm.agentTaskQueues, agentID)
+
+		logger.L.Infow("Evicted task queue", "agent_id", agentID, "registered", registered, "pending_tasks", len(tasks))
+		if len(tasks) > 0 {
+			pending[agentID] = tasks
+		}
+	}
+	return pending
+}
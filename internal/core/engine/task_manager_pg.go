@@ -0,0 +1,568 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/logger"
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/model"
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/store"
+	"github.com/jackc/pgx/v5"
+	"gorm.io/gorm"
+)
+
+const (
+	// taskReadyChannel 是 "某个 Agent 有新任务" 的 Postgres 通知通道，payload 为 agent_id
+	taskReadyChannel = "pioneer_task_ready"
+	// taskCancelChannel 是取消已分发任务的通知通道，payload 为 cancelNotice 的 JSON
+	// Agent 的持久连接可能在任意副本上，收到通知的副本各自检查 Agent 是否连接在自己这里
+	taskCancelChannel = "pioneer_task_cancel"
+)
+
+// cancelNotice 是通过 Postgres 广播的取消通知
+type cancelNotice struct {
+	AgentID string `json:"agent_id"`
+	TaskID  string `json:"task_id"`
+	Reason  string `json:"reason"`
+}
+
+const (
+	// pgFallbackPollInterval 是长轮询在没有收到通知时重新查询的间隔，防止监听连接重连期间丢失的通知让任务一直等到超时
+	pgFallbackPollInterval = 5 * time.Second
+	// pgListenRetryInterval 是监听连接断开后重连的间隔
+	pgListenRetryInterval = 5 * time.Second
+)
+
+// pgTaskManager 是基于 Postgres 的 Dispatcher 实现 (task_queue.backend = postgres)
+// 排队中的任务保存在 queued_tasks 表中，所有后端副本共享同一组队列:
+//   - 提交任务的副本在同一个事务中写入任务并 NOTIFY，所有副本收到通知后唤醒本地等待该 Agent 的长轮询
+//   - 领取任务使用 FOR UPDATE SKIP LOCKED，同一个任务只会被一个副本分发
+//   - 同一 Agent 的提交通过事务级 advisory lock 串行化，多个副本同时提交也不会超过 max_depth
+type pgTaskManager struct {
+	maxDepth int
+	maxBatch int
+
+	mu      sync.Mutex
+	waiters map[string]map[chan struct{}]struct{} // 本副本上正在长轮询的请求，按 agent_id 分组
+}
+
+func newPGTaskManager(maxDepth, maxBatch int) *pgTaskManager {
+	tm := &pgTaskManager{
+		maxDepth: maxDepth,
+		maxBatch: maxBatch,
+		waiters:  make(map[string]map[chan struct{}]struct{}),
+	}
+	go tm.listen()
+	return tm
+}
+
+// listen 持续监听任务通知，连接断开后自动重连
+func (tm *pgTaskManager) listen() {
+	for {
+		err := tm.listenOnce(context.Background())
+		logger.L.Errorw("Task notification listener disconnected, reconnecting", "channel", taskReadyChannel, "error", err)
+		// 重连期间可能错过通知，让所有长轮询重新查询一次
+		tm.wakeAll()
+		time.Sleep(pgListenRetryInterval)
+	}
+}
+
+func (tm *pgTaskManager) listenOnce(ctx context.Context) error {
+	// LISTEN 需要独占一个连接，不能使用 GORM 的连接池
+	conn, err := pgx.Connect(ctx, store.PostgresDSN())
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+
+	for _, channel := range []string{taskReadyChannel, taskCancelChannel} {
+		if _, err := conn.Exec(ctx, "LISTEN "+channel); err != nil {
+			return err
+		}
+	}
+	logger.L.Infow("Listening for task notifications", "channels", []string{taskReadyChannel, taskCancelChannel})
+	tm.wakeAll()
+
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		switch notification.Channel {
+		case taskReadyChannel:
+			tm.wake(notification.Payload)
+		case taskCancelChannel:
+			var notice cancelNotice
+			if err := json.Unmarshal([]byte(notification.Payload), &notice); err != nil {
+				logger.L.Errorw("Invalid task cancel notification", "payload", notification.Payload, "error", err)
+				continue
+			}
+			deliverCancel(notice.AgentID, notice.TaskID, notice.Reason)
+		}
+	}
+}
+
+// addWaiter 登记一个本地长轮询，返回的 channel 在该 Agent 有新任务时收到通知
+func (tm *pgTaskManager) addWaiter(agentID string) chan struct{} {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	notify := make(chan struct{}, 1)
+	if tm.waiters[agentID] == nil {
+		tm.waiters[agentID] = make(map[chan struct{}]struct{})
+	}
+	tm.waiters[agentID][notify] = struct{}{}
+	return notify
+}
+
+func (tm *pgTaskManager) removeWaiter(agentID string, notify chan struct{}) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	delete(tm.waiters[agentID], notify)
+	if len(tm.waiters[agentID]) == 0 {
+		delete(tm.waiters, agentID)
+	}
+}
+
+// wake 非阻塞地唤醒本副本上等待该 Agent 的所有长轮询
+func (tm *pgTaskManager) wake(agentID string) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	for notify := range tm.waiters[agentID] {
+		select {
+		case notify <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (tm *pgTaskManager) wakeAll() {
+	tm.mu.Lock()
+	agentIDs := make([]string, 0, len(tm.waiters))
+	for agentID := range tm.waiters {
+		agentIDs = append(agentIDs, agentID)
+	}
+	tm.mu.Unlock()
+	for _, agentID := range agentIDs {
+		tm.wake(agentID)
+	}
+}
+
+func (tm *pgTaskManager) localWaiters(agentID string) int {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	return len(tm.waiters[agentID])
+}
+
+// SubmitTask 把任务写入 queued_tasks 并通知所有副本
+func (tm *pgTaskManager) SubmitTask(task *Task) error {
+	payload, err := json.Marshal(task)
+	if err != nil {
+		return err
+	}
+
+	err = store.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockAgentQueue(tx, task.AgentID); err != nil {
+			return err
+		}
+		var depth int64
+		if err := tx.Model(&model.QueuedTask{}).Where("agent_id = ?", task.AgentID).Count(&depth).Error; err != nil {
+			return err
+		}
+		if depth >= int64(tm.maxDepth) {
+			return fmt.Errorf("%w (max depth %d)", ErrQueueFull, tm.maxDepth)
+		}
+		if err := tx.Create(&model.QueuedTask{
+			ID:        task.ID,
+			AgentID:   task.AgentID,
+			Priority:  int(task.Priority),
+			NotBefore: task.NotBefore,
+			ExpiresAt: task.ExpiresAt,
+			Payload:   string(payload),
+			CreatedAt: task.CreatedAt,
+		}).Error; err != nil {
+			return err
+		}
+		if err := touchQueueState(tx, task.AgentID, "submitted", 1); err != nil {
+			return err
+		}
+		// NOTIFY 在事务提交后才会送达，收到通知的副本一定能查到这个任务
+		return tx.Exec("SELECT pg_notify(?, ?)", taskReadyChannel, task.AgentID).Error
+	})
+	if errors.Is(err, ErrQueueFull) {
+		if err := addQueueCounter(store.DB, task.AgentID, "rejected", 1); err != nil {
+			logger.L.Errorw("Failed to update task queue state", "agent_id", task.AgentID, "error", err)
+		}
+		logger.L.Warnw("Task queue is full, task rejected", "agent_id", task.AgentID, "task_id", task.ID, "max_depth", tm.maxDepth)
+		return err
+	}
+	if err != nil {
+		logger.L.Errorw("Failed to submit task to queue", "agent_id", task.AgentID, "task_id", task.ID, "error", err)
+		return err
+	}
+	logger.L.Infow("Submitting new task to queue", "agent_id", task.AgentID, "task_id", task.ID, "priority", task.Priority, "command", task.Command)
+	return nil
+}
+
+// GetTasksForAgent 为指定的 Agent 获取最多 limit 个可分发的任务 (支持长轮询)
+// 语义与进程内实现一致: 没有可分发的任务时等待到有任务或超时，过期任务回报给所属工作流，ctx 结束后不再领取任务
+func (tm *pgTaskManager) GetTasksForAgent(ctx context.Context, agentID string, limit int, timeout time.Duration) []*Task {
+	limit = min(max(limit, 1), tm.maxBatch)
+
+	notify := tm.addWaiter(agentID)
+	defer tm.removeWaiter(agentID, notify)
+	tm.touch(agentID)
+	defer tm.touch(agentID)
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	// retryTimer 用于延迟任务到期和定期重新查询，整个长轮询共用一个定时器
+	retryTimer := time.NewTimer(pgFallbackPollInterval)
+	retryTimer.Stop()
+	defer retryTimer.Stop()
+	for {
+		if ctx.Err() != nil {
+			return nil
+		}
+		tasks, wake, err := tm.claim(agentID, limit, time.Now())
+		if err != nil {
+			logger.L.Errorw("Failed to claim tasks", "agent_id", agentID, "error", err)
+		}
+		if len(tasks) > 0 {
+			for _, task := range tasks {
+				logger.L.Infow("Dispatched task to agent", "agent_id", agentID, "task_id", task.ID, "priority", task.Priority)
+				recordTaskDispatched(task)
+			}
+			return tasks
+		}
+
+		// 没有可分发的任务时，等待通知、延迟任务到期或超时，同时定期重新查询以防丢失通知
+		wait := pgFallbackPollInterval
+		if !wake.IsZero() {
+			wait = min(wait, max(time.Until(wake), 0))
+		}
+		retryTimer.Reset(wait)
+		select {
+		case <-notify:
+		case <-retryTimer.C:
+		case <-ctx.Done():
+			return nil
+		case <-timer.C:
+			// 超时，没有任务
+			return nil
+		}
+	}
+}
+
+// claim 在一个事务中移出该 Agent 已过期的任务，并领取最多 limit 个已就绪的任务
+// 没有领取到任务时，wake 是最早的 NotBefore (没有延迟任务时为零值)
+func (tm *pgTaskManager) claim(agentID string, limit int, now time.Time) (tasks []*Task, wake time.Time, err error) {
+	var claimed, expired []model.QueuedTask
+	err = store.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Raw("DELETE FROM queued_tasks WHERE agent_id = ? AND expires_at <= ? RETURNING *", agentID, now).
+			Scan(&expired).Error; err != nil {
+			return err
+		}
+		if err := tx.Raw(`DELETE FROM queued_tasks WHERE id IN (
+				SELECT id FROM queued_tasks
+				WHERE agent_id = ? AND (not_before IS NULL OR not_before <= ?)
+				ORDER BY priority DESC, created_at, id
+				LIMIT ? FOR UPDATE SKIP LOCKED
+			) RETURNING *`, agentID, now, limit).
+			Scan(&claimed).Error; err != nil {
+			return err
+		}
+		if len(expired) > 0 {
+			if err := addQueueCounter(tx, agentID, "expired", len(expired)); err != nil {
+				return err
+			}
+		}
+		if len(claimed) > 0 {
+			return touchQueueState(tx, agentID, "dispatched", len(claimed))
+		}
+		var next *time.Time
+		if err := tx.Raw("SELECT min(not_before) FROM queued_tasks WHERE agent_id = ? AND not_before > ?", agentID, now).
+			Scan(&next).Error; err != nil {
+			return err
+		}
+		if next != nil {
+			wake = *next
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	if len(expired) > 0 {
+		expireTasks(decodeQueuedTasks(expired))
+	}
+	// RETURNING 不保证顺序，按分发顺序重新排序
+	sortQueuedTasks(claimed)
+	return decodeQueuedTasks(claimed), wake, nil
+}
+
+// MaxBatch 返回服务端允许的单次拉取任务数上限
+func (tm *pgTaskManager) MaxBatch() int {
+	return tm.maxBatch
+}
+
+// PreemptLowerPriority 把指定 Agent 队列中优先级低于 priority 的任务全部移出，并返回这些任务
+func (tm *pgTaskManager) PreemptLowerPriority(agentID string, priority Priority) []*Task {
+	var preempted []model.QueuedTask
+	if err := store.DB.Raw("DELETE FROM queued_tasks WHERE agent_id = ? AND priority < ? RETURNING *", agentID, int(priority)).
+		Scan(&preempted).Error; err != nil {
+		logger.L.Errorw("Failed to preempt queued tasks", "agent_id", agentID, "error", err)
+		return nil
+	}
+	sortQueuedTasks(preempted)
+	return decodeQueuedTasks(preempted)
+}
+
+// CancelTask 把还在排队的任务移出队列；任务已经分发时，Agent 连接在本副本上则直接通知，否则广播给其他副本
+func (tm *pgTaskManager) CancelTask(agentID, taskID, reason string) CancelOutcome {
+	result := store.DB.Where("id = ? AND agent_id = ?", taskID, agentID).Delete(&model.QueuedTask{})
+	if result.Error != nil {
+		logger.L.Errorw("Failed to remove cancelled task from queue", "agent_id", agentID, "task_id", taskID, "error", result.Error)
+	} else if result.RowsAffected > 0 {
+		return CancelRemoved
+	}
+	if deliverCancel(agentID, taskID, reason) {
+		return CancelDelivered
+	}
+
+	payload, _ := json.Marshal(cancelNotice{AgentID: agentID, TaskID: taskID, Reason: reason})
+	if err := store.DB.Exec("SELECT pg_notify(?, ?)", taskCancelChannel, string(payload)).Error; err != nil {
+		logger.L.Errorw("Failed to broadcast task cancellation", "agent_id", agentID, "task_id", taskID, "error", err)
+		return CancelNotDelivered
+	}
+	return CancelBroadcast
+}
+
+// RemoveQueued 移出 Agent 队列中所有尚未分发的任务，按分发顺序返回
+func (tm *pgTaskManager) RemoveQueued(agentID string) []*Task {
+	var removed []model.QueuedTask
+	if err := store.DB.Raw("DELETE FROM queued_tasks WHERE agent_id = ? RETURNING *", agentID).Scan(&removed).Error; err != nil {
+		logger.L.Errorw("Failed to remove queued tasks", "agent_id", agentID, "error", err)
+		return nil
+	}
+	sortQueuedTasks(removed)
+	return decodeQueuedTasks(removed)
+}
+
+// RemoveExpired 移出所有 Agent 队列中已过期的任务并返回
+func (tm *pgTaskManager) RemoveExpired(now time.Time) []*Task {
+	var expired []model.QueuedTask
+	err := store.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Raw("DELETE FROM queued_tasks WHERE expires_at <= ? RETURNING *", now).Scan(&expired).Error; err != nil {
+			return err
+		}
+		counts := make(map[string]int)
+		for _, row := range expired {
+			counts[row.AgentID]++
+		}
+		for agentID, n := range counts {
+			if err := addQueueCounter(tx, agentID, "expired", n); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		logger.L.Errorw("Failed to remove expired tasks", "error", err)
+		return nil
+	}
+	return decodeQueuedTasks(expired)
+}
+
+// EvictQueues 清理不再需要的 Agent 队列，并返回被清理队列中尚未分发的任务
+// 清理条件与进程内实现一致；长轮询开始和结束时都会刷新活动时间，正在轮询的队列不会超过 idleTimeout
+func (tm *pgTaskManager) EvictQueues(idleTimeout time.Duration, keep func(agentID string) bool) map[string][]*Task {
+	var states []model.TaskQueueState
+	if err := store.DB.Find(&states).Error; err != nil {
+		logger.L.Errorw("Failed to load task queue states", "error", err)
+		return nil
+	}
+
+	pending := make(map[string][]*Task)
+	now := time.Now()
+	for _, state := range states {
+		registered := keep(state.AgentID)
+		if registered && now.Sub(state.LastActiveAt) < idleTimeout {
+			continue
+		}
+
+		var tasks []model.QueuedTask
+		evicted := false
+		err := store.DB.Transaction(func(tx *gorm.DB) error {
+			if err := lockAgentQueue(tx, state.AgentID); err != nil {
+				return err
+			}
+			// 已注册 Agent 的队列中还有任务时不清理
+			if registered {
+				var depth int64
+				if err := tx.Model(&model.QueuedTask{}).Where("agent_id = ?", state.AgentID).Count(&depth).Error; err != nil || depth > 0 {
+					return err
+				}
+			}
+			// 读取之后队列又有了活动 (其他副本提交或拉取了任务) 时不清理
+			result := tx.Where("agent_id = ? AND last_active_at = ?", state.AgentID, state.LastActiveAt).Delete(&model.TaskQueueState{})
+			if result.Error != nil || result.RowsAffected == 0 {
+				return result.Error
+			}
+			evicted = true
+			return tx.Raw("DELETE FROM queued_tasks WHERE agent_id = ? RETURNING *", state.AgentID).Scan(&tasks).Error
+		})
+		if err != nil {
+			logger.L.Errorw("Failed to evict task queue", "agent_id", state.AgentID, "error", err)
+			continue
+		}
+		if !evicted {
+			continue
+		}
+
+		logger.L.Infow("Evicted task queue", "agent_id", state.AgentID, "registered", registered, "pending_tasks", len(tasks))
+		if len(tasks) > 0 {
+			sortQueuedTasks(tasks)
+			pending[state.AgentID] = decodeQueuedTasks(tasks)
+		}
+	}
+	return pending
+}
+
+// QueueStats 返回所有 Agent 任务队列的统计信息，按 agent_id 排序
+func (tm *pgTaskManager) QueueStats() []QueueStats {
+	var states []model.TaskQueueState
+	if err := store.DB.Order("agent_id asc").Find(&states).Error; err != nil {
+		logger.L.Errorw("Failed to load task queue states", "error", err)
+		return []QueueStats{}
+	}
+	stats := make([]QueueStats, 0, len(states))
+	for _, state := range states {
+		stats = append(stats, tm.stats(state))
+	}
+	return stats
+}
+
+// AgentQueueStats 返回单个 Agent 任务队列的统计信息，队列不存在时返回 false
+func (tm *pgTaskManager) AgentQueueStats(agentID string) (QueueStats, bool) {
+	var state model.TaskQueueState
+	if err := store.DB.Where("agent_id = ?", agentID).First(&state).Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			logger.L.Errorw("Failed to load task queue state", "agent_id", agentID, "error", err)
+		}
+		return QueueStats{}, false
+	}
+	return tm.stats(state), true
+}
+
+// stats 汇总队列状态和排队中的任务，Waiters 只统计当前副本上的长轮询
+func (tm *pgTaskManager) stats(state model.TaskQueueState) QueueStats {
+	stats := QueueStats{
+		AgentID:         state.AgentID,
+		MaxDepth:        tm.maxDepth,
+		DepthByPriority: make(map[string]int, numPriorities),
+		Submitted:       state.Submitted,
+		Dispatched:      state.Dispatched,
+		Rejected:        state.Rejected,
+		Expired:         state.Expired,
+		LastActiveAt:    state.LastActiveAt,
+		Waiters:         tm.localWaiters(state.AgentID),
+	}
+
+	var rows []struct {
+		Priority int
+		Count    int
+		Oldest   time.Time
+	}
+	if err := store.DB.Model(&model.QueuedTask{}).
+		Select("priority, count(*) AS count, min(created_at) AS oldest").
+		Where("agent_id = ?", state.AgentID).
+		Group("priority").
+		Scan(&rows).Error; err != nil {
+		logger.L.Errorw("Failed to count queued tasks", "agent_id", state.AgentID, "error", err)
+		return stats
+	}
+	var oldest time.Time
+	for _, row := range rows {
+		stats.Depth += row.Count
+		stats.DepthByPriority[Priority(row.Priority).String()] += row.Count
+		if oldest.IsZero() || row.Oldest.Before(oldest) {
+			oldest = row.Oldest
+		}
+	}
+	if !oldest.IsZero() {
+		stats.OldestTaskAge = time.Since(oldest).Round(time.Second).String()
+	}
+	return stats
+}
+
+// QueuedTasks 按分发顺序返回 Agent 队列中的任务 (不会移出队列)
+func (tm *pgTaskManager) QueuedTasks(agentID string) []*Task {
+	var rows []model.QueuedTask
+	if err := store.DB.Where("agent_id = ?", agentID).Order("priority desc, created_at, id").Find(&rows).Error; err != nil {
+		logger.L.Errorw("Failed to list queued tasks", "agent_id", agentID, "error", err)
+		return nil
+	}
+	return decodeQueuedTasks(rows)
+}
+
+// touch 刷新队列的最近活动时间，队列不存在时创建
+func (tm *pgTaskManager) touch(agentID string) {
+	if err := touchQueueState(store.DB, agentID, "", 0); err != nil {
+		logger.L.Errorw("Failed to update task queue state", "agent_id", agentID, "error", err)
+	}
+}
+
+// lockAgentQueue 在事务内串行化对同一 Agent 队列的修改，锁在事务结束时自动释放
+func lockAgentQueue(tx *gorm.DB, agentID string) error {
+	return tx.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", "task_queue:"+agentID).Error
+}
+
+// touchQueueState 刷新队列的最近活动时间，counter 不为空时同时累加对应的统计列
+func touchQueueState(db *gorm.DB, agentID, counter string, n int) error {
+	if counter == "" {
+		return db.Exec(`INSERT INTO task_queue_states (agent_id, last_active_at) VALUES (?, ?)
+			ON CONFLICT (agent_id) DO UPDATE SET last_active_at = EXCLUDED.last_active_at`, agentID, time.Now()).Error
+	}
+	return db.Exec(fmt.Sprintf(`INSERT INTO task_queue_states (agent_id, %[1]s, last_active_at) VALUES (?, ?, ?)
+		ON CONFLICT (agent_id) DO UPDATE SET %[1]s = task_queue_states.%[1]s + EXCLUDED.%[1]s, last_active_at = EXCLUDED.last_active_at`, counter),
+		agentID, n, time.Now()).Error
+}
+
+// addQueueCounter 累加统计列，不算作队列活动 (例如被拒绝或过期的任务)
+func addQueueCounter(db *gorm.DB, agentID, counter string, n int) error {
+	return db.Model(&model.TaskQueueState{}).Where("agent_id = ?", agentID).
+		UpdateColumn(counter, gorm.Expr(counter+" + ?", n)).Error
+}
+
+// sortQueuedTasks 按分发顺序 (优先级从高到低，同一优先级先进先出) 排序
+func sortQueuedTasks(rows []model.QueuedTask) {
+	sort.SliceStable(rows, func(i, j int) bool {
+		if rows[i].Priority != rows[j].Priority {
+			return rows[i].Priority > rows[j].Priority
+		}
+		if !rows[i].CreatedAt.Equal(rows[j].CreatedAt) {
+			return rows[i].CreatedAt.Before(rows[j].CreatedAt)
+		}
+		return rows[i].ID < rows[j].ID
+	})
+}
+
+// decodeQueuedTasks 把 queued_tasks 的记录还原为任务，无法解析的记录会被记录日志后跳过
+func decodeQueuedTasks(rows []model.QueuedTask) []*Task {
+	tasks := make([]*Task, 0, len(rows))
+	for _, row := range rows {
+		var task Task
+		if err := json.Unmarshal([]byte(row.Payload), &task); err != nil {
+			logger.L.Errorw("Failed to decode queued task", "task_id", row.ID, "agent_id", row.AgentID, "error", err)
+			continue
+		}
+		tasks = append(tasks, &task)
+	}
+	return tasks
+}
@@ -0,0 +1,135 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/model"
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/store"
+	"gorm.io/gorm/clause"
+)
+
+// 任务输出的来源
+const (
+	OutputStdout = "stdout"
+	OutputStderr = "stderr"
+)
+
+// maxOutputChunkSize 是单段输出的最大字节数，Agent 应该把更长的输出拆成多段
+const maxOutputChunkSize = 64 * 1024
+
+// ErrTaskNotOwned 表示 Agent 上报了不属于它的任务的输出
+var ErrTaskNotOwned = errors.New("task does not belong to this agent")
+
+// TaskOutputChunk 是 Agent 在任务执行过程中上报的一段输出
+type TaskOutputChunk struct {
+	TaskID string `json:"task_id"`
+	Seq    int    `json:"seq"`    // 从 0 开始递增，同一任务的 stdout 和 stderr 共用一个序列
+	Stream string `json:"stream"` // "stdout" 或 "stderr"
+	Data   []byte `json:"data"`
+}
+
+// outputWatchers 是本副本上正在实时查看任务输出的订阅者，按任务 ID 分组
+// 多副本部署时，写入其他副本的输出由订阅方定期查询数据库获得
+var outputWatchers = struct {
+	sync.Mutex
+	m map[string]map[chan struct{}]struct{}
+}{m: make(map[string]map[chan struct{}]struct{})}
+
+// RecordTaskOutput 保存 Agent 上报的任务输出并通知正在查看的订阅者
+// 重复上报的分段 (相同的任务 ID 和序号) 会被忽略
+func RecordTaskOutput(agentID string, chunks []TaskOutputChunk) error {
+	if len(chunks) == 0 {
+		return nil
+	}
+
+	taskIDs := make(map[string]bool)
+	for _, chunk := range chunks {
+		if chunk.TaskID == "" || chunk.Seq < 0 {
+			return fmt.Errorf("invalid output chunk: task_id=%q seq=%d", chunk.TaskID, chunk.Seq)
+		}
+		if chunk.Stream != OutputStdout && chunk.Stream != OutputStderr {
+			return fmt.Errorf("invalid output stream %q", chunk.Stream)
+		}
+		if len(chunk.Data) > maxOutputChunkSize {
+			return fmt.Errorf("output chunk exceeds %d bytes", maxOutputChunkSize)
+		}
+		taskIDs[chunk.TaskID] = true
+	}
+
+	ids := make([]string, 0, len(taskIDs))
+	for taskID := range taskIDs {
+		ids = append(ids, taskID)
+	}
+	var count int64
+	if err := store.DB.Model(&model.WorkflowTask{}).Where("id IN ? AND agent_id = ?", ids, agentID).Count(&count).Error; err != nil {
+		return err
+	}
+	if int(count) != len(ids) {
+		return ErrTaskNotOwned
+	}
+
+	records := make([]model.TaskOutputChunk, 0, len(chunks))
+	for _, chunk := range chunks {
+		records = append(records, model.TaskOutputChunk{
+			TaskID: chunk.TaskID,
+			Seq:    chunk.Seq,
+			Stream: chunk.Stream,
+			Data:   chunk.Data,
+		})
+	}
+	if err := store.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&records).Error; err != nil {
+		return err
+	}
+
+	outputWatchers.Lock()
+	for taskID := range taskIDs {
+		for ch := range outputWatchers.m[taskID] {
+			select {
+			case ch <- struct{}{}:
+			default:
+			}
+		}
+	}
+	outputWatchers.Unlock()
+	return nil
+}
+
+// WatchTaskOutput 订阅一个任务的新输出，有新输出写入本副本时 channel 收到通知
+// 调用方用完后必须调用返回的 stop
+func WatchTaskOutput(taskID string) (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+	outputWatchers.Lock()
+	if outputWatchers.m[taskID] == nil {
+		outputWatchers.m[taskID] = make(map[chan struct{}]struct{})
+	}
+	outputWatchers.m[taskID][ch] = struct{}{}
+	outputWatchers.Unlock()
+
+	stop := func() {
+		outputWatchers.Lock()
+		delete(outputWatchers.m[taskID], ch)
+		if len(outputWatchers.m[taskID]) == 0 {
+			delete(outputWatchers.m, taskID)
+		}
+		outputWatchers.Unlock()
+	}
+	return ch, stop
+}
+
+// TaskOutputSince 按序号返回任务在 afterSeq 之后的输出，最多 limit 段
+func TaskOutputSince(taskID string, afterSeq, limit int) ([]model.TaskOutputChunk, error) {
+	var chunks []model.TaskOutputChunk
+	err := store.DB.Where("task_id = ? AND seq > ?", taskID, afterSeq).Order("seq").Limit(limit).Find(&chunks).Error
+	return chunks, err
+}
+
+// IsTaskFinished 判断任务记录是否已经结束，结束后不会再有新的输出
+func IsTaskFinished(status string) bool {
+	switch status {
+	case TaskStatusSucceeded, TaskStatusFailed, TaskStatusCancelled, TaskStatusExpired, TaskStatusTimedOut, TaskStatusRejected:
+		return true
+	}
+	return false
+}
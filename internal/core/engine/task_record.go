@@ -0,0 +1,170 @@
+package engine
+
+import (
+	"errors"
+	"time"
+
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/logger"
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/model"
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/store"
+)
+
+// 任务记录的状态
+const (
+	TaskStatusQueued     = "queued"
+	TaskStatusDispatched = "dispatched"
+	TaskStatusSucceeded  = "succeeded"
+	TaskStatusFailed     = "failed"
+	TaskStatusCancelled  = "cancelled"
+	TaskStatusExpired    = "expired"
+	TaskStatusTimedOut   = "timed_out"
+	TaskStatusRejected   = "rejected"
+)
+
+// submitTask 记录任务后提交到 Agent 的任务队列，引擎内部下发任务都应该经过这里
+// 队列已满时任务记录会被标记为取消，工作流被推迟，稍后由调度器重新提交
+// 目标 Agent 不支持任务的执行方式，或任务引用的脚本或文件无法加载时，任务记录被标记为取消，工作流失败
+func submitTask(workflow *model.Workflow, task *Task) error {
+	if err := checkAgentCapability(task); err != nil {
+		recordTaskQueued(task)
+		recordTaskCancelled(task.ID, err.Error())
+		transitionWorkflow(workflow, StatusFailed, "cannot route "+task.Type+" step: "+err.Error(), nil)
+		return err
+	}
+	if err := resolveTaskInputs(task); err != nil {
+		recordTaskQueued(task)
+		recordTaskCancelled(task.ID, err.Error())
+		transitionWorkflow(workflow, StatusFailed, "cannot prepare "+task.Type+" step: "+err.Error(), nil)
+		return err
+	}
+
+	recordTaskQueued(task)
+	err := TM.SubmitTask(task)
+	if err == nil {
+		return nil
+	}
+
+	recordTaskCancelled(task.ID, err.Error())
+	if errors.Is(err, ErrQueueFull) {
+		deferForBackpressure(workflow, err)
+	}
+	return err
+}
+
+// resolveTaskInputs 加载任务引用的服务端内容 (脚本、文件)
+func resolveTaskInputs(task *Task) error {
+	if err := resolveTaskScript(task); err != nil {
+		return err
+	}
+	return resolveTaskFile(task)
+}
+
+// recordTaskQueued 持久化一个刚进入队列的任务
+// 任务记录只用于追溯和报告，写入失败不影响工作流的执行
+func recordTaskQueued(task *Task) {
+	record := &model.WorkflowTask{
+		ID:         task.ID,
+		WorkflowID: task.WorkflowID,
+		AgentID:    task.AgentID,
+		Type:       task.Type,
+		Kind:       task.Kind,
+		Command:    task.Command,
+		ScriptName: task.ScriptName,
+		Action:     task.Action,
+		FileID:     taskFileID(task),
+		Priority:   int(task.Priority),
+		Timeout:    task.Timeout,
+		Status:     TaskStatusQueued,
+		CreatedAt:  task.CreatedAt,
+		NotBefore:  task.NotBefore,
+		ExpiresAt:  task.ExpiresAt,
+	}
+	if err := store.DB.Create(record).Error; err != nil {
+		logger.L.Errorw("Failed to record queued task", "task_id", task.ID, "error", err)
+	}
+}
+
+// recordTaskDispatched 记录任务被 Agent 领取的时间
+func recordTaskDispatched(task *Task) {
+	updateData := map[string]interface{}{
+		"status":        TaskStatusDispatched,
+		"dispatched_at": time.Now(),
+	}
+	if err := store.DB.Model(&model.WorkflowTask{}).Where("id = ?", task.ID).Updates(updateData).Error; err != nil {
+		logger.L.Errorw("Failed to record dispatched task", "task_id", task.ID, "error", err)
+	}
+}
+
+// recordTaskResult 记录 Agent 上报的执行结果，或调度方上报的过期结果
+// 只更新属于上报结果的 Agent、且还在排队或执行中的任务记录，迟到、重复或冒名的结果不会覆盖已经结束的记录
+// 没有匹配的任务记录时返回 false；数据库出错时仍返回 true，避免因为记录失败丢掉结果
+func recordTaskResult(result *TaskResult) bool {
+	status := TaskStatusFailed
+	if result.Status == TaskResultExpired {
+		status = TaskStatusExpired
+	} else if result.Status == TaskResultTimedOut {
+		status = TaskStatusTimedOut
+	} else if result.Status == TaskResultRejected {
+		status = TaskStatusRejected
+	} else if result.Success {
+		status = TaskStatusSucceeded
+	}
+	updateData := map[string]interface{}{
+		"status":       status,
+		"exit_code":    result.ExitCode,
+		"output":       result.Output,
+		"stdout":       result.Stdout,
+		"stderr":       result.Stderr,
+		"stdout_bytes": result.StdoutBytes,
+		"stderr_bytes": result.StderrBytes,
+		"data":         string(result.Data),
+		"error":        result.Error,
+		"finished_at":  time.Now(),
+	}
+	dbResult := store.DB.Model(&model.WorkflowTask{}).
+		Where("id = ? AND agent_id = ? AND status IN ?", result.TaskID, result.AgentID, []string{TaskStatusQueued, TaskStatusDispatched}).
+		Updates(updateData)
+	if dbResult.Error != nil {
+		logger.L.Errorw("Failed to record task result", "task_id", result.TaskID, "error", dbResult.Error)
+		return true
+	}
+	if dbResult.RowsAffected == 0 {
+		logger.L.Warnw("Task result matches no pending task of this agent, not recorded", "task_id", result.TaskID, "agent_id", result.AgentID, "status", status)
+		return false
+	}
+	return true
+}
+
+// recordTaskCancelled 记录一个在队列中被取消的任务
+func recordTaskCancelled(taskID, reason string) {
+	updateData := map[string]interface{}{
+		"status":      TaskStatusCancelled,
+		"error":       reason,
+		"finished_at": time.Now(),
+	}
+	if err := store.DB.Model(&model.WorkflowTask{}).Where("id = ?", taskID).Updates(updateData).Error; err != nil {
+		logger.L.Errorw("Failed to record cancelled task", "task_id", taskID, "error", err)
+	}
+}
+
+// waitingWorkflow 返回仍在等待 task 的工作流，用于处理在分发前被移出队列的任务 (抢占、清理)
+// 工作流已经前进到其他任务或已经结束时返回 nil，这时任务只是过时的记录，不能再影响工作流
+func waitingWorkflow(task *Task) *model.Workflow {
+	var workflow model.Workflow
+	if err := store.DB.Where("id = ?", task.WorkflowID).First(&workflow).Error; err != nil {
+		logger.L.Errorw("Cannot find workflow of task removed from queue", "task_id", task.ID, "workflow_id", task.WorkflowID, "error", err)
+		return nil
+	}
+	if workflow.CurrentTaskID != task.ID || IsTerminalStatus(workflow.Status) {
+		return nil
+	}
+	return &workflow
+}
+
+// taskFileID 返回 file_push 任务下发的文件，file_pull 任务收集到的文件在上传完成后记录
+func taskFileID(task *Task) string {
+	if task.Kind == TaskKindFilePush && task.File != nil {
+		return task.File.FileID
+	}
+	return ""
+}
@@ -1,6 +1,10 @@
 package model
 
-import "gorm.io/gorm"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
 
 // Agent 代表一个终端智能体
 type Agent struct {
@@ -10,4 +14,24 @@ type Agent struct {
 	IPAddress  string
 	OS         string
 	Status     string // 例如: "online", "offline"
+	Group      string `gorm:"column:agent_group;index"` // Agent 所属分组, 例如 "build", "web"，用于维护窗口和批量选择
+	// Capabilities 是 Agent 支持的任务执行方式 (Task.Kind)，以逗号分隔，由 Agent 在注册或启动时上报
+	// 为空表示不上报能力的旧版本 Agent，下发任务时不做检查
+	Capabilities string
+
+	// 任务执行容量，由 Agent 随心跳上报，MaxConcurrency 为 0 表示不上报容量的旧版本 Agent
+	MaxConcurrency int
+	QueueSize      int
+	RunningTasks   int
+	QueuedTasks    int
+
+	// 维护模式: 工程师手工操作主机期间，暂停针对该 Agent 的所有自动化
+	MaintenanceUntil  *time.Time // 维护模式的到期时间，为空或已过期表示不在维护中
+	MaintenanceReason string
+	MaintenanceBy     string // 设置维护模式的人
+}
+
+// InMaintenance 判断 Agent 在 now 时刻是否处于维护模式
+func (a *Agent) InMaintenance(now time.Time) bool {
+	return a.MaintenanceUntil != nil && now.Before(*a.MaintenanceUntil)
 }
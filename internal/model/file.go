@@ -0,0 +1,16 @@
+package model
+
+import "time"
+
+// File 是保存在文件存储 (internal/blob) 中的文件，由操作员上传 (供 file_push 任务下发) 或从 Agent 收集 (file_pull 任务)
+type File struct {
+	ID        string `gorm:"primaryKey"` // 在文件存储中的 key 为 "files/<ID>"
+	Name      string
+	Size      int64
+	SHA256    string
+	Source    string // "upload" 或 "agent"
+	AgentID   string `gorm:"index"` // 从 Agent 收集时的来源 Agent
+	TaskID    string `gorm:"index"` // 从 Agent 收集时对应的 file_pull 任务
+	Path      string // 从 Agent 收集时在 Agent 上的路径
+	CreatedAt time.Time
+}
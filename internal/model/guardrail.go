@@ -0,0 +1,15 @@
+package model
+
+import "time"
+
+// KBGuardrail 是单个知识库条目的修复爆炸半径限制，字段为 0 表示不限制
+// 全局限制在配置文件的 guardrails 部分，两者同时生效
+type KBGuardrail struct {
+	ID              uint   `gorm:"primaryKey"`
+	KBID            string `gorm:"uniqueIndex;not null"`
+	MaxConcurrent   int    // 同时处于修复中的工作流数上限
+	MaxPerHour      int    // 最近一小时内开始修复的次数上限
+	MaxGroupPercent int    // 同一 Agent 分组中同时修复的 Agent 占比上限 (1-100)
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+}
@@ -0,0 +1,50 @@
+package model
+
+import "time"
+
+// Incident 把同一个根本问题引发的多个工作流和告警聚合在一起
+// 例如同一时刻十台 Agent 的 DNS 诊断都失败了，只会产生一个事件
+type Incident struct {
+	ID         string `gorm:"primaryKey"`
+	Title      string
+	GroupKey   string `gorm:"index"` // 聚合键，由知识库条目 (或告警类型) 和 Agent 分组组成
+	KBID       string
+	AlertType  string // 由没有知识库条目的告警创建时，记录告警类型
+	AgentGroup string
+	Status     string // "open", "acknowledged", "resolved"
+	Severity   string // 取聚合进来的告警中最严重的级别
+	Assignee   string
+
+	WorkflowCount int
+	AlertCount    int
+	FirstEventAt  time.Time
+	LastEventAt   time.Time // 最近一次聚合进来的工作流或告警的时间，用于判断时间窗口
+
+	AcknowledgedAt *time.Time
+	ResolvedAt     *time.Time
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+// IncidentNote 是事件处理过程中记录的备注
+type IncidentNote struct {
+	ID         uint   `gorm:"primaryKey"`
+	IncidentID string `gorm:"index"`
+	Author     string
+	Content    string
+	CreatedAt  time.Time
+}
+
+// Alert 是一条告警，来自外部监控系统或平台内部 (例如 Agent 离线)
+type Alert struct {
+	ID         uint   `gorm:"primaryKey"`
+	Type       string // 告警类型，例如 "agent_offline", "dns_failure"
+	Source     string // 告警来源，例如 "pioneer", "prometheus"
+	Severity   string // "info", "warning", "critical"
+	AgentID    string `gorm:"index"`
+	AgentGroup string
+	KBID       string
+	Message    string
+	IncidentID string `gorm:"index"`
+	CreatedAt  time.Time
+}
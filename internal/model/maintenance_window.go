@@ -0,0 +1,20 @@
+package model
+
+import "time"
+
+// MaintenanceWindow 定义了一个周期性的时间段，用于控制自动化修复可以在什么时候执行
+type MaintenanceWindow struct {
+	ID         uint   `gorm:"primaryKey"`
+	Name       string `gorm:"uniqueIndex;not null"`
+	Mode       string // "allow": 修复只能在窗口内执行; "block": 窗口内禁止修复 (封网/业务高峰期)
+	Action     string // 命中限制时的处理方式: "defer" 推迟到允许的时间, "hold" 挂起等待人工放行, "reject" 直接失败
+	Timezone   string // IANA 时区名称, 例如 "Asia/Shanghai"，为空时使用 UTC
+	Weekdays   []int  `gorm:"serializer:json"` // 生效的星期 (0 = 周日)，为空表示每天
+	StartTime  string // 开始时间 "HH:MM"
+	EndTime    string // 结束时间 "HH:MM"，早于开始时间表示跨越午夜
+	AgentGroup string // 作用的 Agent 分组，为空表示所有分组
+	KBID       string // 作用的知识库条目，为空表示所有条目
+	Enabled    bool
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
@@ -0,0 +1,47 @@
+package model
+
+import "time"
+
+// KBSchedule 定义了一个按 cron 表达式周期性触发知识库工作流的计划
+type KBSchedule struct {
+	ID            uint          `gorm:"primaryKey"`
+	Name          string        `gorm:"uniqueIndex;not null"`
+	CronExpr      string        `gorm:"not null"` // 标准 5 段 cron 表达式，也支持 @daily 等描述符和 CRON_TZ= 前缀
+	KBID          string        `gorm:"not null"`
+	AgentSelector AgentSelector `gorm:"serializer:json"`
+	Priority      int           // 启动的工作流的优先级，例行清理类计划通常使用 low
+	Enabled       bool
+	LastRunAt     *time.Time
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+// AgentSelector 描述一个计划要作用到哪些 Agent，多个条件之间是 "与" 的关系
+type AgentSelector struct {
+	All      bool     `json:"all,omitempty"`       // 显式选择全部 Agent，防止空选择器误伤整个集群
+	AgentIDs []string `json:"agent_ids,omitempty"` // 指定的 Agent UUID 列表
+	Hostname string   `json:"hostname,omitempty"`  // 主机名的 glob 匹配模式，例如 "build-*"
+	OS       string   `json:"os,omitempty"`        // 操作系统信息中包含的关键字 (不区分大小写)
+	Group    string   `json:"group,omitempty"`     // Agent 分组
+}
+
+// IsEmpty 判断选择器是否没有任何条件
+func (s AgentSelector) IsEmpty() bool {
+	return !s.All && len(s.AgentIDs) == 0 && s.Hostname == "" && s.OS == "" && s.Group == ""
+}
+
+// KBScheduleRun 记录计划的每一次执行
+type KBScheduleRun struct {
+	ID          uint     `gorm:"primaryKey"`
+	ScheduleID  uint     `gorm:"index"`
+	Trigger     string   // "cron" 或 "manual"
+	Outcome     string   // "succeeded", "partial", "failed", "no_targets"
+	Matched     int      // 选择器匹配到的 Agent 数量
+	Started     int      // 成功启动的工作流数量
+	Skipped     int      // 因为离线、维护模式等原因被跳过的 Agent 数量
+	Failed      int      // 启动工作流失败的数量
+	WorkflowIDs []string `gorm:"serializer:json"`
+	Message     string   // 跳过和失败的详细原因
+	StartedAt   time.Time
+	FinishedAt  time.Time
+}
@@ -0,0 +1,16 @@
+package model
+
+import "time"
+
+// Script 是保存在服务端的脚本，知识库步骤通过 {"script": "<name>"} 引用
+// 下发时脚本内容和 SHA256 随任务一起发送，Agent 校验后写入临时文件执行
+type Script struct {
+	ID          uint   `gorm:"primaryKey"`
+	Name        string `gorm:"uniqueIndex;not null"`
+	Description string
+	Interpreter string // "sh"、"bash"、"python3" 或 "direct" (按脚本的 shebang 直接执行)
+	Body        string
+	SHA256      string // Body 的 SHA-256 (十六进制)，保存时计算
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
@@ -0,0 +1,26 @@
+package model
+
+import "time"
+
+// QueuedTask 是排队中、尚未分发给 Agent 的任务，只在 task_queue.backend = postgres 时使用
+// 多个后端副本共享这张表，任务被领取或过期后即被删除，执行记录见 WorkflowTask
+type QueuedTask struct {
+	ID        string `gorm:"primaryKey"` // 与下发给 Agent 的任务 ID 一致
+	AgentID   string `gorm:"index"`
+	Priority  int
+	NotBefore *time.Time
+	ExpiresAt *time.Time `gorm:"index"`
+	Payload   string     `gorm:"type:jsonb"` // 完整的任务内容 (engine.Task 的 JSON)
+	CreatedAt time.Time
+}
+
+// TaskQueueState 是单个 Agent 任务队列的统计数据和最近活动时间，由所有副本共同更新
+// 只在 task_queue.backend = postgres 时使用，记录被删除即表示队列被清理
+type TaskQueueState struct {
+	AgentID      string    `gorm:"primaryKey"`
+	Submitted    int64     `gorm:"not null;default:0"`
+	Dispatched   int64     `gorm:"not null;default:0"`
+	Rejected     int64     `gorm:"not null;default:0"`
+	Expired      int64     `gorm:"not null;default:0"`
+	LastActiveAt time.Time // 最近一次提交或拉取任务的时间
+}
@@ -7,7 +7,65 @@ type Workflow struct {
 	KBID          string
 	AgentID       string `gorm:"index"` // 为 agent_id 添加索引以加快查询
 	Status        string
+	Priority      int    // 优先级，数值越大越优先 (-1 low, 0 normal, 1 high, 2 urgent)
+	StatusReason  string // 最近一次状态流转的原因
 	CurrentTaskID string
+	ResumeStatus  string     // 推迟 (deferred) 或挂起 (on_hold) 后恢复时要进入的状态
+	DeferredUntil *time.Time // 推迟到的时间点，到期后由调度器自动恢复
+	Version       int        `gorm:"not null;default:0"` // 乐观锁版本号，每次状态流转 +1
+	FinishedAt    *time.Time // 进入终止状态的时间
+	IncidentID    string     `gorm:"index"` // 所属的事件
 	CreatedAt     time.Time
 	UpdatedAt     time.Time
 }
+
+// WorkflowTransition 记录工作流的每一次状态流转
+type WorkflowTransition struct {
+	ID         uint   `gorm:"primaryKey"`
+	WorkflowID string `gorm:"index"`
+	FromStatus string
+	ToStatus   string
+	Reason     string
+	Version    int // 流转完成后工作流的版本号
+	CreatedAt  time.Time
+}
+
+// WorkflowTask 记录工作流下发的每一个任务及其执行结果
+type WorkflowTask struct {
+	ID           string `gorm:"primaryKey"` // 与下发给 Agent 的任务 ID 一致
+	WorkflowID   string `gorm:"index"`
+	AgentID      string
+	Type         string // "diagnostic", "remediation"
+	Kind         string // "command"、"script"、"file_push"、"file_pull"、"action"
+	Command      string
+	ScriptName   string // 脚本任务引用的脚本
+	Action       string // 内置动作任务的动作名称
+	FileID       string // file_push 任务下发的文件，或 file_pull 任务收集到的文件
+	Priority     int
+	Timeout      int    // Agent 执行任务的超时时间 (秒)，0 表示 Agent 的默认值
+	Status       string // "queued", "dispatched", "succeeded", "failed", "cancelled", "expired", "timed_out", "rejected"
+	ExitCode     int
+	Output       string // 旧版本 Agent 上报的合并输出
+	Stdout       string // 可能已被 Agent 截断，见 StdoutBytes
+	Stderr       string
+	StdoutBytes  int64 // 截断前的原始字节数
+	StderrBytes  int64
+	Data         string // 内置动作任务返回的结构化结果 (JSON)
+	Error        string
+	CreatedAt    time.Time  // 进入队列的时间
+	NotBefore    *time.Time // 最早可分发的时间
+	ExpiresAt    *time.Time // 过期时间，过期前未被领取的任务不再执行
+	DispatchedAt *time.Time // 被 Agent 领取的时间
+	FinishedAt   *time.Time // 收到执行结果的时间
+}
+
+// TaskOutputChunk 是 Agent 在任务执行过程中上报的一段输出，用于实时查看长时间运行的任务
+// 同一任务的 stdout 和 stderr 共用一个递增的序号，(task_id, seq) 唯一，Agent 重试上报时不会重复保存
+type TaskOutputChunk struct {
+	ID        uint   `gorm:"primaryKey"`
+	TaskID    string `gorm:"uniqueIndex:idx_task_output_seq"`
+	Seq       int    `gorm:"uniqueIndex:idx_task_output_seq"`
+	Stream    string // "stdout" 或 "stderr"
+	Data      []byte
+	CreatedAt time.Time
+}
@@ -0,0 +1,299 @@
+package report
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"os"
+	"path/filepath"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/config"
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/core/engine"
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/logger"
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/model"
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/store"
+)
+
+// 支持的报告格式
+const (
+	FormatJSON     = "json"
+	FormatMarkdown = "md"
+	FormatHTML     = "html"
+)
+
+const (
+	markdownTemplateName = "workflow.md.tmpl"
+	htmlTemplateName     = "workflow.html.tmpl"
+	defaultExcerptBytes  = 2048
+)
+
+// ErrUnknownFormat 表示请求了不支持的报告格式
+var ErrUnknownFormat = errors.New("unknown report format, must be one of json, md, html")
+
+//go:embed templates/*.tmpl
+var defaultTemplates embed.FS
+
+// WorkflowReport 是一次工作流执行的完整记录，用于事后复盘
+type WorkflowReport struct {
+	GeneratedAt time.Time      `json:"generated_at"`
+	Workflow    WorkflowInfo   `json:"workflow"`
+	KBItem      *KBItemInfo    `json:"kb_item"` // 知识库条目已被删除或无法访问时为空
+	Agent       *AgentInfo     `json:"agent"`   // Agent 已被注销时为空
+	Steps       []StepInfo     `json:"steps"`
+	Decisions   []DecisionInfo `json:"decisions"`
+	Outcome     OutcomeInfo    `json:"outcome"`
+}
+
+type WorkflowInfo struct {
+	ID         string     `json:"id"`
+	KBID       string     `json:"kb_id"`
+	Priority   string     `json:"priority"`
+	CreatedAt  time.Time  `json:"created_at"`
+	FinishedAt *time.Time `json:"finished_at"`
+}
+
+type KBItemInfo struct {
+	Diagnostics   []string `json:"diagnostics"`
+	AnalysisLogic string   `json:"analysis_logic"`
+	Remediation   string   `json:"remediation"`
+	Rollback      string   `json:"rollback"`
+}
+
+type AgentInfo struct {
+	UUID      string `json:"uuid"`
+	Hostname  string `json:"hostname"`
+	IPAddress string `json:"ip_address"`
+	OS        string `json:"os"`
+	Group     string `json:"group"`
+	Status    string `json:"status"`
+}
+
+// StepInfo 是工作流中下发的一个任务
+type StepInfo struct {
+	TaskID        string     `json:"task_id"`
+	Type          string     `json:"type"`
+	Command       string     `json:"command"`
+	Status        string     `json:"status"`
+	ExitCode      int        `json:"exit_code"`
+	OutputExcerpt string     `json:"output_excerpt"` // stdout (旧版本 Agent 为合并输出) 的摘录
+	StderrExcerpt string     `json:"stderr_excerpt"`
+	Error         string     `json:"error"`
+	QueuedAt      time.Time  `json:"queued_at"`
+	DispatchedAt  *time.Time `json:"dispatched_at"`
+	FinishedAt    *time.Time `json:"finished_at"`
+	Duration      string     `json:"duration"` // 从下发到结束的耗时，未结束时为空
+}
+
+// DecisionInfo 是引擎或操作员做出的一次状态流转决策
+type DecisionInfo struct {
+	At         time.Time `json:"at"`
+	FromStatus string    `json:"from_status"`
+	ToStatus   string    `json:"to_status"`
+	Reason     string    `json:"reason"`
+}
+
+type OutcomeInfo struct {
+	Status   string `json:"status"`
+	Reason   string `json:"reason"`
+	Finished bool   `json:"finished"`
+	Duration string `json:"duration"` // 工作流从创建到结束的耗时，未结束时为空
+}
+
+// BuildWorkflowReport 汇总工作流、知识库条目、Agent、任务记录和流转历史，生成报告
+func BuildWorkflowReport(workflow *model.Workflow) (*WorkflowReport, error) {
+	report := &WorkflowReport{
+		GeneratedAt: time.Now(),
+		Workflow: WorkflowInfo{
+			ID:         workflow.ID,
+			KBID:       workflow.KBID,
+			Priority:   engine.Priority(workflow.Priority).String(),
+			CreatedAt:  workflow.CreatedAt,
+			FinishedAt: workflow.FinishedAt,
+		},
+		Outcome: OutcomeInfo{
+			Status:   workflow.Status,
+			Reason:   workflow.StatusReason,
+			Finished: engine.IsTerminalStatus(workflow.Status),
+		},
+	}
+	if workflow.FinishedAt != nil {
+		report.Outcome.Duration = workflow.FinishedAt.Sub(workflow.CreatedAt).Round(time.Second).String()
+	}
+
+	// 知识库条目和 Agent 信息只是补充说明，获取失败不影响报告生成
+	if kbItem, err := engine.GetKBItem(workflow.KBID); err != nil {
+		logger.L.Warnw("Failed to load KB item for report", "workflow_id", workflow.ID, "kb_id", workflow.KBID, "error", err)
+	} else {
+		report.KBItem = toKBItemInfo(kbItem)
+	}
+
+	var agent model.Agent
+	if err := store.DB.Where("uuid = ?", workflow.AgentID).First(&agent).Error; err != nil {
+		logger.L.Warnw("Failed to load agent for report", "workflow_id", workflow.ID, "agent_id", workflow.AgentID, "error", err)
+	} else {
+		report.Agent = &AgentInfo{
+			UUID:      agent.UUID,
+			Hostname:  agent.Hostname,
+			IPAddress: agent.IPAddress,
+			OS:        agent.OS,
+			Group:     agent.Group,
+			Status:    agent.Status,
+		}
+	}
+
+	var tasks []model.WorkflowTask
+	if err := store.DB.Where("workflow_id = ?", workflow.ID).Order("created_at asc").Find(&tasks).Error; err != nil {
+		return nil, err
+	}
+	excerptBytes := config.C.Report.ExcerptBytes
+	if excerptBytes <= 0 {
+		excerptBytes = defaultExcerptBytes
+	}
+	report.Steps = make([]StepInfo, 0, len(tasks))
+	for _, task := range tasks {
+		command := task.Command
+		if task.ScriptName != "" {
+			command = "script: " + task.ScriptName
+		} else if task.Action != "" {
+			command = "action: " + task.Action
+		}
+		step := StepInfo{
+			TaskID:        task.ID,
+			Type:          task.Type,
+			Command:       command,
+			Status:        task.Status,
+			ExitCode:      task.ExitCode,
+			OutputExcerpt: excerpt(task.Output+task.Stdout, excerptBytes),
+			StderrExcerpt: excerpt(task.Stderr, excerptBytes),
+			Error:         task.Error,
+			QueuedAt:      task.CreatedAt,
+			DispatchedAt:  task.DispatchedAt,
+			FinishedAt:    task.FinishedAt,
+		}
+		if task.DispatchedAt != nil && task.FinishedAt != nil {
+			step.Duration = task.FinishedAt.Sub(*task.DispatchedAt).Round(time.Millisecond).String()
+		}
+		report.Steps = append(report.Steps, step)
+	}
+
+	var transitions []model.WorkflowTransition
+	if err := store.DB.Where("workflow_id = ?", workflow.ID).Order("version asc, id asc").Find(&transitions).Error; err != nil {
+		return nil, err
+	}
+	report.Decisions = make([]DecisionInfo, 0, len(transitions))
+	for _, t := range transitions {
+		report.Decisions = append(report.Decisions, DecisionInfo{
+			At:         t.CreatedAt,
+			FromStatus: t.FromStatus,
+			ToStatus:   t.ToStatus,
+			Reason:     t.Reason,
+		})
+	}
+	return report, nil
+}
+
+// Render 把报告渲染为 Markdown 或 HTML，返回内容和对应的 Content-Type
+func Render(report *WorkflowReport, format string) ([]byte, string, error) {
+	var buf bytes.Buffer
+	switch format {
+	case FormatMarkdown:
+		tmplText, err := loadTemplate(markdownTemplateName)
+		if err != nil {
+			return nil, "", err
+		}
+		tmpl, err := texttemplate.New(markdownTemplateName).Funcs(templateFuncs).Parse(tmplText)
+		if err != nil {
+			return nil, "", fmt.Errorf("parse %s: %w", markdownTemplateName, err)
+		}
+		if err := tmpl.Execute(&buf, report); err != nil {
+			return nil, "", err
+		}
+		return buf.Bytes(), "text/markdown; charset=utf-8", nil
+	case FormatHTML:
+		tmplText, err := loadTemplate(htmlTemplateName)
+		if err != nil {
+			return nil, "", err
+		}
+		tmpl, err := htmltemplate.New(htmlTemplateName).Funcs(templateFuncs).Parse(tmplText)
+		if err != nil {
+			return nil, "", fmt.Errorf("parse %s: %w", htmlTemplateName, err)
+		}
+		if err := tmpl.Execute(&buf, report); err != nil {
+			return nil, "", err
+		}
+		return buf.Bytes(), "text/html; charset=utf-8", nil
+	default:
+		return nil, "", ErrUnknownFormat
+	}
+}
+
+// loadTemplate 优先读取 report.template_dir 下的同名模板，不存在时使用内置模板
+func loadTemplate(name string) (string, error) {
+	if dir := config.C.Report.TemplateDir; dir != "" {
+		content, err := os.ReadFile(filepath.Join(dir, name))
+		if err == nil {
+			return string(content), nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return "", err
+		}
+	}
+	content, err := defaultTemplates.ReadFile("templates/" + name)
+	if err != nil {
+		return "", err
+	}
+	return string(content), nil
+}
+
+var templateFuncs = map[string]interface{}{
+	"formatTime": func(t interface{}) string {
+		switch v := t.(type) {
+		case time.Time:
+			return v.Format("2006-01-02 15:04:05 MST")
+		case *time.Time:
+			if v == nil {
+				return "-"
+			}
+			return v.Format("2006-01-02 15:04:05 MST")
+		}
+		return ""
+	},
+	"inc": func(i int) int { return i + 1 },
+	"orDash": func(s string) string {
+		if s == "" {
+			return "-"
+		}
+		return s
+	},
+}
+
+func toKBItemInfo(item *engine.KnowledgeBaseItem) *KBItemInfo {
+	info := &KBItemInfo{AnalysisLogic: item.AnalysisLogic}
+	for _, step := range item.Diagnostics {
+		info.Diagnostics = append(info.Diagnostics, engine.DescribeStep(step))
+	}
+	if item.Remediation != nil {
+		info.Remediation = engine.DescribeStep(item.Remediation)
+	}
+	if item.Rollback != nil {
+		info.Rollback = engine.DescribeStep(item.Rollback)
+	}
+	return info
+}
+
+// excerpt 截取输出的开头和结尾，中间用省略标记代替
+// 排障时最有用的通常是命令输出的开头 (上下文) 和结尾 (错误信息)
+func excerpt(output string, maxBytes int) string {
+	if len(output) <= maxBytes {
+		return output
+	}
+	half := maxBytes / 2
+	head := strings.ToValidUTF8(output[:half], "")
+	tail := strings.ToValidUTF8(output[len(output)-half:], "")
+	return fmt.Sprintf("%s\n... [%d bytes omitted] ...\n%s", head, len(output)-2*half, tail)
+}
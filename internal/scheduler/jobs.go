@@ -1,7 +1,9 @@
 package scheduler
 
 import (
+	"fmt"
 	"github.com/GenJi77JYXC/intelligent-pioneer/internal/config"
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/core/engine"
 	"github.com/GenJi77JYXC/intelligent-pioneer/internal/logger"
 	"github.com/GenJi77JYXC/intelligent-pioneer/internal/model"
 	"github.com/GenJi77JYXC/intelligent-pioneer/internal/store"
@@ -23,21 +25,85 @@ func CheckOfflineAgents() {
 	// 任何 'online' 的 Agent，如果其 updated_at 在这个时间点之前，就认为它离线了
 	deadline := time.Now().Add(-timeoutDuration)
 
-	// 构造更新条件
-	// 我们使用 GORM 的原生 SQL 功能来执行一个更高效的批量更新
-	// 这比“查询出来再逐个更新”要快得多
+	// 先查出所有心跳超时的 Agent，以便区分处于维护模式的 Agent
+	var staleAgents []model.Agent
+	if err := store.DB.Where("status = ? AND updated_at < ?", "online", deadline).Find(&staleAgents).Error; err != nil {
+		logger.L.Errorw("Error checking for offline agents", "error", err)
+		return
+	}
+	if len(staleAgents) == 0 {
+		logger.L.Debug("No offline agents found.")
+		return
+	}
+
+	// 使用一条批量更新语句标记离线，这比“逐个更新”要快得多
+	// 条件中再次带上 updated_at，避免覆盖查询之后刚刚收到心跳的 Agent
+	ids := make([]uint, 0, len(staleAgents))
+	for _, agent := range staleAgents {
+		ids = append(ids, agent.ID)
+	}
 	result := store.DB.Model(&model.Agent{}).
-		Where("status = ? AND updated_at < ?", "online", deadline).
+		Where("id IN ? AND status = ? AND updated_at < ?", ids, "online", deadline).
 		Update("status", "offline")
-
 	if result.Error != nil {
-		logger.L.Errorw("Error checking for offline agents", "error", result.Error)
+		logger.L.Errorw("Error marking agents as offline", "error", result.Error)
 		return
 	}
+	logger.L.Infow("Marked agents as offline", "count", result.RowsAffected)
 
-	if result.RowsAffected > 0 {
-		logger.L.Infow("Marked agents as offline", "count", result.RowsAffected)
-	} else {
-		logger.L.Debug("No offline agents found.")
+	// 维护模式中的 Agent 离线是预期行为 (例如工程师正在重启主机)，不发出告警
+	now := time.Now()
+	for _, agent := range staleAgents {
+		if agent.InMaintenance(now) {
+			logger.L.Debugw("Agent in maintenance mode went offline, not alerting", "agent_id", agent.UUID)
+			continue
+		}
+		logger.L.Warnw("Agent went offline", "agent_id", agent.UUID, "hostname", agent.Hostname, "last_seen", agent.UpdatedAt)
+		alert := &model.Alert{
+			Type:       "agent_offline",
+			Source:     "pioneer",
+			Severity:   engine.SeverityWarning,
+			AgentID:    agent.UUID,
+			AgentGroup: agent.Group,
+			Message:    fmt.Sprintf("agent %s (%s) stopped sending heartbeats, last seen at %s", agent.Hostname, agent.UUID, agent.UpdatedAt.Format(time.RFC3339)),
+		}
+		if err := engine.RecordAlert(alert); err != nil {
+			logger.L.Errorw("Failed to record agent offline alert", "agent_id", agent.UUID, "error", err)
+		}
+	}
+}
+
+// ResumeDeferredWorkflows 是一个定时任务，用于恢复被维护窗口推迟且已到期的工作流
+func ResumeDeferredWorkflows() {
+	logger.L.Debug("Running job: ResumeDeferredWorkflows")
+	engine.ResumeDueWorkflows()
+}
+
+// CheckKBEffectiveness 是一个定时任务，对修复成功率低于阈值的知识库条目发出告警
+func CheckKBEffectiveness() {
+	logger.L.Debug("Running job: CheckKBEffectiveness")
+
+	stats, err := engine.ComputeKBStats(engine.KBStatsQuery{Since: engine.DefaultAnalyticsSince()})
+	if err != nil {
+		logger.L.Errorw("Failed to compute KB stats", "error", err)
+		return
 	}
+	for _, s := range stats {
+		if s.Flagged {
+			logger.L.Warnw("KB item effectiveness below threshold", "kb_id", s.KBID, "reason", s.FlagReason,
+				"remediation_runs", s.RemediationRuns, "rollbacks", s.Rollbacks)
+		}
+	}
+}
+
+// EvictIdleTaskQueues 是一个定时任务，用于清理不活跃或已注销 Agent 的任务队列
+func EvictIdleTaskQueues() {
+	logger.L.Debug("Running job: EvictIdleTaskQueues")
+	engine.EvictIdleQueues()
+}
+
+// ExpireQueuedTasks 是一个定时任务，用于把队列中已过期的任务回报给所属工作流
+func ExpireQueuedTasks() {
+	logger.L.Debug("Running job: ExpireQueuedTasks")
+	engine.ExpireQueuedTasks()
 }
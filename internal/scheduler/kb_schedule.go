@@ -0,0 +1,271 @@
+package scheduler
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/core/engine"
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/logger"
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/model"
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/store"
+	"github.com/robfig/cron/v3"
+)
+
+// 计划执行结果
+const (
+	RunOutcomeSucceeded = "succeeded"  // 所有匹配的 Agent 都成功启动了工作流
+	RunOutcomePartial   = "partial"    // 部分 Agent 被跳过或启动失败
+	RunOutcomeFailed    = "failed"     // 没有任何工作流被成功启动
+	RunOutcomeNoTargets = "no_targets" // 选择器没有匹配到任何 Agent
+)
+
+var (
+	// kbScheduleEntries 记录计划 ID 与 cron 条目的对应关系，用于更新和删除计划
+	kbScheduleEntries = make(map[uint]cron.EntryID)
+	// kbScheduleExprs 记录已注册计划的 cron 表达式，多副本部署时用于发现其他副本上的修改
+	kbScheduleExprs = make(map[uint]string)
+	kbScheduleMu    sync.Mutex
+)
+
+// ParseScheduleExpr 解析计划使用的 cron 表达式
+// 与系统内部任务不同，计划使用标准的 5 段表达式 (分 时 日 月 周)，对运维人员更友好
+func ParseScheduleExpr(expr string) (cron.Schedule, error) {
+	return cron.ParseStandard(expr)
+}
+
+// loadKBSchedules 在启动时把数据库中所有启用的计划加载到 cron 中
+func loadKBSchedules() {
+	var schedules []model.KBSchedule
+	if err := store.DB.Where("enabled = ?", true).Find(&schedules).Error; err != nil {
+		logger.L.Errorw("Failed to load KB schedules", "error", err)
+		return
+	}
+
+	for i := range schedules {
+		if err := RegisterKBSchedule(&schedules[i]); err != nil {
+			// 单个计划的表达式错误不应该影响其他计划
+			logger.L.Errorw("Failed to register KB schedule", "schedule_id", schedules[i].ID, "cron", schedules[i].CronExpr, "error", err)
+		}
+	}
+	logger.L.Infow("KB schedules loaded", "count", len(kbScheduleEntries))
+}
+
+// syncKBSchedules 让本副本的 cron 与数据库中的计划保持一致
+// 多副本部署时，计划可能在其他副本上被创建、修改或删除，由调度器周期性调用
+func syncKBSchedules() {
+	var schedules []model.KBSchedule
+	if err := store.DB.Where("enabled = ?", true).Find(&schedules).Error; err != nil {
+		logger.L.Errorw("Failed to sync KB schedules", "error", err)
+		return
+	}
+
+	enabled := make(map[uint]bool, len(schedules))
+	for i := range schedules {
+		schedule := &schedules[i]
+		enabled[schedule.ID] = true
+		kbScheduleMu.Lock()
+		expr, registered := kbScheduleExprs[schedule.ID]
+		kbScheduleMu.Unlock()
+		if registered && expr == schedule.CronExpr {
+			continue
+		}
+		if err := RegisterKBSchedule(schedule); err != nil {
+			logger.L.Errorw("Failed to register KB schedule", "schedule_id", schedule.ID, "cron", schedule.CronExpr, "error", err)
+		}
+	}
+
+	kbScheduleMu.Lock()
+	var stale []uint
+	for scheduleID := range kbScheduleEntries {
+		if !enabled[scheduleID] {
+			stale = append(stale, scheduleID)
+		}
+	}
+	kbScheduleMu.Unlock()
+	for _, scheduleID := range stale {
+		UnregisterKBSchedule(scheduleID)
+	}
+}
+
+// RegisterKBSchedule 将计划注册 (或重新注册) 到 cron 中
+// 计划被禁用时只会移除已有的条目
+func RegisterKBSchedule(schedule *model.KBSchedule) error {
+	kbScheduleMu.Lock()
+	defer kbScheduleMu.Unlock()
+
+	if entryID, exists := kbScheduleEntries[schedule.ID]; exists {
+		c.Remove(entryID)
+		delete(kbScheduleEntries, schedule.ID)
+		delete(kbScheduleExprs, schedule.ID)
+	}
+	if !schedule.Enabled {
+		return nil
+	}
+
+	cronSchedule, err := ParseScheduleExpr(schedule.CronExpr)
+	if err != nil {
+		return err
+	}
+
+	scheduleID := schedule.ID
+	entryID := c.Schedule(cronSchedule, cron.FuncJob(func() {
+		// 多副本部署时每个副本都注册了计划，只有主副本真正执行
+		if !isLeader() {
+			return
+		}
+		if _, err := RunKBSchedule(scheduleID, "cron"); err != nil {
+			logger.L.Errorw("Scheduled KB run failed", "schedule_id", scheduleID, "error", err)
+		}
+	}))
+	kbScheduleEntries[schedule.ID] = entryID
+	kbScheduleExprs[schedule.ID] = schedule.CronExpr
+	logger.L.Infow("KB schedule registered", "schedule_id", schedule.ID, "name", schedule.Name, "cron", schedule.CronExpr)
+	return nil
+}
+
+// UnregisterKBSchedule 从 cron 中移除一个计划
+func UnregisterKBSchedule(scheduleID uint) {
+	kbScheduleMu.Lock()
+	defer kbScheduleMu.Unlock()
+
+	if entryID, exists := kbScheduleEntries[scheduleID]; exists {
+		c.Remove(entryID)
+		delete(kbScheduleEntries, scheduleID)
+		delete(kbScheduleExprs, scheduleID)
+		logger.L.Infow("KB schedule unregistered", "schedule_id", scheduleID)
+	}
+}
+
+// NextKBScheduleRun 返回计划下一次的执行时间，计划未注册时返回 nil
+func NextKBScheduleRun(scheduleID uint) *time.Time {
+	kbScheduleMu.Lock()
+	entryID, exists := kbScheduleEntries[scheduleID]
+	kbScheduleMu.Unlock()
+	if !exists {
+		return nil
+	}
+
+	next := c.Entry(entryID).Next
+	if next.IsZero() {
+		return nil
+	}
+	return &next
+}
+
+// RunKBSchedule 执行一次计划：解析 Agent 选择器，为每个匹配的 Agent 启动工作流，并记录执行历史
+// trigger 表示本次执行的来源 ("cron" 或 "manual")
+func RunKBSchedule(scheduleID uint, trigger string) (*model.KBScheduleRun, error) {
+	// 每次执行都从数据库重新读取，保证使用的是最新的计划定义
+	var schedule model.KBSchedule
+	if err := store.DB.First(&schedule, scheduleID).Error; err != nil {
+		return nil, err
+	}
+
+	logger.L.Infow("Running KB schedule", "schedule_id", schedule.ID, "name", schedule.Name, "kb_id", schedule.KBID, "trigger", trigger)
+
+	run := &model.KBScheduleRun{
+		ScheduleID: schedule.ID,
+		Trigger:    trigger,
+		StartedAt:  time.Now(),
+	}
+
+	agents, err := SelectAgents(schedule.AgentSelector)
+	if err != nil {
+		return nil, err
+	}
+	run.Matched = len(agents)
+
+	var messages []string
+	for _, agent := range agents {
+		if agent.Status != "online" {
+			run.Skipped++
+			messages = append(messages, fmt.Sprintf("%s: skipped, agent is %s", agent.UUID, agent.Status))
+			continue
+		}
+		if agent.InMaintenance(time.Now()) {
+			run.Skipped++
+			messages = append(messages, fmt.Sprintf("%s: skipped, agent is in maintenance until %s (%s)",
+				agent.UUID, agent.MaintenanceUntil.Format(time.RFC3339), agent.MaintenanceReason))
+			continue
+		}
+
+		workflowID, err := engine.StartKBWorkflow(agent.UUID, schedule.KBID, engine.WorkflowOptions{
+			Priority: engine.Priority(schedule.Priority),
+		})
+		if err != nil {
+			run.Failed++
+			messages = append(messages, fmt.Sprintf("%s: failed to start workflow: %v", agent.UUID, err))
+			continue
+		}
+		run.Started++
+		run.WorkflowIDs = append(run.WorkflowIDs, workflowID)
+	}
+
+	switch {
+	case run.Matched == 0:
+		run.Outcome = RunOutcomeNoTargets
+	case run.Started == run.Matched:
+		run.Outcome = RunOutcomeSucceeded
+	case run.Started == 0:
+		run.Outcome = RunOutcomeFailed
+	default:
+		run.Outcome = RunOutcomePartial
+	}
+	run.Message = strings.Join(messages, "\n")
+	run.FinishedAt = time.Now()
+
+	if err := store.DB.Create(run).Error; err != nil {
+		logger.L.Errorw("Failed to save KB schedule run", "schedule_id", schedule.ID, "error", err)
+	}
+	if err := store.DB.Model(&schedule).Update("last_run_at", run.StartedAt).Error; err != nil {
+		logger.L.Errorw("Failed to update KB schedule last run time", "schedule_id", schedule.ID, "error", err)
+	}
+
+	logger.L.Infow("KB schedule run finished",
+		"schedule_id", schedule.ID,
+		"outcome", run.Outcome,
+		"matched", run.Matched,
+		"started", run.Started,
+		"skipped", run.Skipped,
+		"failed", run.Failed,
+	)
+	return run, nil
+}
+
+// SelectAgents 返回所有满足选择器条件的 Agent
+func SelectAgents(selector model.AgentSelector) ([]model.Agent, error) {
+	if selector.IsEmpty() {
+		return nil, nil
+	}
+
+	query := store.DB.Order("id asc")
+	if len(selector.AgentIDs) > 0 {
+		query = query.Where("uuid IN ?", selector.AgentIDs)
+	}
+	if selector.Group != "" {
+		query = query.Where("agent_group = ?", selector.Group)
+	}
+
+	var agents []model.Agent
+	if err := query.Find(&agents).Error; err != nil {
+		return nil, err
+	}
+
+	// 主机名和操作系统的匹配在内存中完成，glob 语义比 SQL LIKE 更直观
+	matched := agents[:0]
+	for _, agent := range agents {
+		if selector.Hostname != "" {
+			if ok, _ := path.Match(selector.Hostname, agent.Hostname); !ok {
+				continue
+			}
+		}
+		if selector.OS != "" && !strings.Contains(strings.ToLower(agent.OS), strings.ToLower(selector.OS)) {
+			continue
+		}
+		matched = append(matched, agent)
+	}
+	return matched, nil
+}
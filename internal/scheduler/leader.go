@@ -0,0 +1,104 @@
+package scheduler
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/core/engine"
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/logger"
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/store"
+)
+
+// schedulerLeaderLock 是选举主副本使用的 advisory lock 名称
+const schedulerLeaderLock = "pioneer_scheduler_leader"
+
+const (
+	leaderRetryInterval = 15 * time.Second // 非主副本尝试接管的间隔
+	leaderCheckInterval = 10 * time.Second // 主副本检查锁连接是否存活的间隔
+)
+
+var (
+	// leader 表示当前副本是否为主副本
+	leader atomic.Bool
+	// stopLeaderElection 在调度器停止时释放主副本身份
+	stopLeaderElection context.CancelFunc
+)
+
+// isLeader 判断当前副本是否应该执行定时任务，单副本部署时总是返回 true
+func isLeader() bool {
+	return !engine.Clustered() || leader.Load()
+}
+
+// leaderOnly 包装定时任务，多副本部署时只在主副本上执行，避免同一个任务被每个副本各执行一次
+func leaderOnly(job func()) func() {
+	return func() {
+		if !isLeader() {
+			return
+		}
+		job()
+	}
+}
+
+// startLeaderElection 在多副本部署时开始竞选主副本
+func startLeaderElection() {
+	ctx, cancel := context.WithCancel(context.Background())
+	stopLeaderElection = cancel
+	go runLeaderElection(ctx)
+}
+
+// runLeaderElection 通过 session 级别的 advisory lock 选出主副本
+// 主副本在一个专用连接上持有锁，副本崩溃或连接断开时锁自动释放，其他副本在下一次尝试时接管
+func runLeaderElection(ctx context.Context) {
+	for {
+		err := holdLeadership(ctx)
+		if leader.Swap(false) {
+			logger.L.Warnw("Lost scheduler leadership", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(leaderRetryInterval):
+		}
+	}
+}
+
+// holdLeadership 尝试获取主副本身份，获取成功后一直持有直到连接断开或 ctx 被取消
+// 没有获取到时立即返回 nil
+func holdLeadership(ctx context.Context) error {
+	sqlDB, err := store.DB.DB()
+	if err != nil {
+		return err
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var acquired bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock(hashtext($1))", schedulerLeaderLock).Scan(&acquired); err != nil {
+		return err
+	}
+	if !acquired {
+		return nil
+	}
+	// 连接会被放回连接池，必须显式释放锁
+	defer conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock(hashtext($1))", schedulerLeaderLock)
+
+	leader.Store(true)
+	logger.L.Info("Acquired scheduler leadership, scheduled jobs will run on this replica")
+
+	ticker := time.NewTicker(leaderCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			if err := conn.PingContext(ctx); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -2,6 +2,7 @@ package scheduler
 
 import (
 	"github.com/GenJi77JYXC/intelligent-pioneer/internal/config"
+	"github.com/GenJi77JYXC/intelligent-pioneer/internal/core/engine"
 	"github.com/GenJi77JYXC/intelligent-pioneer/internal/logger"
 	"github.com/robfig/cron/v3"
 )
@@ -16,11 +17,58 @@ func InitScheduler() {
 	c = cron.New(cron.WithSeconds())
 
 	// 注册我们的离线检测任务
-	_, err := c.AddFunc(config.C.Agent.OfflineCheckCron, CheckOfflineAgents)
+	_, err := c.AddFunc(config.C.Agent.OfflineCheckCron, leaderOnly(CheckOfflineAgents))
 	if err != nil {
 		logger.L.Fatalw("Failed to add offline agent check job to scheduler", "error", err)
 	}
 
+	// 注册推迟工作流的恢复任务
+	deferredCheckCron := config.C.Workflow.DeferredCheckCron
+	if deferredCheckCron == "" {
+		deferredCheckCron = "@every 1m"
+	}
+	if _, err := c.AddFunc(deferredCheckCron, leaderOnly(ResumeDeferredWorkflows)); err != nil {
+		logger.L.Fatalw("Failed to add deferred workflow job to scheduler", "error", err)
+	}
+
+	// 注册知识库效果检查任务
+	analyticsCheckCron := config.C.Analytics.CheckCron
+	if analyticsCheckCron == "" {
+		analyticsCheckCron = "@every 1h"
+	}
+	if _, err := c.AddFunc(analyticsCheckCron, leaderOnly(CheckKBEffectiveness)); err != nil {
+		logger.L.Fatalw("Failed to add KB effectiveness job to scheduler", "error", err)
+	}
+
+	// 注册不活跃任务队列的清理任务
+	queueCleanupCron := config.C.TaskQueue.CleanupCron
+	if queueCleanupCron == "" {
+		queueCleanupCron = "@every 5m"
+	}
+	if _, err := c.AddFunc(queueCleanupCron, leaderOnly(EvictIdleTaskQueues)); err != nil {
+		logger.L.Fatalw("Failed to add task queue cleanup job to scheduler", "error", err)
+	}
+
+	// 注册过期任务的清理任务
+	taskExpiryCron := config.C.TaskQueue.ExpiryCron
+	if taskExpiryCron == "" {
+		taskExpiryCron = "@every 1m"
+	}
+	if _, err := c.AddFunc(taskExpiryCron, leaderOnly(ExpireQueuedTasks)); err != nil {
+		logger.L.Fatalw("Failed to add task expiry job to scheduler", "error", err)
+	}
+
+	// 加载运维人员定义的周期性知识库计划
+	loadKBSchedules()
+
+	// 多副本部署时，定时任务只在主副本上执行；计划的增删改可能发生在其他副本上，定期与数据库同步
+	if engine.Clustered() {
+		startLeaderElection()
+		if _, err := c.AddFunc("@every 1m", syncKBSchedules); err != nil {
+			logger.L.Fatalw("Failed to add KB schedule sync job to scheduler", "error", err)
+		}
+	}
+
 	// 在一个新的 goroutine 中启动调度器，避免阻塞主线程
 	go c.Start()
 
@@ -30,6 +78,9 @@ func InitScheduler() {
 // StopScheduler 优雅地停止调度器 (在程序退出时调用)
 func StopScheduler() {
 	logger.L.Info("Stopping scheduler...")
+	if stopLeaderElection != nil {
+		stopLeaderElection()
+	}
 	ctx := c.Stop() // Stop 会等待所有正在运行的任务完成
 	<-ctx.Done()
 	logger.L.Info("Scheduler stopped gracefully.")
@@ -16,21 +16,8 @@ var DB *gorm.DB
 func InitPostgres() {
 	logger.L.Info("Initializing PostgreSQL connection...")
 
-	// 从全局配置中获取数据库配置
-	pgConfig := config.C.Database.Postgres
-
-	// 构建 DSN (Data Source Name)
-	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=%s TimeZone=Asia/Shanghai",
-		pgConfig.Host,
-		pgConfig.User,
-		pgConfig.Password,
-		pgConfig.DBName,
-		pgConfig.Port,
-		pgConfig.SSLMode,
-	)
-
 	// 连接数据库
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	db, err := gorm.Open(postgres.Open(PostgresDSN()), &gorm.Config{})
 	if err != nil {
 		logger.L.Fatalw("Failed to connect to PostgreSQL database", "error", err)
 	}
@@ -43,12 +30,40 @@ func InitPostgres() {
 	migrateDatabase()
 }
 
+// PostgresDSN 根据配置构建 DSN (Data Source Name)
+// 除了 GORM 之外，需要独占连接的功能 (例如 LISTEN) 也用它建立连接
+func PostgresDSN() string {
+	pgConfig := config.C.Database.Postgres
+	return fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=%s TimeZone=Asia/Shanghai",
+		pgConfig.Host,
+		pgConfig.User,
+		pgConfig.Password,
+		pgConfig.DBName,
+		pgConfig.Port,
+		pgConfig.SSLMode,
+	)
+}
+
 func migrateDatabase() {
 	logger.L.Info("Running database migrations...")
 	// GORM 会自动检查 `Agent` 结构体对应的表是否存在，不存在则创建
 	err := DB.AutoMigrate(
 		&model.Agent{},
 		&model.Workflow{},
+		&model.WorkflowTransition{},
+		&model.WorkflowTask{},
+		&model.TaskOutputChunk{},
+		&model.Script{},
+		&model.File{},
+		&model.KBSchedule{},
+		&model.KBScheduleRun{},
+		&model.MaintenanceWindow{},
+		&model.Incident{},
+		&model.IncidentNote{},
+		&model.Alert{},
+		&model.KBGuardrail{},
+		&model.QueuedTask{},
+		&model.TaskQueueState{},
 	)
 	if err != nil {
 		logger.L.Fatalw("Failed to migrate database", "error", err)